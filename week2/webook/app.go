@@ -0,0 +1,15 @@
+package main
+
+import (
+	"basic-go/week2/webook/internal/job"
+	"github.com/gin-gonic/gin"
+)
+
+// App 把 web 服务器和后台任务组装在一起，由 wire 注入
+type App struct {
+	Server         *gin.Engine
+	WechatTokenJob *job.WechatTokenRefreshJob
+	// UserMigrationValidateJob 迁移 users 表的时候才有，平时是 nil
+	UserMigrationValidateJob *job.UserMigrationValidateJob
+	UserAnonymizeJob         *job.UserAnonymizeJob
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+// ArticleStatus 文章状态，会存进数据库，已经用了的值不能改
+type ArticleStatus uint8
+
+const (
+	ArticleStatusUnknown ArticleStatus = 0
+	// ArticleStatusUnpublished 草稿，或者发表之后又改了还没重新发表
+	ArticleStatusUnpublished ArticleStatus = 1
+	ArticleStatusPublished   ArticleStatus = 2
+	// ArticleStatusPrivate 作者撤回了，读者看不到
+	ArticleStatusPrivate ArticleStatus = 3
+)
+
+// Article 文章，作者改的是草稿，发表的时候把草稿同步给读者
+type Article struct {
+	Id      int64
+	Title   string
+	Content string
+	Author  Author
+	Status  ArticleStatus
+	Ctime   time.Time
+	Utime   time.Time
+}
+
+// Author 作者，就是写文章的用户
+type Author struct {
+	Id   int64
+	Name string
+}
+
+// ArticleAbstractLen 摘要最多多少个字，列表从数据库里也只取这么长
+const ArticleAbstractLen = 128
+
+// Abstract 列表里展示的摘要，取内容的前 ArticleAbstractLen 个字
+func (a Article) Abstract() string {
+	cs := []rune(a.Content)
+	if len(cs) <= ArticleAbstractLen {
+		return a.Content
+	}
+	return string(cs[:ArticleAbstractLen])
+}
+
+// ArticleCursor 翻页的位置，是上一页最后一篇的更新时间和 id，零值表示第一页。
+// 按位置翻页不会因为中间有新发表的文章而重复或者漏掉
+type ArticleCursor struct {
+	Utime time.Time
+	Id    int64
+}
+
+func (c ArticleCursor) IsZero() bool {
+	return c.Id == 0
+}
@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// AuthMethod 认证事件的类型，会存进数据库，也会返回给前端，已经用了的不能改
+type AuthMethod string
+
+const (
+	AuthMethodPassword AuthMethod = "password"
+	AuthMethodSMS      AuthMethod = "sms"
+	AuthMethodWechat   AuthMethod = "wechat"
+	AuthMethodRefresh  AuthMethod = "refresh_token"
+	AuthMethodLogout   AuthMethod = "logout"
+)
+
+// IsLogin 是不是一次登录，只有登录才判断是不是新设备
+func (m AuthMethod) IsLogin() bool {
+	return m == AuthMethodPassword || m == AuthMethodSMS || m == AuthMethodWechat
+}
+
+// AuthEvent 一次登录、刷新 token 或者退出登录
+type AuthEvent struct {
+	Id int64
+	// Uid 失败的时候可能不知道是哪个用户，就是 0
+	Uid       int64
+	Method    AuthMethod
+	IP        string
+	UserAgent string
+	// Device 由 UserAgent 算出来，用来判断是不是新设备
+	Device  string
+	Success bool
+	// Reason 失败的原因，是错误码的 MsgKey，比如 user.invalid_credential
+	Reason string
+	// NewDevice 这个用户以前登录成功过，但是没有在这个设备上登录过
+	NewDevice bool
+	Ctime     time.Time
+}
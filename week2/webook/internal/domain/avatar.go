@@ -0,0 +1,7 @@
+package domain
+
+// Avatar 用户上传的头像。URL 会保存到用户资料里，ThumbnailURL 是列表、评论里用的小图
+type Avatar struct {
+	URL          string
+	ThumbnailURL string
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+type UserExportStatus uint8
+
+const (
+	// UserExportPending 还在后台打包
+	UserExportPending UserExportStatus = 0
+	UserExportReady   UserExportStatus = 1
+	// UserExportFailed 打包失败了，可以重新发起
+	UserExportFailed UserExportStatus = 2
+)
+
+// UserExport 用户发起的一次个人数据导出
+type UserExport struct {
+	Id     string
+	Uid    int64
+	Status UserExportStatus
+	Ctime  time.Time
+}
@@ -0,0 +1,14 @@
+package domain
+
+// Interactive 某个业务对象（比如一篇文章）的阅读、点赞、收藏数。
+// Biz 是业务，比如 "article"，BizId 是业务对象的 id
+type Interactive struct {
+	Biz        string
+	BizId      int64
+	ReadCnt    int64
+	LikeCnt    int64
+	CollectCnt int64
+	// 当前用户有没有点赞、收藏，批量查询的时候不填
+	Liked     bool
+	Collected bool
+}
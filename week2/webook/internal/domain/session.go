@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// Session 一次登录。登录的时候由 jwt.RedisJWTHandler 以 JSON 的形式写进 redis，
+// 所以 json tag 改了要考虑已经存进去的数据
+type Session struct {
+	Ssid      string    `json:"ssid"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	LoginAt   time.Time `json:"loginAt"`
+}
@@ -11,11 +11,78 @@ type User struct {
 	// YYYY-MM-DD
 	Birthday time.Time
 	Resume   string
+	// 头像的 url
+	Avatar   string
+	Gender   Gender
+	Location string
+	// Website 个人主页
+	Website string
+	// Locale 用户选的界面语言，比如 zh-CN、en-US，为空就按 Accept-Language 来
+	Locale string
 
 	Phone string
 
+	// Handle 用户名，公开主页 /users/{handle} 用它，没设置是空的
+	Handle string
+	// HandleUtime 上次改用户名的时间，用来算多久之后才能再改
+	HandleUtime time.Time
+	Privacy     UserPrivacy
+
 	Ctime time.Time
+	// Utime 资料的更新时间，部分更新的时候用来判断资料有没有被别人改过
+	Utime time.Time
 
 	// 组合一下wechatInfo
 	WechatInfo WechatInfo
+
+	Status UserStatus
+	// DeactivatedAt 注销的时间，冷静期从这个时候开始算
+	DeactivatedAt time.Time
+}
+
+// UserStatus 账号状态
+type UserStatus uint8
+
+const (
+	UserStatusActive UserStatus = 0
+	// UserStatusDeactivated 用户自己注销了，冷静期内登录的时候可以恢复
+	UserStatusDeactivated UserStatus = 1
+	// UserStatusDeleted 冷静期过了，个人信息已经清除，不能再恢复
+	UserStatusDeleted UserStatus = 2
+)
+
+// Gender 性别，用户可以不填
+type Gender uint8
+
+const (
+	GenderUnknown Gender = 0
+	GenderMale    Gender = 1
+	GenderFemale  Gender = 2
+	GenderOther   Gender = 3
+)
+
+// UserPrivacy 隐私设置，只影响别人看到的公开主页，自己看自己的资料不受影响
+type UserPrivacy struct {
+	HideBirthday bool
+	HideResume   bool
+}
+
+// UserProfilePatch 部分更新个人资料，nil 的字段不改
+type UserProfilePatch struct {
+	Nickname *string
+	// Birthday 零值表示清空
+	Birthday *time.Time
+	Resume   *string
+	Gender   *Gender
+	Location *string
+	Website  *string
+	Avatar   *string
+	// Utime 客户端读到的资料的更新时间，和现在的不一样说明资料已经被别人改过了（乐观锁）
+	Utime time.Time
+}
+
+// IsEmpty 一个字段都没改
+func (p UserProfilePatch) IsEmpty() bool {
+	return p.Nickname == nil && p.Birthday == nil && p.Resume == nil && p.Gender == nil &&
+		p.Location == nil && p.Website == nil && p.Avatar == nil
 }
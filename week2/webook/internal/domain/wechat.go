@@ -1,6 +1,33 @@
 package domain
 
+import "time"
+
 type WechatInfo struct {
 	UnionId string `json:"unionid"`
 	OpenId  string `json:"openid"`
+
+	// Token 登录时微信返回的接口调用凭证，不跟着用户一起序列化
+	Token WechatToken `json:"-"`
+}
+
+// WechatToken 微信的接口调用凭证（access_token 和 refresh_token）
+type WechatToken struct {
+	Uid int64
+	// App 凭证是哪个微信应用发的，刷新的时候要用同一个应用的 appid
+	App          string
+	OpenId       string
+	AccessToken  string
+	RefreshToken string
+	Scope        string
+	// access_token 的过期时间
+	ExpiresAt time.Time
+}
+
+// WechatUserInfo 微信 userinfo 接口返回的个人信息
+type WechatUserInfo struct {
+	OpenId   string
+	UnionId  string
+	Nickname string
+	// 头像的 url
+	Avatar string
 }
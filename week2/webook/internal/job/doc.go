@@ -0,0 +1,2 @@
+// Package job 后台定时任务
+package job
@@ -0,0 +1,55 @@
+package job
+
+import (
+	"basic-go/week2/webook/internal/service"
+	"context"
+	"log"
+	"time"
+)
+
+// UserAnonymizeJob 定时清除过了冷静期的注销账号的个人信息
+type UserAnonymizeJob struct {
+	svc service.UserService
+	// 多久跑一次
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func NewUserAnonymizeJob(svc service.UserService) *UserAnonymizeJob {
+	return &UserAnonymizeJob{
+		svc:      svc,
+		interval: time.Hour,
+		timeout:  time.Minute * 10,
+	}
+}
+
+func (j *UserAnonymizeJob) Name() string {
+	return "user_anonymize"
+}
+
+// Start 在后台一直跑，直到 ctx 被取消
+func (j *UserAnonymizeJob) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				j.run(ctx)
+			}
+		}
+	}()
+}
+
+func (j *UserAnonymizeJob) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	cnt, err := j.svc.AnonymizeExpired(ctx)
+	if err != nil {
+		log.Println("清除注销账号的任务出错，已清除", cnt, "个", err)
+		return
+	}
+	log.Println("清除注销账号的任务完成，清除了", cnt, "个")
+}
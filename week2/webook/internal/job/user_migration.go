@@ -0,0 +1,109 @@
+package job
+
+import (
+	"basic-go/week2/webook/internal/migrator"
+	"basic-go/week2/webook/internal/repository/dao"
+	"context"
+	"log"
+	"time"
+)
+
+// UserMigrationValidateJob 迁移 users 表期间，定时按当前的双写模式校验一轮，同时在后台修复不一致的数据
+type UserMigrationValidateJob struct {
+	dao      *dao.DoubleWriteUserDao
+	src      dao.UserMigrateDao
+	dst      dao.UserMigrateDao
+	producer *migrator.ChanProducer
+	fixer    *migrator.UserFixer
+	// 多久跑一次
+	interval time.Duration
+	// 一轮最多跑多久
+	timeout   time.Duration
+	batchSize int
+}
+
+func NewUserMigrationValidateJob(d *dao.DoubleWriteUserDao, src dao.UserMigrateDao, dst dao.UserMigrateDao) *UserMigrationValidateJob {
+	j := &UserMigrationValidateJob{
+		dao:       d,
+		src:       src,
+		dst:       dst,
+		producer:  migrator.NewChanProducer(1024),
+		fixer:     migrator.NewUserFixer(src, dst),
+		interval:  time.Minute * 10,
+		timeout:   time.Minute * 5,
+		batchSize: 100,
+	}
+	d.OnWriteFailed(j.onWriteFailed)
+	return j
+}
+
+// onWriteFailed 双写失败了马上修这一条，不用等下一轮校验
+func (j *UserMigrationValidateJob) onWriteFailed(ctx context.Context, id int64, pattern string) {
+	direction := migrator.DirectionSrc
+	if pattern == dao.PatternDstFirst {
+		direction = migrator.DirectionDst
+	}
+	// 是在请求里调的，channel 满了不能一直等，发不出去就靠下一轮校验
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+	defer cancel()
+	err := j.producer.ProduceInconsistentEvent(ctx, migrator.InconsistentEvent{
+		Id:        id,
+		Direction: direction,
+		Type:      migrator.InconsistentEventTypeWriteFailed,
+	})
+	if err != nil {
+		log.Println("发送用户数据修复事件失败", id, err)
+	}
+}
+
+func (j *UserMigrationValidateJob) Interval(interval time.Duration) *UserMigrationValidateJob {
+	j.interval = interval
+	return j
+}
+
+func (j *UserMigrationValidateJob) BatchSize(size int) *UserMigrationValidateJob {
+	j.batchSize = size
+	return j
+}
+
+func (j *UserMigrationValidateJob) Name() string {
+	return "user_migration_validate"
+}
+
+// Start 在后台一直跑，直到 ctx 被取消
+func (j *UserMigrationValidateJob) Start(ctx context.Context) {
+	go j.fixer.Consume(ctx, j.producer.Events())
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				j.run(ctx)
+			}
+		}
+	}()
+}
+
+func (j *UserMigrationValidateJob) run(ctx context.Context) {
+	var validator *migrator.UserValidator
+	switch j.dao.Pattern() {
+	case dao.PatternSrcFirst:
+		validator = migrator.NewUserValidator(j.src, j.dst, migrator.DirectionSrc, j.producer)
+	case dao.PatternDstFirst:
+		validator = migrator.NewUserValidator(j.dst, j.src, migrator.DirectionDst, j.producer)
+	default:
+		// 只写一边的时候另一边本来就不全，不用校验
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	cnt, err := validator.BatchSize(j.batchSize).Validate(ctx)
+	if err != nil {
+		log.Println("校验用户数据的任务出错", err)
+		return
+	}
+	log.Println("校验用户数据的任务完成，发现不一致", cnt, "条")
+}
@@ -0,0 +1,58 @@
+package job
+
+import (
+	"basic-go/week2/webook/internal/service"
+	"context"
+	"log"
+	"time"
+)
+
+// WechatTokenRefreshJob 定时把快过期的微信 access_token 刷新掉（access_token 只有 2 小时有效期）
+type WechatTokenRefreshJob struct {
+	svc service.WechatUserService
+	// 多久跑一次
+	interval time.Duration
+	// 提前多久刷新
+	window  time.Duration
+	timeout time.Duration
+}
+
+func NewWechatTokenRefreshJob(svc service.WechatUserService) *WechatTokenRefreshJob {
+	return &WechatTokenRefreshJob{
+		svc:      svc,
+		interval: time.Minute * 10,
+		window:   time.Minute * 30,
+		timeout:  time.Minute,
+	}
+}
+
+func (j *WechatTokenRefreshJob) Name() string {
+	return "wechat_token_refresh"
+}
+
+// Start 在后台一直跑，直到 ctx 被取消
+func (j *WechatTokenRefreshJob) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				j.run(ctx)
+			}
+		}
+	}()
+}
+
+func (j *WechatTokenRefreshJob) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	cnt, err := j.svc.RefreshExpiring(ctx, j.window)
+	if err != nil {
+		log.Println("刷新微信凭证的任务出错", err)
+		return
+	}
+	log.Println("刷新微信凭证的任务完成，刷新了", cnt, "个")
+}
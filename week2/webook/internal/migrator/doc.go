@@ -0,0 +1,2 @@
+// Package migrator 不停服迁移表数据：双写期间定时校验源表和目标表，发现不一致就发修复事件，由 Fixer 修复
+package migrator
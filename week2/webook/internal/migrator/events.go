@@ -0,0 +1,57 @@
+package migrator
+
+import "context"
+
+const (
+	// DirectionSrc 以源表为准，修复的时候拿源表的数据覆盖目标表
+	DirectionSrc = "SRC"
+	// DirectionDst 以目标表为准
+	DirectionDst = "DST"
+
+	// InconsistentEventTypeNEQ 两边都有，但是 utime 不一样
+	InconsistentEventTypeNEQ = "neq"
+	// InconsistentEventTypeTargetMissing 为准的那边有，另一边没有
+	InconsistentEventTypeTargetMissing = "target_missing"
+	// InconsistentEventTypeBaseMissing 为准的那边没有，另一边有
+	InconsistentEventTypeBaseMissing = "base_missing"
+	// InconsistentEventTypeWriteFailed 双写的时候后写的那边失败了，不是校验发现的
+	InconsistentEventTypeWriteFailed = "write_failed"
+)
+
+// InconsistentEvent 校验（或者双写失败）发现的一条不一致的数据
+type InconsistentEvent struct {
+	Id        int64
+	Direction string
+	Type      string
+}
+
+// Producer 发出修复事件
+type Producer interface {
+	ProduceInconsistentEvent(ctx context.Context, evt InconsistentEvent) error
+}
+
+// ChanProducer 进程内用 channel 把事件交给 Fixer。
+// 事件丢了也没关系（比如进程重启），下一轮校验还会再发现
+type ChanProducer struct {
+	ch chan InconsistentEvent
+}
+
+func NewChanProducer(size int) *ChanProducer {
+	return &ChanProducer{
+		ch: make(chan InconsistentEvent, size),
+	}
+}
+
+// ProduceInconsistentEvent channel 满了就等，直到 ctx 超时
+func (p *ChanProducer) ProduceInconsistentEvent(ctx context.Context, evt InconsistentEvent) error {
+	select {
+	case p.ch <- evt:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *ChanProducer) Events() <-chan InconsistentEvent {
+	return p.ch
+}
@@ -0,0 +1,53 @@
+package migrator
+
+import (
+	"basic-go/week2/webook/internal/repository/dao"
+	"basic-go/week2/webook/pkg/dbx"
+	"context"
+	"log"
+)
+
+// UserFixer 处理修复事件，拿为准的那边的数据覆盖另一边
+type UserFixer struct {
+	src dao.UserMigrateDao
+	dst dao.UserMigrateDao
+}
+
+func NewUserFixer(src dao.UserMigrateDao, dst dao.UserMigrateDao) *UserFixer {
+	return &UserFixer{
+		src: src,
+		dst: dst,
+	}
+}
+
+// Fix 不看事件的类型，按修复的时候 base 里的最新数据来：有就覆盖，没有就删掉。
+// 因为事件发出来之后，这条数据可能又被改过了
+func (f *UserFixer) Fix(ctx context.Context, evt InconsistentEvent) error {
+	base, target := f.src, f.dst
+	if evt.Direction == DirectionDst {
+		base, target = f.dst, f.src
+	}
+	ctx = dbx.WithPrimary(ctx)
+	us, err := base.FindByIds(ctx, []int64{evt.Id})
+	if err != nil {
+		return err
+	}
+	if len(us) == 0 {
+		return target.DeleteById(ctx, evt.Id)
+	}
+	return target.Upsert(ctx, us[0])
+}
+
+// Consume 一直处理 events 里的事件，直到 ctx 被取消。修复失败只打日志，下一轮校验还会再发现
+func (f *UserFixer) Consume(ctx context.Context, events <-chan InconsistentEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-events:
+			if err := f.Fix(ctx, evt); err != nil {
+				log.Println("修复用户数据失败", evt.Id, evt.Direction, evt.Type, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package migrator
+
+import (
+	"basic-go/week2/webook/internal/repository/dao"
+	"basic-go/week2/webook/internal/repository/dao/daotest"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+)
+
+func TestUserValidator_Fix(t *testing.T) {
+	src, dst := dao.NewUserMigrateDao(daotest.NewSQLiteDB(t, "src.db")), dao.NewUserMigrateDao(daotest.NewSQLiteDB(t, "dst.db"))
+	ctx := context.Background()
+	for id := int64(1); id <= 5; id++ {
+		u := dao.User{Id: id, Phone: sql.NullString{String: fmt.Sprintf("1520000000%d", id), Valid: true}, Utime: 100}
+		require.NoError(t, src.Upsert(ctx, u))
+		switch id {
+		case 2:
+			// 目标表没有
+		case 3:
+			// 目标表是旧数据
+			u.Utime = 99
+			require.NoError(t, dst.Upsert(ctx, u))
+		default:
+			require.NoError(t, dst.Upsert(ctx, u))
+		}
+	}
+	// 源表里已经删掉了
+	require.NoError(t, dst.Upsert(ctx, dao.User{Id: 6, Utime: 100}))
+
+	producer := NewChanProducer(10)
+	cnt, err := NewUserValidator(src, dst, DirectionSrc, producer).BatchSize(2).Validate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cnt)
+
+	var evts []InconsistentEvent
+	for i := 0; i < cnt; i++ {
+		evts = append(evts, <-producer.Events())
+	}
+	sort.Slice(evts, func(i, j int) bool {
+		return evts[i].Id < evts[j].Id
+	})
+	assert.Equal(t, []InconsistentEvent{
+		{Id: 2, Direction: DirectionSrc, Type: InconsistentEventTypeTargetMissing},
+		{Id: 3, Direction: DirectionSrc, Type: InconsistentEventTypeNEQ},
+		{Id: 6, Direction: DirectionSrc, Type: InconsistentEventTypeBaseMissing},
+	}, evts)
+
+	fixer := NewUserFixer(src, dst)
+	for _, evt := range evts {
+		require.NoError(t, fixer.Fix(ctx, evt))
+	}
+	// 修完再校验一次就没有不一致了
+	cnt, err = NewUserValidator(src, dst, DirectionSrc, producer).BatchSize(2).Validate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository/cache"
+	"basic-go/week2/webook/internal/repository/dao"
+	"basic-go/week2/webook/pkg/dbx"
+	"context"
+	"errors"
+	"golang.org/x/sync/singleflight"
+	"log"
+	"strconv"
+	"time"
+)
+
+// ErrArticleNotFound 文章不存在，或者不是这个作者的
+var ErrArticleNotFound = dao.ErrArticleNotFound
+
+type ArticleRepository interface {
+	// Create 新建草稿，返回文章的 id
+	Create(ctx context.Context, art domain.Article) (int64, error)
+	// Update 改草稿，只能改作者自己的
+	Update(ctx context.Context, art domain.Article) error
+	// Sync 保存草稿并同步给读者，返回文章的 id
+	Sync(ctx context.Context, art domain.Article) (int64, error)
+	// SyncStatus 同时改草稿和已发表的文章的状态
+	SyncStatus(ctx context.Context, uid int64, id int64, status domain.ArticleStatus) error
+	// GetByAuthor 作者自己的文章，最近改过的在前面
+	GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]domain.Article, error)
+	GetById(ctx context.Context, id int64) (domain.Article, error)
+	// GetPubById 读者看的文章，包括撤回了的，调用方要判断状态
+	GetPubById(ctx context.Context, id int64) (domain.Article, error)
+	// ListPub 作者发表的文章，不包括撤回了的，从 cursor 的下一篇开始取。Content 只有摘要
+	ListPub(ctx context.Context, uid int64, cursor domain.ArticleCursor, limit int) ([]domain.Article, error)
+}
+
+// CachedArticleRepository 草稿只有作者自己看，不缓存；读者看的走缓存，缓存策略和 CachedUserRepository 一样：
+// 1. 写：先更新数据库，再删缓存，过一会儿再删一次（延迟双删）。撤回之后读者必须很快就看不到
+// 2. 发表之后马上把文章放进缓存（预加载），刚发表的文章最容易被看
+// 3. 重建：同一个实例里的并发请求用 singleflight 合并，热门文章缓存过期的时候不会一起打到数据库
+// 4. 作者的第一页单独缓存，只有不带 cursor、并且 limit 不超过 firstPageSize 的请求能用
+// 5. 文章不存在就写一个很短的负缓存，防止缓存穿透。发表的时候 SetPub 会把它覆盖掉
+type CachedArticleRepository struct {
+	dao   dao.ArticleDao
+	cache cache.ArticleCache
+	// 第二次删缓存前等多久
+	doubleDeleteDelay time.Duration
+	// 第一页缓存多少篇，要比前端一页的数量多一篇，service 多取一篇判断还有没有下一页
+	firstPageSize int
+
+	group singleflight.Group
+}
+
+func NewArticleRepository(d dao.ArticleDao, c cache.ArticleCache) ArticleRepository {
+	return &CachedArticleRepository{
+		dao:               d,
+		cache:             c,
+		doubleDeleteDelay: time.Millisecond * 500,
+		firstPageSize:     50,
+	}
+}
+
+func (repo *CachedArticleRepository) Create(ctx context.Context, art domain.Article) (int64, error) {
+	return repo.dao.Insert(ctx, repo.toEntity(art))
+}
+
+func (repo *CachedArticleRepository) Update(ctx context.Context, art domain.Article) error {
+	return repo.dao.UpdateById(ctx, repo.toEntity(art))
+}
+
+func (repo *CachedArticleRepository) Sync(ctx context.Context, art domain.Article) (int64, error) {
+	id, err := repo.dao.Sync(ctx, repo.toEntity(art))
+	if err != nil {
+		return 0, err
+	}
+	uid := art.Author.Id
+	// 预加载。ctime 是第一次发表的时间，要从数据库读出来；刚写完，要读主库
+	pub, err := repo.dao.GetPubById(dbx.WithPrimary(ctx), id)
+	if err != nil {
+		// 发表已经成功了，预加载失败了就删掉旧的，等读者来看的时候再放进缓存
+		log.Println("预加载文章缓存失败", id, err)
+		repo.twice(ctx, func(ctx context.Context) {
+			repo.delPub(ctx, id)
+			repo.delFirstPage(ctx, uid)
+		})
+		return id, nil
+	}
+	art = repo.toDomain(dao.Article(pub))
+	// 第二次是重新写一遍，不是删：并发的读请求可能从从库读到旧的文章写进了缓存
+	repo.twice(ctx, func(ctx context.Context) {
+		if err := repo.cache.SetPub(ctx, art); err != nil {
+			log.Println("预加载文章缓存失败", id, err)
+		}
+		repo.delFirstPage(ctx, uid)
+	})
+	return id, nil
+}
+
+func (repo *CachedArticleRepository) SyncStatus(ctx context.Context, uid int64, id int64, status domain.ArticleStatus) error {
+	err := repo.dao.SyncStatus(ctx, uid, id, uint8(status))
+	if err != nil {
+		return err
+	}
+	repo.twice(ctx, func(ctx context.Context) {
+		repo.delPub(ctx, id)
+		repo.delFirstPage(ctx, uid)
+	})
+	return nil
+}
+
+// twice 更新完数据库之后马上处理一次缓存，过一会儿再处理一次（延迟双删）
+func (repo *CachedArticleRepository) twice(ctx context.Context, fn func(ctx context.Context)) {
+	fn(ctx)
+	// note 这时候请求可能已经结束了，ctx 会被取消，所以第二次要用新的 ctx
+	time.AfterFunc(repo.doubleDeleteDelay, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		fn(ctx)
+	})
+}
+
+// 删缓存失败了打日志，靠第二次删和过期时间兜底
+
+func (repo *CachedArticleRepository) delPub(ctx context.Context, id int64) {
+	if err := repo.cache.DelPub(ctx, id); err != nil {
+		log.Println("删除文章缓存失败", id, err)
+	}
+}
+
+func (repo *CachedArticleRepository) delFirstPage(ctx context.Context, uid int64) {
+	if err := repo.cache.DelFirstPage(ctx, uid); err != nil {
+		log.Println("删除作者第一页缓存失败", uid, err)
+	}
+}
+
+func (repo *CachedArticleRepository) GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]domain.Article, error) {
+	arts, err := repo.dao.GetByAuthor(ctx, uid, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, repo.toDomain(art))
+	}
+	return res, nil
+}
+
+func (repo *CachedArticleRepository) GetById(ctx context.Context, id int64) (domain.Article, error) {
+	art, err := repo.dao.GetById(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	return repo.toDomain(art), nil
+}
+
+func (repo *CachedArticleRepository) GetPubById(ctx context.Context, id int64) (domain.Article, error) {
+	art, err := repo.cache.GetPub(ctx, id)
+	switch {
+	case err == nil:
+		return art, nil
+	case errors.Is(err, cache.ErrArticleNotFound):
+		// 负缓存，刚查过数据库，没有这篇文章
+		return domain.Article{}, ErrArticleNotFound
+	}
+	// 缓存没有或者 redis 出错了都查数据库
+	val, err, _ := repo.group.Do("pub:"+strconv.FormatInt(id, 10), func() (any, error) {
+		pub, err := repo.dao.GetPubById(ctx, id)
+		if errors.Is(err, dao.ErrArticleNotFound) {
+			// 写失败了也没关系，只是下次还要查数据库。发表的时候 SetPub 会覆盖掉
+			if err := repo.cache.SetPubNotFound(ctx, id); err != nil {
+				log.Println("写文章负缓存失败", id, err)
+			}
+		}
+		if err != nil {
+			return domain.Article{}, err
+		}
+		art := repo.toDomain(dao.Article(pub))
+		_ = repo.cache.SetPub(ctx, art)
+		return art, nil
+	})
+	if err != nil {
+		return domain.Article{}, err
+	}
+	return val.(domain.Article), nil
+}
+
+func (repo *CachedArticleRepository) ListPub(ctx context.Context, uid int64, cursor domain.ArticleCursor, limit int) ([]domain.Article, error) {
+	if !cursor.IsZero() || limit > repo.firstPageSize {
+		return repo.listPubInDB(ctx, uid, cursor, limit)
+	}
+	arts, err := repo.cache.GetFirstPage(ctx, uid)
+	if err != nil {
+		val, err, _ := repo.group.Do("page:"+strconv.FormatInt(uid, 10), func() (any, error) {
+			arts, err := repo.listPubInDB(ctx, uid, cursor, repo.firstPageSize)
+			if err != nil {
+				return nil, err
+			}
+			_ = repo.cache.SetFirstPage(ctx, uid, arts)
+			return arts, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		// singleflight 合并的请求拿到的是同一个切片，复制一份，调用方改了也不会互相影响
+		arts = append([]domain.Article(nil), val.([]domain.Article)...)
+	}
+	if len(arts) > limit {
+		arts = arts[:limit]
+	}
+	return arts, nil
+}
+
+func (repo *CachedArticleRepository) listPubInDB(ctx context.Context, uid int64,
+	cursor domain.ArticleCursor, limit int) ([]domain.Article, error) {
+	var utime int64
+	if !cursor.IsZero() {
+		utime = cursor.Utime.UnixMilli()
+	}
+	pubs, err := repo.dao.ListPubByAuthor(ctx, uid, utime, cursor.Id, limit, domain.ArticleAbstractLen)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(pubs))
+	for _, pub := range pubs {
+		art := repo.toDomain(dao.Article(pub))
+		// 和缓存里的第一页一样，只给摘要
+		art.Content = art.Abstract()
+		res = append(res, art)
+	}
+	return res, nil
+}
+
+func (repo *CachedArticleRepository) toEntity(art domain.Article) dao.Article {
+	return dao.Article{
+		Id:       art.Id,
+		Title:    art.Title,
+		Content:  art.Content,
+		AuthorId: art.Author.Id,
+		Status:   uint8(art.Status),
+	}
+}
+
+func (repo *CachedArticleRepository) toDomain(art dao.Article) domain.Article {
+	return domain.Article{
+		Id:      art.Id,
+		Title:   art.Title,
+		Content: art.Content,
+		Author: domain.Author{
+			Id: art.AuthorId,
+		},
+		Status: domain.ArticleStatus(art.Status),
+		Ctime:  time.UnixMilli(art.Ctime),
+		Utime:  time.UnixMilli(art.Utime),
+	}
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository/cache"
+	cachemocks "basic-go/week2/webook/internal/repository/cache/mocks"
+	"basic-go/week2/webook/internal/repository/dao"
+	daomocks "basic-go/week2/webook/internal/repository/dao/mocks"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func TestCachedArticleRepository_ListPub(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	cached := []domain.Article{
+		{Id: 3, Title: "三", Author: domain.Author{Id: 1}, Utime: now},
+		{Id: 2, Title: "二", Author: domain.Author{Id: 1}, Utime: now},
+		{Id: 1, Title: "一", Author: domain.Author{Id: 1}, Utime: now},
+	}
+	testCases := []struct {
+		name   string
+		mock   func(ctrl *gomock.Controller) (dao.ArticleDao, cache.ArticleCache)
+		cursor domain.ArticleCursor
+		limit  int
+
+		wantArts []domain.Article
+		wantErr  error
+	}{
+		{
+			name: "第一页缓存命中，按 limit 截断",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDao, cache.ArticleCache) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().GetFirstPage(gomock.Any(), int64(1)).Return(cached, nil)
+				return daomocks.NewMockArticleDao(ctrl), c
+			},
+			limit:    2,
+			wantArts: cached[:2],
+		},
+		{
+			name: "第一页缓存没有，查数据库并写缓存",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDao, cache.ArticleCache) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				d := daomocks.NewMockArticleDao(ctrl)
+				c.EXPECT().GetFirstPage(gomock.Any(), int64(1)).Return(nil, cache.ErrKeyNotExist)
+				// 缓存整个第一页，不是只取 limit 篇
+				d.EXPECT().ListPubByAuthor(gomock.Any(), int64(1), int64(0), int64(0), 50, domain.ArticleAbstractLen).
+					Return([]dao.PublishedArticle{
+						{Id: 2, Title: "二", Content: "内容", AuthorId: 1, Status: 2, Utime: now.UnixMilli()},
+					}, nil)
+				c.EXPECT().SetFirstPage(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+				return d, c
+			},
+			limit: 20,
+			wantArts: []domain.Article{
+				{Id: 2, Title: "二", Content: "内容", Author: domain.Author{Id: 1},
+					Status: domain.ArticleStatusPublished, Ctime: time.UnixMilli(0), Utime: now},
+			},
+		},
+		{
+			name: "带 cursor，直接查数据库",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDao, cache.ArticleCache) {
+				d := daomocks.NewMockArticleDao(ctrl)
+				d.EXPECT().ListPubByAuthor(gomock.Any(), int64(1), now.UnixMilli(), int64(3), 20, domain.ArticleAbstractLen).
+					Return([]dao.PublishedArticle{}, nil)
+				return d, cachemocks.NewMockArticleCache(ctrl)
+			},
+			cursor:   domain.ArticleCursor{Utime: now, Id: 3},
+			limit:    20,
+			wantArts: []domain.Article{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			d, c := tc.mock(ctrl)
+			repo := NewArticleRepository(d, c)
+			arts, err := repo.ListPub(context.Background(), 1, tc.cursor, tc.limit)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantArts, arts)
+		})
+	}
+}
+
+func TestCachedArticleRepository_Sync(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d := daomocks.NewMockArticleDao(ctrl)
+	c := cachemocks.NewMockArticleCache(ctrl)
+	d.EXPECT().Sync(gomock.Any(), gomock.Any()).Return(int64(1), nil)
+	d.EXPECT().GetPubById(gomock.Any(), int64(1)).
+		Return(dao.PublishedArticle{Id: 1, Title: "标题", AuthorId: 2, Status: 2}, nil)
+	// 发表之后马上放进缓存，延迟之后再放一次；作者的第一页删两次
+	c.EXPECT().SetPub(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+	done := make(chan struct{}, 2)
+	c.EXPECT().DelFirstPage(gomock.Any(), int64(2)).Times(2).DoAndReturn(func(ctx context.Context, uid int64) error {
+		done <- struct{}{}
+		return nil
+	})
+	repo := NewArticleRepository(d, c).(*CachedArticleRepository)
+	repo.doubleDeleteDelay = time.Millisecond * 10
+	id, err := repo.Sync(context.Background(), domain.Article{Title: "标题", Author: domain.Author{Id: 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("缓存没有处理两次")
+		}
+	}
+}
+
+func TestCachedArticleRepository_GetPubById(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (dao.ArticleDao, cache.ArticleCache)
+		wantArt domain.Article
+		wantErr error
+	}{
+		{
+			name: "缓存命中",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDao, cache.ArticleCache) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().GetPub(gomock.Any(), int64(1)).Return(domain.Article{Id: 1, Title: "标题"}, nil)
+				return daomocks.NewMockArticleDao(ctrl), c
+			},
+			wantArt: domain.Article{Id: 1, Title: "标题"},
+		},
+		{
+			name: "负缓存命中，不查数据库",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDao, cache.ArticleCache) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().GetPub(gomock.Any(), int64(1)).Return(domain.Article{}, cache.ErrArticleNotFound)
+				return daomocks.NewMockArticleDao(ctrl), c
+			},
+			wantErr: ErrArticleNotFound,
+		},
+		{
+			name: "数据库里没有，写负缓存",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDao, cache.ArticleCache) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().GetPub(gomock.Any(), int64(1)).Return(domain.Article{}, cache.ErrKeyNotExist)
+				c.EXPECT().SetPubNotFound(gomock.Any(), int64(1)).Return(nil)
+				d := daomocks.NewMockArticleDao(ctrl)
+				d.EXPECT().GetPubById(gomock.Any(), int64(1)).Return(dao.PublishedArticle{}, dao.ErrArticleNotFound)
+				return d, c
+			},
+			wantErr: ErrArticleNotFound,
+		},
+		{
+			name: "缓存没有，查数据库写回缓存",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDao, cache.ArticleCache) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().GetPub(gomock.Any(), int64(1)).Return(domain.Article{}, cache.ErrKeyNotExist)
+				c.EXPECT().SetPub(gomock.Any(), gomock.Any()).Return(nil)
+				d := daomocks.NewMockArticleDao(ctrl)
+				d.EXPECT().GetPubById(gomock.Any(), int64(1)).
+					Return(dao.PublishedArticle{Id: 1, Title: "标题", AuthorId: 2, Status: 2, Ctime: 1000, Utime: 1000}, nil)
+				return d, c
+			},
+			wantArt: domain.Article{Id: 1, Title: "标题", Author: domain.Author{Id: 2}, Status: 2,
+				Ctime: time.UnixMilli(1000), Utime: time.UnixMilli(1000)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			d, c := tc.mock(ctrl)
+			repo := NewArticleRepository(d, c)
+			art, err := repo.GetPubById(context.Background(), 1)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantArt, art)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository/dao"
+	"context"
+	"time"
+)
+
+type AuthEventRepository interface {
+	BatchCreate(ctx context.Context, events []domain.AuthEvent) error
+	// FindByUid 最近的 limit 条，最新的在前面
+	FindByUid(ctx context.Context, uid int64, limit int) ([]domain.AuthEvent, error)
+	// FindDevices 这个用户登录成功过的所有设备
+	FindDevices(ctx context.Context, uid int64) ([]string, error)
+}
+
+// DBAuthEventRepository 审计日志只在数据库里，不缓存
+type DBAuthEventRepository struct {
+	dao dao.AuthEventDao
+}
+
+func NewAuthEventRepository(d dao.AuthEventDao) AuthEventRepository {
+	return &DBAuthEventRepository{
+		dao: d,
+	}
+}
+
+func (repo *DBAuthEventRepository) BatchCreate(ctx context.Context, events []domain.AuthEvent) error {
+	entities := make([]dao.AuthEvent, 0, len(events))
+	for _, e := range events {
+		entities = append(entities, repo.toEntity(e))
+	}
+	return repo.dao.BatchInsert(ctx, entities)
+}
+
+func (repo *DBAuthEventRepository) FindByUid(ctx context.Context, uid int64, limit int) ([]domain.AuthEvent, error) {
+	entities, err := repo.dao.FindByUid(ctx, uid, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AuthEvent, 0, len(entities))
+	for _, e := range entities {
+		res = append(res, repo.toDomain(e))
+	}
+	return res, nil
+}
+
+func (repo *DBAuthEventRepository) FindDevices(ctx context.Context, uid int64) ([]string, error) {
+	return repo.dao.FindDevices(ctx, uid)
+}
+
+func (repo *DBAuthEventRepository) toEntity(e domain.AuthEvent) dao.AuthEvent {
+	return dao.AuthEvent{
+		Uid:       e.Uid,
+		Method:    string(e.Method),
+		Ip:        e.IP,
+		UserAgent: e.UserAgent,
+		Device:    e.Device,
+		Success:   e.Success,
+		Reason:    e.Reason,
+		NewDevice: e.NewDevice,
+		Ctime:     e.Ctime.UnixMilli(),
+	}
+}
+
+func (repo *DBAuthEventRepository) toDomain(e dao.AuthEvent) domain.AuthEvent {
+	return domain.AuthEvent{
+		Id:        e.Id,
+		Uid:       e.Uid,
+		Method:    domain.AuthMethod(e.Method),
+		IP:        e.Ip,
+		UserAgent: e.UserAgent,
+		Device:    e.Device,
+		Success:   e.Success,
+		Reason:    e.Reason,
+		NewDevice: e.NewDevice,
+		Ctime:     time.UnixMilli(e.Ctime),
+	}
+}
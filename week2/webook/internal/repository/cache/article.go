@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/pkg/codec"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// articleSchemaVersion cachedArticle 的结构改了就加一，和 userSchemaVersion 一样
+const articleSchemaVersion byte = 1
+
+// ErrArticleNotFound 缓存里记着这篇文章不存在（负缓存），和 ErrUserNotFound 一样
+var ErrArticleNotFound = errors.New("缓存中记录了文章不存在")
+
+// ArticleCache 读者看的文章（已发表的）。缓存两样东西：
+// 1. 文章的详情，读者点进来看的，发表的时候就预先放进来，因为刚发表的文章最容易被看
+// 2. 每个作者发表的文章列表的第一页，大部分读者只看第一页
+type ArticleCache interface {
+	// GetPub 缓存里没有返回 ErrKeyNotExist，记着文章不存在返回 ErrArticleNotFound
+	GetPub(ctx context.Context, id int64) (domain.Article, error)
+	// SetPub 内容太大的不放进缓存，会把旧的缓存删掉
+	SetPub(ctx context.Context, art domain.Article) error
+	// SetPubNotFound 记下这篇文章不存在，过期时间很短，防止有人用不存在的 id 一直打数据库（缓存穿透）
+	SetPubNotFound(ctx context.Context, id int64) error
+	DelPub(ctx context.Context, id int64) error
+	// GetFirstPage 缓存里没有返回 ErrKeyNotExist。列表里的 Content 只有摘要
+	GetFirstPage(ctx context.Context, uid int64) ([]domain.Article, error)
+	SetFirstPage(ctx context.Context, uid int64, arts []domain.Article) error
+	DelFirstPage(ctx context.Context, uid int64) error
+}
+
+type RedisArticleCache struct {
+	cmd redis.Cmdable
+	// 详情缓存多久，过期了还有人看就会再放进来，所以留在缓存里的都是最近有人看的
+	expiration time.Duration
+	// 第一页缓存多久，作者发表、撤回的时候会删掉，所以可以长一点
+	pageExpiration time.Duration
+	// 负缓存多久，发表的时候会覆盖掉，所以只是兜底
+	notFoundExpiration time.Duration
+	// 内容超过这么大的文章不放进缓存，太大的 value 会拖慢 redis
+	maxContentSize int
+	codec          codec.Codec
+}
+
+func NewArticleCache(cmd redis.Cmdable) ArticleCache {
+	return &RedisArticleCache{
+		cmd:                cmd,
+		expiration:         time.Minute * 10,
+		pageExpiration:     time.Hour,
+		notFoundExpiration: time.Minute,
+		maxContentSize:     1 << 20,
+		codec:              codec.Msgpack,
+	}
+}
+
+func (c *RedisArticleCache) pubKey(id int64) string {
+	return fmt.Sprintf("article:pub:%d", id)
+}
+
+func (c *RedisArticleCache) firstPageKey(uid int64) string {
+	return fmt.Sprintf("article:pub:first_page:%d", uid)
+}
+
+func (c *RedisArticleCache) GetPub(ctx context.Context, id int64) (domain.Article, error) {
+	var ca cachedArticle
+	if err := c.get(ctx, c.pubKey(id), &ca); err != nil {
+		return domain.Article{}, err
+	}
+	return ca.toDomain(), nil
+}
+
+func (c *RedisArticleCache) SetPub(ctx context.Context, art domain.Article) error {
+	if len(art.Content) > c.maxContentSize {
+		// 不放进缓存，但是旧的（包括负缓存）要删掉，不然读者看到的一直是旧的
+		return c.DelPub(ctx, art.Id)
+	}
+	return c.set(ctx, c.pubKey(art.Id), newCachedArticle(art), c.expiration)
+}
+
+func (c *RedisArticleCache) SetPubNotFound(ctx context.Context, id int64) error {
+	return c.cmd.Set(ctx, c.pubKey(id), notFoundVal, c.notFoundExpiration).Err()
+}
+
+func (c *RedisArticleCache) DelPub(ctx context.Context, id int64) error {
+	return c.cmd.Del(ctx, c.pubKey(id)).Err()
+}
+
+func (c *RedisArticleCache) GetFirstPage(ctx context.Context, uid int64) ([]domain.Article, error) {
+	var cas []cachedArticle
+	if err := c.get(ctx, c.firstPageKey(uid), &cas); err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(cas))
+	for _, ca := range cas {
+		res = append(res, ca.toDomain())
+	}
+	return res, nil
+}
+
+func (c *RedisArticleCache) SetFirstPage(ctx context.Context, uid int64, arts []domain.Article) error {
+	cas := make([]cachedArticle, 0, len(arts))
+	for _, art := range arts {
+		// 列表只要摘要，不存全文
+		art.Content = art.Abstract()
+		cas = append(cas, newCachedArticle(art))
+	}
+	return c.set(ctx, c.firstPageKey(uid), cas, c.pageExpiration)
+}
+
+func (c *RedisArticleCache) DelFirstPage(ctx context.Context, uid int64) error {
+	return c.cmd.Del(ctx, c.firstPageKey(uid)).Err()
+}
+
+func (c *RedisArticleCache) get(ctx context.Context, key string, val any) error {
+	data, err := c.cmd.Get(ctx, key).Bytes()
+	if err != nil {
+		return err
+	}
+	// 只有详情会存负缓存
+	if string(data) == notFoundVal {
+		return ErrArticleNotFound
+	}
+	err = codec.Decode(data, articleSchemaVersion, val)
+	if errors.Is(err, codec.ErrVersionMismatch) || errors.Is(err, codec.ErrInvalidEnvelope) {
+		return ErrKeyNotExist
+	}
+	return err
+}
+
+func (c *RedisArticleCache) set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	data, err := codec.Encode(c.codec, articleSchemaVersion, val)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, key, data, expiration).Err()
+}
+
+// cachedArticle 存进 redis 的文章，作者名字不放进来，改昵称的时候不用管文章的缓存
+// note 改了这里的字段一定要把 articleSchemaVersion 加一
+type cachedArticle struct {
+	Id       int64  `json:"id" msgpack:"id"`
+	Title    string `json:"title" msgpack:"title"`
+	Content  string `json:"content" msgpack:"content"`
+	AuthorId int64  `json:"authorId" msgpack:"author_id"`
+	Status   uint8  `json:"status" msgpack:"status"`
+	Ctime    int64  `json:"ctime" msgpack:"ctime"`
+	Utime    int64  `json:"utime" msgpack:"utime"`
+}
+
+func newCachedArticle(art domain.Article) cachedArticle {
+	return cachedArticle{
+		Id:       art.Id,
+		Title:    art.Title,
+		Content:  art.Content,
+		AuthorId: art.Author.Id,
+		Status:   uint8(art.Status),
+		Ctime:    art.Ctime.UnixMilli(),
+		Utime:    art.Utime.UnixMilli(),
+	}
+}
+
+func (ca cachedArticle) toDomain() domain.Article {
+	return domain.Article{
+		Id:      ca.Id,
+		Title:   ca.Title,
+		Content: ca.Content,
+		Author: domain.Author{
+			Id: ca.AuthorId,
+		},
+		Status: domain.ArticleStatus(ca.Status),
+		Ctime:  time.UnixMilli(ca.Ctime),
+		Utime:  time.UnixMilli(ca.Utime),
+	}
+}
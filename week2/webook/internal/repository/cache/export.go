@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// UserExportCache 导出任务和打包好的文件都放在 redis 里，不管下载请求落到哪个实例都能拿到，过期自动删掉
+type UserExportCache interface {
+	// SetTask 同时记下这是这个用户最近的一次导出
+	SetTask(ctx context.Context, t domain.UserExport) error
+	// GetTask 没有返回 ErrKeyNotExist
+	GetTask(ctx context.Context, id string) (domain.UserExport, error)
+	// GetLatest 这个用户最近的一次导出，没有返回 ErrKeyNotExist
+	GetLatest(ctx context.Context, uid int64) (domain.UserExport, error)
+	SetArchive(ctx context.Context, id string, data []byte) error
+	GetArchive(ctx context.Context, id string) ([]byte, error)
+}
+
+type RedisUserExportCache struct {
+	cmd redis.Cmdable
+	// 任务和文件保留多久
+	expiration time.Duration
+}
+
+func NewUserExportCache(cmd redis.Cmdable) UserExportCache {
+	return &RedisUserExportCache{
+		cmd:        cmd,
+		expiration: time.Hour * 24,
+	}
+}
+
+func (c *RedisUserExportCache) taskKey(id string) string {
+	return fmt.Sprintf("user:export:task:%s", id)
+}
+
+func (c *RedisUserExportCache) latestKey(uid int64) string {
+	return fmt.Sprintf("user:export:latest:%d", uid)
+}
+
+func (c *RedisUserExportCache) archiveKey(id string) string {
+	return fmt.Sprintf("user:export:archive:%s", id)
+}
+
+func (c *RedisUserExportCache) SetTask(ctx context.Context, t domain.UserExport) error {
+	val, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	pipe := c.cmd.TxPipeline()
+	pipe.Set(ctx, c.taskKey(t.Id), val, c.expiration)
+	pipe.Set(ctx, c.latestKey(t.Uid), t.Id, c.expiration)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (c *RedisUserExportCache) GetTask(ctx context.Context, id string) (domain.UserExport, error) {
+	val, err := c.cmd.Get(ctx, c.taskKey(id)).Bytes()
+	if err != nil {
+		return domain.UserExport{}, err
+	}
+	var t domain.UserExport
+	err = json.Unmarshal(val, &t)
+	return t, err
+}
+
+func (c *RedisUserExportCache) GetLatest(ctx context.Context, uid int64) (domain.UserExport, error) {
+	id, err := c.cmd.Get(ctx, c.latestKey(uid)).Result()
+	if err != nil {
+		return domain.UserExport{}, err
+	}
+	return c.GetTask(ctx, id)
+}
+
+func (c *RedisUserExportCache) SetArchive(ctx context.Context, id string, data []byte) error {
+	return c.cmd.Set(ctx, c.archiveKey(id), data, c.expiration).Err()
+}
+
+func (c *RedisUserExportCache) GetArchive(ctx context.Context, id string) ([]byte, error) {
+	return c.cmd.Get(ctx, c.archiveKey(id)).Bytes()
+}
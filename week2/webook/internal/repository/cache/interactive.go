@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+//go:embed lua/incr_cnt.lua
+var luaIncrCnt string
+
+// hash 里的字段名
+const (
+	fieldReadCnt    = "read_cnt"
+	fieldLikeCnt    = "like_cnt"
+	fieldCollectCnt = "collect_cnt"
+)
+
+// InteractiveCache 阅读、点赞、收藏数，每个业务对象一个 hash。
+// 计数改了不删缓存，直接在缓存上加减，热门文章一直点赞也不会一直打数据库
+type InteractiveCache interface {
+	// IncrReadCntIfPresent 缓存里有才加，没有的话什么也不做
+	IncrReadCntIfPresent(ctx context.Context, biz string, bizId int64) error
+	// IncrLikeCntIfPresent delta 是 -1 就是取消点赞
+	IncrLikeCntIfPresent(ctx context.Context, biz string, bizId int64, delta int64) error
+	IncrCollectCntIfPresent(ctx context.Context, biz string, bizId int64, delta int64) error
+	// Get 缓存里没有返回 ErrKeyNotExist
+	Get(ctx context.Context, biz string, bizId int64) (domain.Interactive, error)
+	Set(ctx context.Context, intr domain.Interactive) error
+	// GetByIds 只返回缓存里有的
+	GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error)
+	SetBatch(ctx context.Context, intrs []domain.Interactive) error
+}
+
+type RedisInteractiveCache struct {
+	cmd redis.Cmdable
+	// 过期之前计数一直在缓存上加减，过期了从数据库重新加载，顺便纠正并发导致的误差
+	expiration time.Duration
+}
+
+func NewInteractiveCache(cmd redis.Cmdable) InteractiveCache {
+	return &RedisInteractiveCache{
+		cmd:        cmd,
+		expiration: time.Minute * 15,
+	}
+}
+
+func (c *RedisInteractiveCache) key(biz string, bizId int64) string {
+	return fmt.Sprintf("interactive:%s:%d", biz, bizId)
+}
+
+func (c *RedisInteractiveCache) IncrReadCntIfPresent(ctx context.Context, biz string, bizId int64) error {
+	return c.incr(ctx, biz, bizId, fieldReadCnt, 1)
+}
+
+func (c *RedisInteractiveCache) IncrLikeCntIfPresent(ctx context.Context, biz string, bizId int64, delta int64) error {
+	return c.incr(ctx, biz, bizId, fieldLikeCnt, delta)
+}
+
+func (c *RedisInteractiveCache) IncrCollectCntIfPresent(ctx context.Context, biz string, bizId int64, delta int64) error {
+	return c.incr(ctx, biz, bizId, fieldCollectCnt, delta)
+}
+
+func (c *RedisInteractiveCache) incr(ctx context.Context, biz string, bizId int64, field string, delta int64) error {
+	// 返回 0 说明缓存里没有，不算出错
+	return c.cmd.Eval(ctx, luaIncrCnt, []string{c.key(biz, bizId)}, field, delta).Err()
+}
+
+func (c *RedisInteractiveCache) Get(ctx context.Context, biz string, bizId int64) (domain.Interactive, error) {
+	vals, err := c.cmd.HGetAll(ctx, c.key(biz, bizId)).Result()
+	if err != nil {
+		return domain.Interactive{}, err
+	}
+	if len(vals) == 0 {
+		return domain.Interactive{}, ErrKeyNotExist
+	}
+	return c.toDomain(biz, bizId, vals), nil
+}
+
+func (c *RedisInteractiveCache) Set(ctx context.Context, intr domain.Interactive) error {
+	return c.SetBatch(ctx, []domain.Interactive{intr})
+}
+
+func (c *RedisInteractiveCache) GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error) {
+	res := make(map[int64]domain.Interactive, len(bizIds))
+	if len(bizIds) == 0 {
+		return res, nil
+	}
+	pipe := c.cmd.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, 0, len(bizIds))
+	for _, id := range bizIds {
+		cmds = append(cmds, pipe.HGetAll(ctx, c.key(biz, id)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		if vals := cmd.Val(); len(vals) > 0 {
+			res[bizIds[i]] = c.toDomain(biz, bizIds[i], vals)
+		}
+	}
+	return res, nil
+}
+
+func (c *RedisInteractiveCache) SetBatch(ctx context.Context, intrs []domain.Interactive) error {
+	if len(intrs) == 0 {
+		return nil
+	}
+	pipe := c.cmd.Pipeline()
+	for _, intr := range intrs {
+		key := c.key(intr.Biz, intr.BizId)
+		pipe.HSet(ctx, key,
+			fieldReadCnt, intr.ReadCnt,
+			fieldLikeCnt, intr.LikeCnt,
+			fieldCollectCnt, intr.CollectCnt)
+		pipe.Expire(ctx, key, c.expiration)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *RedisInteractiveCache) toDomain(biz string, bizId int64, vals map[string]string) domain.Interactive {
+	// 解析不了的当成 0，过期之后会从数据库重新加载
+	readCnt, _ := strconv.ParseInt(vals[fieldReadCnt], 10, 64)
+	likeCnt, _ := strconv.ParseInt(vals[fieldLikeCnt], 10, 64)
+	collectCnt, _ := strconv.ParseInt(vals[fieldCollectCnt], 10, 64)
+	return domain.Interactive{
+		Biz:        biz,
+		BizId:      bizId,
+		ReadCnt:    readCnt,
+		LikeCnt:    likeCnt,
+		CollectCnt: collectCnt,
+	}
+}
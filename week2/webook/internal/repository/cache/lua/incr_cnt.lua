@@ -0,0 +1,11 @@
+-- 只有缓存里有这个 key 的时候才加，没有的话等下次查询的时候从数据库加载完整的数据
+-- 不然会创建出只有一个字段的 hash，别的计数就变成 0 了
+local key = KEYS[1]
+local field = ARGV[1]
+local delta = tonumber(ARGV[2])
+if redis.call("exists", key) == 1 then
+    redis.call("hincrby", key, field, delta)
+    return 1
+else
+    return 0
+end
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// SessionCache 用户的登录态（ssid）和登录历史。
+// note key 的格式要和 web/jwt.RedisJWTHandler 保持一致：登录的时候它把这次登录记到 users:sessions:{uid} 和 users:logins:{uid}，
+// 退出的时候把 ssid 写进黑名单 users:ssid:{ssid}
+type SessionCache interface {
+	// RevokeAll 让这个用户所有的 ssid 都失效，返回失效了几个
+	RevokeAll(ctx context.Context, uid int64) (int, error)
+	// List 这个用户还有效的登录，已经退出的不算
+	List(ctx context.Context, uid int64) ([]domain.Session, error)
+	// LoginHistory 最近的登录历史，最新的在前面
+	LoginHistory(ctx context.Context, uid int64) ([]domain.Session, error)
+}
+
+type RedisSessionCache struct {
+	cmd redis.Cmdable
+	// 黑名单的过期时间，要和 refresh_token 的有效期一样长
+	expiration time.Duration
+}
+
+func NewSessionCache(cmd redis.Cmdable) SessionCache {
+	return &RedisSessionCache{
+		cmd:        cmd,
+		expiration: time.Hour * 24 * 7,
+	}
+}
+
+func (c *RedisSessionCache) sessionsKey(uid int64) string {
+	return fmt.Sprintf("users:sessions:%d", uid)
+}
+
+func (c *RedisSessionCache) blacklistKey(ssid string) string {
+	return fmt.Sprintf("users:ssid:%s", ssid)
+}
+
+func (c *RedisSessionCache) RevokeAll(ctx context.Context, uid int64) (int, error) {
+	key := c.sessionsKey(uid)
+	ssids, err := c.cmd.HKeys(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(ssids) == 0 {
+		return 0, nil
+	}
+	pipe := c.cmd.Pipeline()
+	for _, ssid := range ssids {
+		pipe.Set(ctx, c.blacklistKey(ssid), "", c.expiration)
+	}
+	// 只删拿到的这些，中间新登录的不受影响
+	pipe.HDel(ctx, key, ssids...)
+	_, err = pipe.Exec(ctx)
+	return len(ssids), err
+}
+
+func (c *RedisSessionCache) List(ctx context.Context, uid int64) ([]domain.Session, error) {
+	vals, err := c.cmd.HVals(ctx, c.sessionsKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := c.decode(vals)
+	if err != nil || len(sessions) == 0 {
+		return sessions, err
+	}
+	// 退出登录只是把 ssid 加进黑名单，没有从 users:sessions 里删
+	pipe := c.cmd.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(sessions))
+	for _, s := range sessions {
+		cmds = append(cmds, pipe.Exists(ctx, c.blacklistKey(s.Ssid)))
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(-c.expiration)
+	res := make([]domain.Session, 0, len(sessions))
+	for i, s := range sessions {
+		if cmds[i].Val() > 0 || s.LoginAt.Before(deadline) {
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func (c *RedisSessionCache) LoginHistory(ctx context.Context, uid int64) ([]domain.Session, error) {
+	vals, err := c.cmd.LRange(ctx, fmt.Sprintf("users:logins:%d", uid), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(vals)
+}
+
+func (c *RedisSessionCache) decode(vals []string) ([]domain.Session, error) {
+	res := make([]domain.Session, 0, len(vals))
+	for _, val := range vals {
+		var s domain.Session
+		if err := json.Unmarshal([]byte(val), &s); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
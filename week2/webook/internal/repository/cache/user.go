@@ -2,43 +2,116 @@ package cache
 
 import (
 	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/pkg/codec"
 	"context"
-	"encoding/json"
+	_ "embed"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"math/rand"
 	"time"
 )
 
-var ErrKeyNotExist = redis.Nil
+var (
+	//go:embed lua/unlock.lua
+	luaUnlock string
+
+	ErrKeyNotExist = redis.Nil
+	// ErrUserNotFound 缓存里记着这个用户不存在（负缓存），不用再查数据库了
+	ErrUserNotFound = errors.New("缓存中记录了用户不存在")
+	// ErrLockHeld 重建缓存的锁被别人拿着
+	ErrLockHeld = errors.New("重建缓存的锁被占用")
+)
+
+// notFoundVal 负缓存存的值，正常的用户是 codec.Encode 编码的，开头是 magic，不会和它冲突
+const notFoundVal = "null"
+
+// userSchemaVersion cachedUser 的结构改了（加减字段、改类型）就加一，
+// 发布过程中新旧版本的服务读到对方写的缓存都只会当成没命中
+const userSchemaVersion byte = 4
 
 type UserCache interface {
+	// Get 缓存里没有返回 ErrKeyNotExist，记着用户不存在返回 ErrUserNotFound
 	Get(ctx context.Context, uid int64) (domain.User, error)
 	Set(ctx context.Context, du domain.User) error
+	// SetNotFound 记下这个用户不存在，过期时间很短，防止有人用不存在的 id 一直打数据库（缓存穿透）
+	SetNotFound(ctx context.Context, uid int64) error
+	Delete(ctx context.Context, uid int64) error
+	// Lock 抢重建这个用户缓存的分布式锁，抢到了返回 token，解锁的时候要带上；被别人抢了返回 ErrLockHeld
+	Lock(ctx context.Context, uid int64) (string, error)
+	Unlock(ctx context.Context, uid int64, token string) error
 }
 
 type RedisUserCache struct {
 	cmd        redis.Cmdable
 	expiration time.Duration
+	// 负缓存的过期时间，要短，不然新注册的用户可能一段时间内查不到
+	notFoundExpiration time.Duration
+	// 过期时间再随机加上 [0, jitter)，防止同一时间写进去的 key 同时过期（缓存雪崩）
+	jitter time.Duration
+	// 锁的过期时间，持有锁的实例挂了，最多这么久别人就能拿到锁
+	lockExpiration time.Duration
+	// 写缓存用的序列化方式，读的时候按数据里记录的来，所以可以随时切换
+	codec codec.Codec
 }
 
 func NewUserCache(cmd redis.Cmdable) UserCache {
+	return NewRedisUserCache(cmd)
+}
+
+// NewRedisUserCache 和 NewUserCache 一样，只是返回具体类型，方便修改过期时间
+func NewRedisUserCache(cmd redis.Cmdable) *RedisUserCache {
 	return &RedisUserCache{
-		cmd:        cmd,
-		expiration: time.Minute * 15,
+		cmd:                cmd,
+		expiration:         time.Minute * 15,
+		notFoundExpiration: time.Minute,
+		jitter:             time.Minute * 3,
+		lockExpiration:     time.Second * 3,
+		codec:              codec.Msgpack,
 	}
 }
 
+func (c *RedisUserCache) Expiration(expiration time.Duration) *RedisUserCache {
+	c.expiration = expiration
+	return c
+}
+
+func (c *RedisUserCache) NotFoundExpiration(expiration time.Duration) *RedisUserCache {
+	c.notFoundExpiration = expiration
+	return c
+}
+
+func (c *RedisUserCache) Jitter(jitter time.Duration) *RedisUserCache {
+	c.jitter = jitter
+	return c
+}
+
+func (c *RedisUserCache) Codec(cc codec.Codec) *RedisUserCache {
+	c.codec = cc
+	return c
+}
+
 func (c *RedisUserCache) Get(ctx context.Context, uid int64) (domain.User, error) {
 	key := c.Key(uid)
-	// 假定用JSON来存储val
-	val, err := c.cmd.Get(ctx, key).Result()
+	val, err := c.cmd.Get(ctx, key).Bytes()
 	if err != nil {
 		return domain.User{}, err
 	}
-	var u domain.User
+	if string(val) == notFoundVal {
+		return domain.User{}, ErrUserNotFound
+	}
+	var cu cachedUser
 	// 反序列化
-	err = json.Unmarshal([]byte(val), &u)
-	return u, err
+	err = codec.Decode(val, userSchemaVersion, &cu)
+	if errors.Is(err, codec.ErrVersionMismatch) || errors.Is(err, codec.ErrInvalidEnvelope) {
+		// 别的版本写的，或者是升级之前的 JSON，当成没命中，重建的时候会覆盖掉
+		return domain.User{}, ErrKeyNotExist
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	return cu.toDomain(), nil
 }
 
 func (c *RedisUserCache) Key(uid int64) string {
@@ -48,10 +121,150 @@ func (c *RedisUserCache) Key(uid int64) string {
 
 func (c *RedisUserCache) Set(ctx context.Context, du domain.User) error {
 	key := c.Key(du.Id)
-	// 序列化
-	val, err := json.Marshal(du)
+	// 序列化，只存 cachedUser 里的字段
+	val, err := codec.Encode(c.codec, userSchemaVersion, newCachedUser(du))
 	if err != nil {
 		return err
 	}
-	return c.cmd.Set(ctx, key, val, c.expiration).Err()
+	return c.cmd.Set(ctx, key, val, c.withJitter(c.expiration)).Err()
+}
+
+func (c *RedisUserCache) SetNotFound(ctx context.Context, uid int64) error {
+	// 负缓存本来就短，抖动也小一点，最多多 20%
+	return c.cmd.Set(ctx, c.Key(uid), notFoundVal,
+		c.notFoundExpiration+c.randDuration(c.notFoundExpiration/5)).Err()
+}
+
+func (c *RedisUserCache) Delete(ctx context.Context, uid int64) error {
+	return c.cmd.Del(ctx, c.Key(uid)).Err()
+}
+
+func (c *RedisUserCache) lockKey(uid int64) string {
+	return fmt.Sprintf("user:info:lock:%d", uid)
+}
+
+func (c *RedisUserCache) Lock(ctx context.Context, uid int64) (string, error) {
+	// 每次加锁用不同的 token，解锁的时候才能确认锁还是自己的
+	token := uuid.New().String()
+	ok, err := c.cmd.SetNX(ctx, c.lockKey(uid), token, c.lockExpiration).Result()
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrLockHeld
+	}
+	return token, nil
+}
+
+func (c *RedisUserCache) Unlock(ctx context.Context, uid int64, token string) error {
+	return c.cmd.Eval(ctx, luaUnlock, []string{c.lockKey(uid)}, token).Err()
+}
+
+func (c *RedisUserCache) withJitter(expiration time.Duration) time.Duration {
+	return expiration + c.randDuration(c.jitter)
+}
+
+func (c *RedisUserCache) randDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// cachedUser 存进 redis 的用户，只放展示需要的字段，密码、微信凭证这些敏感的字段不能放进来
+// note 改了这里的字段一定要把 userSchemaVersion 加一
+type cachedUser struct {
+	Id       int64  `json:"id" msgpack:"id"`
+	Email    string `json:"email" msgpack:"email"`
+	Phone    string `json:"phone" msgpack:"phone"`
+	Nickname string `json:"nickname" msgpack:"nickname"`
+	// UTC 0 的毫秒数
+	Birthday      int64  `json:"birthday" msgpack:"birthday"`
+	Resume        string `json:"resume" msgpack:"resume"`
+	Avatar        string `json:"avatar" msgpack:"avatar"`
+	Gender        uint8  `json:"gender" msgpack:"gender"`
+	Location      string `json:"location" msgpack:"location"`
+	Website       string `json:"website" msgpack:"website"`
+	Locale        string `json:"locale" msgpack:"locale"`
+	Handle        string `json:"handle" msgpack:"handle"`
+	HandleUtime   int64  `json:"handleUtime" msgpack:"handle_utime"`
+	HideBirthday  bool   `json:"hideBirthday" msgpack:"hide_birthday"`
+	HideResume    bool   `json:"hideResume" msgpack:"hide_resume"`
+	Ctime         int64  `json:"ctime" msgpack:"ctime"`
+	Utime         int64  `json:"utime" msgpack:"utime"`
+	WechatOpenId  string `json:"wechatOpenId" msgpack:"wechat_open_id"`
+	WechatUnionId string `json:"wechatUnionId" msgpack:"wechat_union_id"`
+	Status        uint8  `json:"status" msgpack:"status"`
+	DeactivatedAt int64  `json:"deactivatedAt" msgpack:"deactivated_at"`
+}
+
+func newCachedUser(u domain.User) cachedUser {
+	return cachedUser{
+		Id:            u.Id,
+		Email:         u.Email,
+		Phone:         u.Phone,
+		Nickname:      u.Nickname,
+		Birthday:      u.Birthday.UnixMilli(),
+		Resume:        u.Resume,
+		Avatar:        u.Avatar,
+		Gender:        uint8(u.Gender),
+		Location:      u.Location,
+		Website:       u.Website,
+		Locale:        u.Locale,
+		Handle:        u.Handle,
+		HandleUtime:   timeToMilli(u.HandleUtime),
+		HideBirthday:  u.Privacy.HideBirthday,
+		HideResume:    u.Privacy.HideResume,
+		Ctime:         u.Ctime.UnixMilli(),
+		Utime:         timeToMilli(u.Utime),
+		WechatOpenId:  u.WechatInfo.OpenId,
+		WechatUnionId: u.WechatInfo.UnionId,
+		Status:        uint8(u.Status),
+		DeactivatedAt: timeToMilli(u.DeactivatedAt),
+	}
+}
+
+func (cu cachedUser) toDomain() domain.User {
+	return domain.User{
+		Id:          cu.Id,
+		Email:       cu.Email,
+		Phone:       cu.Phone,
+		Nickname:    cu.Nickname,
+		Birthday:    time.UnixMilli(cu.Birthday),
+		Resume:      cu.Resume,
+		Avatar:      cu.Avatar,
+		Gender:      domain.Gender(cu.Gender),
+		Location:    cu.Location,
+		Website:     cu.Website,
+		Locale:      cu.Locale,
+		Handle:      cu.Handle,
+		HandleUtime: milliToTime(cu.HandleUtime),
+		Privacy: domain.UserPrivacy{
+			HideBirthday: cu.HideBirthday,
+			HideResume:   cu.HideResume,
+		},
+		Ctime: time.UnixMilli(cu.Ctime),
+		Utime: milliToTime(cu.Utime),
+		WechatInfo: domain.WechatInfo{
+			OpenId:  cu.WechatOpenId,
+			UnionId: cu.WechatUnionId,
+		},
+		Status:        domain.UserStatus(cu.Status),
+		DeactivatedAt: milliToTime(cu.DeactivatedAt),
+	}
+}
+
+// timeToMilli 零值存成 0，不然 time.Time{}.UnixMilli() 是个很大的负数
+func timeToMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func milliToTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
 }
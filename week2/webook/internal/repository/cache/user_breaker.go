@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/pkg/breaker"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrCacheUnavailable redis 出错了或者已经熔断，调用方应该走降级逻辑，而不是当成缓存没命中
+var ErrCacheUnavailable = errors.New("用户缓存不可用")
+
+// BreakerUserCache 给 UserCache 加上熔断：redis 连续出错之后一段时间内不再访问 redis，
+// 直接返回 ErrCacheUnavailable，避免每个请求都卡在 redis 的超时上
+type BreakerUserCache struct {
+	cache   UserCache
+	breaker *breaker.Breaker
+}
+
+func NewBreakerUserCache(c UserCache, b *breaker.Breaker) UserCache {
+	return &BreakerUserCache{
+		cache:   c,
+		breaker: b,
+	}
+}
+
+func (c *BreakerUserCache) Get(ctx context.Context, uid int64) (domain.User, error) {
+	var u domain.User
+	err := c.call(func() error {
+		var err error
+		u, err = c.cache.Get(ctx, uid)
+		return err
+	})
+	return u, err
+}
+
+func (c *BreakerUserCache) Set(ctx context.Context, du domain.User) error {
+	return c.call(func() error {
+		return c.cache.Set(ctx, du)
+	})
+}
+
+func (c *BreakerUserCache) SetNotFound(ctx context.Context, uid int64) error {
+	return c.call(func() error {
+		return c.cache.SetNotFound(ctx, uid)
+	})
+}
+
+func (c *BreakerUserCache) Delete(ctx context.Context, uid int64) error {
+	return c.call(func() error {
+		return c.cache.Delete(ctx, uid)
+	})
+}
+
+func (c *BreakerUserCache) Lock(ctx context.Context, uid int64) (string, error) {
+	var token string
+	err := c.call(func() error {
+		var err error
+		token, err = c.cache.Lock(ctx, uid)
+		return err
+	})
+	return token, err
+}
+
+func (c *BreakerUserCache) Unlock(ctx context.Context, uid int64, token string) error {
+	return c.call(func() error {
+		return c.cache.Unlock(ctx, uid, token)
+	})
+}
+
+func (c *BreakerUserCache) call(fn func() error) error {
+	if !c.breaker.Allow() {
+		return ErrCacheUnavailable
+	}
+	err := fn()
+	switch {
+	case err == nil,
+		// 下面这些是业务上的结果，说明 redis 是好的
+		errors.Is(err, ErrKeyNotExist),
+		errors.Is(err, ErrUserNotFound),
+		errors.Is(err, ErrLockHeld):
+		c.breaker.Success()
+		return err
+	case errors.Is(err, context.Canceled):
+		// 调用方自己取消的，不能算 redis 的错，也不能当成 redis 恢复了
+		c.breaker.Ignore()
+		return err
+	default:
+		c.breaker.Failure()
+		return fmt.Errorf("%w: %v", ErrCacheUnavailable, err)
+	}
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	lru "github.com/hashicorp/golang-lru"
+	"time"
+)
+
+// LocalUserCache 进程内的用户缓存，是 MultiLevelUserCache 的一级缓存，先读它，没有再读 redis。
+// redis 不可用的时候也还能读到，过期时间要比 redis 的短，靠发布订阅和过期时间失效
+// lru.Cache 本身是并发安全的，所以这里不用再加锁
+type LocalUserCache struct {
+	cache      *lru.Cache
+	expiration time.Duration
+}
+
+func NewLocalUserCache(size int, expiration time.Duration) *LocalUserCache {
+	c, err := lru.New(size)
+	if err != nil {
+		// 只有 size <= 0 才会出错，说明代码写错了
+		panic(err)
+	}
+	return &LocalUserCache{
+		cache:      c,
+		expiration: expiration,
+	}
+}
+
+func (l *LocalUserCache) Get(uid int64) (domain.User, bool) {
+	val, ok := l.cache.Get(uid)
+	if !ok {
+		return domain.User{}, false
+	}
+	itm, ok := val.(userItem)
+	if !ok || time.Now().After(itm.expire) {
+		return domain.User{}, false
+	}
+	return itm.user, true
+}
+
+func (l *LocalUserCache) Set(du domain.User) {
+	l.cache.Add(du.Id, userItem{
+		user:   du,
+		expire: time.Now().Add(l.expiration),
+	})
+}
+
+func (l *LocalUserCache) Delete(uid int64) {
+	l.cache.Remove(uid)
+}
+
+type userItem struct {
+	user domain.User
+	// 用的 lru 没有过期时间，自己维护一个
+	expire time.Time
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"strconv"
+)
+
+// MultiLevelUserCache 两级缓存：进程内的 LRU 在前，redis 在后
+// 本地缓存的失效靠 redis 的发布订阅：一个实例删了用户缓存，就往 channel 里发 uid，
+// 所有实例（包括自己）收到之后删掉本地的。发布订阅不保证送达，所以本地缓存的过期时间要比 redis 的短
+type MultiLevelUserCache struct {
+	local  *LocalUserCache
+	remote UserCache
+	// 用来发布和订阅失效消息
+	client  redis.UniversalClient
+	channel string
+}
+
+func NewMultiLevelUserCache(local *LocalUserCache, remote UserCache,
+	client redis.UniversalClient, channel string) *MultiLevelUserCache {
+	return &MultiLevelUserCache{
+		local:   local,
+		remote:  remote,
+		client:  client,
+		channel: channel,
+	}
+}
+
+func (c *MultiLevelUserCache) Get(ctx context.Context, uid int64) (domain.User, error) {
+	if u, ok := c.local.Get(uid); ok {
+		return u, nil
+	}
+	u, err := c.remote.Get(ctx, uid)
+	if err != nil {
+		return domain.User{}, err
+	}
+	c.local.Set(u)
+	return u, nil
+}
+
+// Set 先写本地，redis 不可用的时候本地缓存还能用
+func (c *MultiLevelUserCache) Set(ctx context.Context, du domain.User) error {
+	c.local.Set(du)
+	return c.remote.Set(ctx, du)
+}
+
+func (c *MultiLevelUserCache) SetNotFound(ctx context.Context, uid int64) error {
+	c.local.Delete(uid)
+	return c.remote.SetNotFound(ctx, uid)
+}
+
+func (c *MultiLevelUserCache) Delete(ctx context.Context, uid int64) error {
+	c.local.Delete(uid)
+	err := c.remote.Delete(ctx, uid)
+	if errors.Is(err, ErrCacheUnavailable) {
+		// redis 出错了或者熔断了，发布也发不出去，不用再等一次超时，其它实例只能等本地缓存过期
+		return err
+	}
+	// 通知其它实例，发失败了只能等它们的本地缓存过期
+	if perr := c.client.Publish(ctx, c.channel, uid).Err(); perr != nil {
+		log.Println("发布用户缓存失效消息失败", uid, perr)
+	}
+	return err
+}
+
+func (c *MultiLevelUserCache) Lock(ctx context.Context, uid int64) (string, error) {
+	return c.remote.Lock(ctx, uid)
+}
+
+func (c *MultiLevelUserCache) Unlock(ctx context.Context, uid int64, token string) error {
+	return c.remote.Unlock(ctx, uid, token)
+}
+
+// Subscribe 开始监听失效消息，ctx 取消之后停止
+// note go-redis 断线之后会自动重连并重新订阅，但断线期间的消息就丢了
+func (c *MultiLevelUserCache) Subscribe(ctx context.Context) {
+	ps := c.client.Subscribe(ctx, c.channel)
+	go func() {
+		defer ps.Close()
+		ch := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				c.onInvalidate(msg.Payload)
+			}
+		}
+	}()
+}
+
+func (c *MultiLevelUserCache) onInvalidate(payload string) {
+	uid, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		log.Println("非法的用户缓存失效消息", payload)
+		return
+	}
+	c.local.Delete(uid)
+}
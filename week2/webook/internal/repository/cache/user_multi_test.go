@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// fakeRemoteCache 模拟 redis 那一级，记录 Get 被调了几次
+type fakeRemoteCache struct {
+	UserCache
+	users  map[int64]domain.User
+	gets   int
+	setErr error
+	delErr error
+}
+
+func (f *fakeRemoteCache) Get(ctx context.Context, uid int64) (domain.User, error) {
+	f.gets++
+	u, ok := f.users[uid]
+	if !ok {
+		return domain.User{}, ErrKeyNotExist
+	}
+	return u, nil
+}
+
+func (f *fakeRemoteCache) Set(ctx context.Context, du domain.User) error {
+	return f.setErr
+}
+
+func (f *fakeRemoteCache) Delete(ctx context.Context, uid int64) error {
+	delete(f.users, uid)
+	return f.delErr
+}
+
+// publishCounter 记录往 redis 发了几次命令
+type publishCounter struct {
+	cnt int
+}
+
+func (p *publishCounter) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (p *publishCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "publish" {
+			p.cnt++
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (p *publishCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestMultiLevelUserCache(t *testing.T) {
+	remote := &fakeRemoteCache{users: map[int64]domain.User{1: {Id: 1, Nickname: "redis"}}}
+	// 连不上的 redis，发布失效消息会失败，但不影响删除
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	c := NewMultiLevelUserCache(NewLocalUserCache(10, time.Minute), remote, client, "test")
+	ctx := context.Background()
+
+	// 第一次从 redis 拿，第二次从本地拿
+	for i := 0; i < 2; i++ {
+		u, err := c.Get(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "redis", u.Nickname)
+	}
+	assert.Equal(t, 1, remote.gets)
+
+	// 删除之后本地和 redis 都没了
+	assert.NoError(t, c.Delete(ctx, 1))
+	_, err := c.Get(ctx, 1)
+	assert.Equal(t, ErrKeyNotExist, err)
+
+	// redis 写失败了，本地还是有
+	remote.setErr = errors.New("redis 挂了")
+	assert.Error(t, c.Set(ctx, domain.User{Id: 2, Nickname: "本地"}))
+	u, err := c.Get(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "本地", u.Nickname)
+
+	// 收到别的实例发来的失效消息，删掉本地的
+	c.onInvalidate("2")
+	_, err = c.Get(ctx, 2)
+	assert.Equal(t, ErrKeyNotExist, err)
+}
+
+func TestMultiLevelUserCache_DeleteUnavailable(t *testing.T) {
+	remote := &fakeRemoteCache{users: map[int64]domain.User{}}
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	counter := &publishCounter{}
+	client.AddHook(counter)
+	c := NewMultiLevelUserCache(NewLocalUserCache(10, time.Minute), remote, client, "test")
+	ctx := context.Background()
+	c.local.Set(domain.User{Id: 1})
+
+	// redis 是好的，删完要通知其它实例
+	assert.NoError(t, c.Delete(ctx, 1))
+	assert.Equal(t, 1, counter.cnt)
+
+	// 熔断了，本地的照样删，但是不发布
+	c.local.Set(domain.User{Id: 1})
+	remote.delErr = ErrCacheUnavailable
+	assert.Equal(t, ErrCacheUnavailable, c.Delete(ctx, 1))
+	assert.Equal(t, 1, counter.cnt)
+	_, ok := c.local.Get(1)
+	assert.False(t, ok)
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/pkg/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCachedUser(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	u := domain.User{
+		Id:       1,
+		Email:    "a@qq.com",
+		Password: "$2a$10$secret-hash",
+		Nickname: "Tom",
+		Birthday: now,
+		Ctime:    now,
+		WechatInfo: domain.WechatInfo{
+			OpenId: "open-1",
+			Token:  domain.WechatToken{AccessToken: "access-token"},
+		},
+	}
+	for _, c := range []codec.Codec{codec.JSON, codec.Msgpack} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := codec.Encode(c, userSchemaVersion, newCachedUser(u))
+			require.NoError(t, err)
+			// 密码和微信凭证不会写进缓存
+			assert.NotContains(t, string(data), "secret-hash")
+			assert.NotContains(t, string(data), "access-token")
+
+			var cu cachedUser
+			require.NoError(t, codec.Decode(data, userSchemaVersion, &cu))
+			want := u
+			want.Password = ""
+			want.WechatInfo.Token = domain.WechatToken{}
+			assert.Equal(t, want, cu.toDomain())
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrArticleNotFound 文章不存在，或者不是这个作者的。两种情况不区分，不能让人试出别人的文章 id
+var ErrArticleNotFound = errors.New("文章不存在")
+
+type ArticleDao interface {
+	// Insert 新建草稿，返回文章的 id
+	Insert(ctx context.Context, art Article) (int64, error)
+	// UpdateById 改草稿，id 和作者对不上返回 ErrArticleNotFound
+	UpdateById(ctx context.Context, art Article) error
+	// Sync 保存草稿并同步到线上库，在一个事务里。返回文章的 id
+	Sync(ctx context.Context, art Article) (int64, error)
+	// SyncStatus 同时改草稿和线上库的状态，比如撤回
+	SyncStatus(ctx context.Context, uid int64, id int64, status uint8) error
+	// GetByAuthor 作者自己的草稿，最近改过的在前面
+	GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]Article, error)
+	GetById(ctx context.Context, id int64) (Article, error)
+	// GetPubById 已经发表过的文章，包括撤回了的
+	GetPubById(ctx context.Context, id int64) (PublishedArticle, error)
+	// ListPubByAuthor 作者发表的文章，不包括撤回了的，按 utime、id 倒序，
+	// 从 (utime, id) 的下一篇开始取，id 是 0 表示从头开始。Content 只取前 contentLen 个字
+	ListPubByAuthor(ctx context.Context, uid int64, utime int64, id int64, limit int, contentLen int) ([]PublishedArticle, error)
+}
+
+type GORMArticleDao struct {
+	db *gorm.DB
+}
+
+func NewArticleDao(db *gorm.DB) ArticleDao {
+	return &GORMArticleDao{
+		db: db,
+	}
+}
+
+func (dao *GORMArticleDao) Insert(ctx context.Context, art Article) (int64, error) {
+	now := nowMilli(ctx)
+	art.Ctime = now
+	art.Utime = now
+	err := withCtx(ctx, dao.db).Create(&art).Error
+	return art.Id, err
+}
+
+func (dao *GORMArticleDao) UpdateById(ctx context.Context, art Article) error {
+	// 带上作者 id，别人的文章更新不到
+	res := withCtx(ctx, dao.db).Model(&Article{}).
+		Where("id=? AND author_id=?", art.Id, art.AuthorId).
+		Updates(map[string]any{
+			"title":   art.Title,
+			"content": art.Content,
+			"status":  art.Status,
+			"utime":   nowMilli(ctx),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrArticleNotFound
+	}
+	return nil
+}
+
+func (dao *GORMArticleDao) Sync(ctx context.Context, art Article) (int64, error) {
+	// 两边用同一个时间，线上库的 utime 和草稿的一样
+	ctx = withNow(ctx, nowMilli(ctx))
+	now := nowMilli(ctx)
+	id := art.Id
+	err := withCtx(ctx, dao.db).Transaction(func(tx *gorm.DB) error {
+		txDao := NewArticleDao(tx)
+		var err error
+		if id > 0 {
+			err = txDao.UpdateById(ctx, art)
+		} else {
+			id, err = txDao.Insert(ctx, art)
+		}
+		if err != nil {
+			return err
+		}
+		art.Id = id
+		pub := PublishedArticle(art)
+		pub.Ctime = now
+		pub.Utime = now
+		// 第一次发表插入，之后再发表覆盖，ctime 保留第一次发表的时间
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "id"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"title":   pub.Title,
+				"content": pub.Content,
+				"status":  pub.Status,
+				"utime":   now,
+			}),
+		}).Create(&pub).Error
+	})
+	return id, err
+}
+
+func (dao *GORMArticleDao) SyncStatus(ctx context.Context, uid int64, id int64, status uint8) error {
+	now := nowMilli(ctx)
+	return withCtx(ctx, dao.db).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Article{}).Where("id=? AND author_id=?", id, uid).
+			Updates(map[string]any{
+				"status": status,
+				"utime":  now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrArticleNotFound
+		}
+		// 没发表过的话线上库里没有，改不到也没关系
+		return tx.Model(&PublishedArticle{}).Where("id=? AND author_id=?", id, uid).
+			Updates(map[string]any{
+				"status": status,
+				"utime":  now,
+			}).Error
+	})
+}
+
+func (dao *GORMArticleDao) GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]Article, error) {
+	var res []Article
+	// 走 idx_articles_author_utime
+	err := withCtx(ctx, dao.db).Where("author_id=?", uid).
+		Order("utime DESC, id DESC").Offset(offset).Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMArticleDao) GetById(ctx context.Context, id int64) (Article, error) {
+	var art Article
+	err := withCtx(ctx, dao.db).Where("id=?", id).First(&art).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Article{}, ErrArticleNotFound
+	}
+	return art, err
+}
+
+func (dao *GORMArticleDao) GetPubById(ctx context.Context, id int64) (PublishedArticle, error) {
+	var art PublishedArticle
+	err := withCtx(ctx, dao.db).Where("id=?", id).First(&art).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return PublishedArticle{}, ErrArticleNotFound
+	}
+	return art, err
+}
+
+func (dao *GORMArticleDao) ListPubByAuthor(ctx context.Context, uid int64, utime int64, id int64,
+	limit int, contentLen int) ([]PublishedArticle, error) {
+	var res []PublishedArticle
+	// 列表只要摘要，不把整篇 longtext 读出来。SUBSTR 在 MySQL 和 SQLite 里都是按字符算的
+	db := withCtx(ctx, dao.db).
+		Select("id, title, author_id, status, ctime, utime, SUBSTR(content, 1, ?) AS content", contentLen).
+		// 走 idx_published_articles_author_utime
+		Where("author_id=? AND status=?", uid, ArticleStatusPublished)
+	if id > 0 {
+		db = db.Where("utime<? OR (utime=? AND id<?)", utime, utime, id)
+	}
+	err := db.Order("utime DESC, id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// ArticleStatusPublished 和 domain.ArticleStatusPublished 一样
+const ArticleStatusPublished uint8 = 2
+
+// Article 作者的草稿（制作库），作者的所有操作都在这张表上
+type Article struct {
+	Id      int64  `gorm:"primaryKey,autoIncrement"`
+	Title   string `gorm:"type:varchar(256)"`
+	Content string `gorm:"type:longtext"`
+	// 作者自己的列表按 author_id 查，按 utime 排序
+	AuthorId int64 `gorm:"index:idx_articles_author_utime,priority:1"`
+	// Status 和 domain.ArticleStatus 一一对应
+	Status uint8
+	Ctime  int64
+	Utime  int64 `gorm:"index:idx_articles_author_utime,priority:2"`
+}
+
+// PublishedArticle 发表出去的文章（线上库），id 和草稿的一样，结构也一样。
+// 读者只读这张表，作者改草稿不影响读者，重新发表才会覆盖
+type PublishedArticle Article
@@ -0,0 +1,109 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGORMArticleDao(t *testing.T) {
+	db := newTestDB(t)
+	dao := NewArticleDao(db)
+	ctx := context.Background()
+	findPublished := func(id int64) (PublishedArticle, error) {
+		var pub PublishedArticle
+		err := db.Where("id=?", id).First(&pub).Error
+		return pub, err
+	}
+
+	// 新建草稿，还没发表
+	id, err := dao.Insert(ctx, Article{Title: "草稿", Content: "内容", AuthorId: 1, Status: 1})
+	require.NoError(t, err)
+	_, err = findPublished(id)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	// 别人改不了
+	err = dao.UpdateById(ctx, Article{Id: id, Title: "改了", AuthorId: 2, Status: 1})
+	assert.Equal(t, ErrArticleNotFound, err)
+	_, err = dao.Sync(ctx, Article{Id: id, Title: "改了", AuthorId: 2, Status: 2})
+	assert.Equal(t, ErrArticleNotFound, err)
+	_, err = findPublished(id)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	// 发表
+	_, err = dao.Sync(ctx, Article{Id: id, Title: "标题", Content: "内容", AuthorId: 1, Status: 2})
+	require.NoError(t, err)
+	pub, err := findPublished(id)
+	require.NoError(t, err)
+	assert.Equal(t, "标题", pub.Title)
+	assert.Equal(t, uint8(2), pub.Status)
+
+	// 改草稿不影响已经发表的
+	require.NoError(t, dao.UpdateById(ctx, Article{Id: id, Title: "新标题", AuthorId: 1, Status: 1}))
+	pub, err = findPublished(id)
+	require.NoError(t, err)
+	assert.Equal(t, "标题", pub.Title)
+
+	// 撤回，两边都改
+	assert.Equal(t, ErrArticleNotFound, dao.SyncStatus(ctx, 2, id, 3))
+	require.NoError(t, dao.SyncStatus(ctx, 1, id, 3))
+	pub, err = findPublished(id)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(3), pub.Status)
+	art, err := dao.GetById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(3), art.Status)
+
+	// 直接发表一篇新的
+	id2, err := dao.Sync(ctx, Article{Title: "第二篇", Content: "内容", AuthorId: 1, Status: 2})
+	require.NoError(t, err)
+	assert.NotEqual(t, id, id2)
+	_, err = findPublished(id2)
+	assert.NoError(t, err)
+
+	arts, err := dao.GetByAuthor(ctx, 1, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, arts, 2)
+	arts, err = dao.GetByAuthor(ctx, 2, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, arts)
+	_, err = dao.GetById(ctx, id2+1)
+	assert.Equal(t, ErrArticleNotFound, err)
+}
+
+func TestGORMArticleDao_ListPubByAuthor(t *testing.T) {
+	db := newTestDB(t)
+	dao := NewArticleDao(db)
+	ctx := context.Background()
+
+	for i, content := range []string{strings.Repeat("长文", 100), "短文"} {
+		_, err := dao.Sync(ctx, Article{Title: "标题", Content: content, AuthorId: 1, Status: ArticleStatusPublished})
+		require.NoError(t, err)
+		// utime 是毫秒，拉开一点保证顺序
+		if i == 0 {
+			time.Sleep(time.Millisecond * 2)
+		}
+	}
+
+	pubs, err := dao.ListPubByAuthor(ctx, 1, 0, 0, 10, 5)
+	require.NoError(t, err)
+	require.Len(t, pubs, 2)
+	// 新的在前面，内容只取前几个字，按字符截不会截出半个汉字
+	assert.Equal(t, "短文", pubs[0].Content)
+	assert.Equal(t, "长文长文长", pubs[1].Content)
+	assert.Equal(t, "标题", pubs[1].Title)
+	assert.Equal(t, int64(1), pubs[1].AuthorId)
+	assert.Equal(t, ArticleStatusPublished, pubs[1].Status)
+	assert.NotZero(t, pubs[1].Ctime)
+	assert.NotZero(t, pubs[1].Utime)
+
+	// 从第一篇的下一篇开始
+	pubs, err = dao.ListPubByAuthor(ctx, 1, pubs[0].Utime, pubs[0].Id, 10, 5)
+	require.NoError(t, err)
+	require.Len(t, pubs, 1)
+	assert.Equal(t, "长文长文长", pubs[0].Content)
+}
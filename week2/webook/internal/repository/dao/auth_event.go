@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+type AuthEventDao interface {
+	// BatchInsert 一次插入多条，由异步写入的时候攒批调用
+	BatchInsert(ctx context.Context, events []AuthEvent) error
+	// FindByUid 最近的 limit 条，最新的在前面
+	FindByUid(ctx context.Context, uid int64, limit int) ([]AuthEvent, error)
+	// FindDevices 这个用户登录成功过的所有设备
+	FindDevices(ctx context.Context, uid int64) ([]string, error)
+	// DeleteByUid 清除注销账号的时候删掉这个用户的所有记录，里面有 IP 和 UA
+	DeleteByUid(ctx context.Context, uid int64) error
+}
+
+type GORMAuthEventDao struct {
+	db *gorm.DB
+}
+
+func NewAuthEventDao(db *gorm.DB) AuthEventDao {
+	return &GORMAuthEventDao{
+		db: db,
+	}
+}
+
+func (dao *GORMAuthEventDao) BatchInsert(ctx context.Context, events []AuthEvent) error {
+	// UA 是客户端随便传的，一批里是不同用户的记录，MySQL 严格模式下一条超长整批都插不进去，所以按列的长度截断
+	for i := range events {
+		e := &events[i]
+		e.Method = truncate(e.Method, 20)
+		e.Ip = truncate(e.Ip, 64)
+		e.UserAgent = truncate(e.UserAgent, 255)
+		e.Device = truncate(e.Device, 32)
+		e.Reason = truncate(e.Reason, 64)
+	}
+	return withCtx(ctx, dao.db).Create(&events).Error
+}
+
+func (dao *GORMAuthEventDao) FindByUid(ctx context.Context, uid int64, limit int) ([]AuthEvent, error) {
+	var res []AuthEvent
+	err := withCtx(ctx, dao.db).Where("uid=?", uid).
+		Order("ctime DESC, id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMAuthEventDao) FindDevices(ctx context.Context, uid int64) ([]string, error) {
+	var res []string
+	// 走 idx_auth_events_uid_device
+	err := withCtx(ctx, dao.db).Model(&AuthEvent{}).Distinct("device").
+		Where("uid=? AND success=? AND device<>''", uid, true).Pluck("device", &res).Error
+	return res, err
+}
+
+func (dao *GORMAuthEventDao) DeleteByUid(ctx context.Context, uid int64) error {
+	return withCtx(ctx, dao.db).Where("uid=?", uid).Delete(&AuthEvent{}).Error
+}
+
+// AuthEvent 认证的审计日志，只插入不修改，清除注销账号的时候整个删掉
+type AuthEvent struct {
+	Id        int64  `gorm:"primaryKey,autoIncrement"`
+	Uid       int64  `gorm:"index:idx_auth_events_uid_ctime,priority:1;index:idx_auth_events_uid_device,priority:1"`
+	Method    string `gorm:"type:varchar(20)"`
+	Ip        string `gorm:"type:varchar(64)"`
+	UserAgent string `gorm:"type:varchar(255)"`
+	Device    string `gorm:"type:varchar(32);index:idx_auth_events_uid_device,priority:2"`
+	Success   bool
+	Reason    string `gorm:"type:varchar(64)"`
+	NewDevice bool
+
+	Ctime int64 `gorm:"index:idx_auth_events_uid_ctime,priority:2"`
+}
@@ -0,0 +1,58 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGORMAuthEventDao(t *testing.T) {
+	db := newTestDB(t)
+	dao := NewAuthEventDao(db)
+	ctx := context.Background()
+
+	err := dao.BatchInsert(ctx, []AuthEvent{
+		{Uid: 1, Method: "password", Device: "a", Success: true, Ctime: 1},
+		{Uid: 1, Method: "password", Device: "b", Success: false, Reason: "user.invalid_credential", Ctime: 2},
+		{Uid: 1, Method: "sms", Device: "a", Success: true, Ctime: 3},
+		{Uid: 2, Method: "wechat", Device: "c", Success: true, Ctime: 4},
+	})
+	require.NoError(t, err)
+
+	events, err := dao.FindByUid(ctx, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, int64(3), events[0].Ctime)
+	assert.Equal(t, "user.invalid_credential", events[1].Reason)
+
+	// 失败的登录不算登录过的设备
+	devices, err := dao.FindDevices(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, devices)
+}
+
+// 超长的 UA 按字符截断，不能让同一批里别人的记录也插不进去
+func TestGORMAuthEventDao_BatchInsertTooLong(t *testing.T) {
+	db := newTestDB(t)
+	dao := NewAuthEventDao(db)
+	ctx := context.Background()
+
+	err := dao.BatchInsert(ctx, []AuthEvent{
+		{Uid: 1, Method: "password", UserAgent: strings.Repeat("浏览器", 100), Reason: strings.Repeat("r", 100), Ctime: 1},
+		{Uid: 2, Method: "password", UserAgent: "Chrome", Success: true, Ctime: 2},
+	})
+	require.NoError(t, err)
+
+	events, err := dao.FindByUid(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, 255, utf8.RuneCountInString(events[0].UserAgent))
+	assert.True(t, utf8.ValidString(events[0].UserAgent))
+	assert.Len(t, events[0].Reason, 64)
+	events, err = dao.FindByUid(ctx, 2, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
@@ -0,0 +1,27 @@
+// Package daotest 测试用的数据库，别的包测 dao 相关的代码的时候用，不要在业务代码里用
+package daotest
+
+import (
+	"basic-go/week2/webook/internal/repository/dao"
+	"context"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+)
+
+// NewSQLiteDB 在临时目录里建一个 sqlite 数据库，执行完所有的迁移脚本，测试结束自动删掉
+func NewSQLiteDB(t testing.TB, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	require.NoError(t, err)
+	m, err := dao.NewMigrator(db)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, m.Verify(context.Background()))
+	return db
+}
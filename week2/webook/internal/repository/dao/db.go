@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"basic-go/week2/webook/pkg/dbx"
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"time"
+	"unicode/utf8"
+)
+
+// withCtx 代替 db.WithContext。
+// 配置了从库的时候，dbresolver 会把写请求发到主库，读请求发到从库；ctx 里要求读主库（dbx.WithPrimary）的话，读也走主库
+func withCtx(ctx context.Context, db *gorm.DB) *gorm.DB {
+	db = db.WithContext(ctx)
+	if dbx.IsPrimary(ctx) {
+		db = db.Clauses(dbresolver.Write)
+	}
+	return db
+}
+
+type nowKey struct{}
+
+// withNow 让这个 ctx 上的写操作都用同一个时间。
+// 双写的时候两边各自取时间会差几毫秒，utime 对不上，校验的时候就会当成不一致
+func withNow(ctx context.Context, now int64) context.Context {
+	return context.WithValue(ctx, nowKey{}, now)
+}
+
+// nowMilli 当前时间的毫秒数，ctx 里指定了时间就用指定的
+func nowMilli(ctx context.Context) int64 {
+	if now, ok := ctx.Value(nowKey{}).(int64); ok {
+		return now
+	}
+	return time.Now().UnixMilli()
+}
+
+// truncate 截断到最多 n 个字符，MySQL 的 varchar(n) 按字符算，不能从一个字符中间截断
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
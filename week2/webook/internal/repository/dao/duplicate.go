@@ -0,0 +1,47 @@
+package dao
+
+import (
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"regexp"
+	"strings"
+)
+
+var (
+	// MySQL 1062 的错误信息是 Duplicate entry 'xxx' for key 'users.email'，MySQL 5.7 没有前面的表名
+	mysqlDuplicateRegexp = regexp.MustCompile(`for key '([^']+)'`)
+	// SQLite 的错误信息是 UNIQUE constraint failed: users.email，联合唯一索引会有多个列，用逗号隔开
+	sqliteDuplicateRegexp = regexp.MustCompile(`UNIQUE constraint failed: ([^\s,]+)`)
+)
+
+// duplicateKey 判断 err 是不是唯一索引冲突，是的话返回冲突的索引名（去掉了表名）
+// 不依赖具体的数据库：MySQL 按错误码，SQLite 按错误信息，这样 dao 不用 import SQLite 的驱动（要 cgo）
+// note SQLite 的错误信息里只有列名没有索引名，联合唯一索引返回第一列
+func duplicateKey(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		const duplicateErr uint16 = 1062 // 从控制台看到的具体Error Number
+		if me.Number != duplicateErr {
+			return "", false
+		}
+		matches := mysqlDuplicateRegexp.FindStringSubmatch(me.Message)
+		if matches == nil {
+			return "", true
+		}
+		return trimTable(matches[1]), true
+	}
+	if matches := sqliteDuplicateRegexp.FindStringSubmatch(err.Error()); matches != nil {
+		return trimTable(matches[1]), true
+	}
+	return "", false
+}
+
+func trimTable(key string) string {
+	if idx := strings.LastIndex(key, "."); idx >= 0 {
+		return key[idx+1:]
+	}
+	return key
+}
@@ -0,0 +1,195 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InteractiveDao 阅读、点赞、收藏。biz 是业务，比如 "article"，bizId 是业务对象的 id
+type InteractiveDao interface {
+	// IncrReadCnt 阅读数加一，没有记录就插入
+	IncrReadCnt(ctx context.Context, biz string, bizId int64) error
+	// InsertLikeInfo 点赞，changed 是 false 说明之前就点过了，点赞数不变
+	InsertLikeInfo(ctx context.Context, biz string, bizId int64, uid int64) (changed bool, err error)
+	// DeleteLikeInfo 取消点赞，changed 是 false 说明本来就没点过
+	DeleteLikeInfo(ctx context.Context, biz string, bizId int64, uid int64) (changed bool, err error)
+	// InsertCollectionInfo 收藏，和点赞一样是幂等的
+	InsertCollectionInfo(ctx context.Context, biz string, bizId int64, uid int64) (changed bool, err error)
+	DeleteCollectionInfo(ctx context.Context, biz string, bizId int64, uid int64) (changed bool, err error)
+	// Get 没有记录返回 ErrRecordNotFound，说明还没人看过
+	Get(ctx context.Context, biz string, bizId int64) (Interactive, error)
+	// GetByIds 没有记录的不返回
+	GetByIds(ctx context.Context, biz string, bizIds []int64) ([]Interactive, error)
+	Liked(ctx context.Context, biz string, bizId int64, uid int64) (bool, error)
+	Collected(ctx context.Context, biz string, bizId int64, uid int64) (bool, error)
+}
+
+type GORMInteractiveDao struct {
+	db *gorm.DB
+}
+
+func NewInteractiveDao(db *gorm.DB) InteractiveDao {
+	return &GORMInteractiveDao{
+		db: db,
+	}
+}
+
+func (dao *GORMInteractiveDao) IncrReadCnt(ctx context.Context, biz string, bizId int64) error {
+	return dao.incr(ctx, withCtx(ctx, dao.db), biz, bizId, "read_cnt", 1)
+}
+
+func (dao *GORMInteractiveDao) InsertLikeInfo(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return dao.toggle(ctx, "user_like_bizs", "like_cnt", biz, bizId, uid, true)
+}
+
+func (dao *GORMInteractiveDao) DeleteLikeInfo(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return dao.toggle(ctx, "user_like_bizs", "like_cnt", biz, bizId, uid, false)
+}
+
+func (dao *GORMInteractiveDao) InsertCollectionInfo(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return dao.toggle(ctx, "user_collection_bizs", "collect_cnt", biz, bizId, uid, true)
+}
+
+func (dao *GORMInteractiveDao) DeleteCollectionInfo(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return dao.toggle(ctx, "user_collection_bizs", "collect_cnt", biz, bizId, uid, false)
+}
+
+// toggle 改用户的点赞（收藏）记录，真的改了才改计数，两步在一个事务里。
+// 重复点赞、取消没点过的都不会改计数，前端重试、用户连点都没关系
+func (dao *GORMInteractiveDao) toggle(ctx context.Context, table string, cntCol string,
+	biz string, bizId int64, uid int64, on bool) (bool, error) {
+	now := nowMilli(ctx)
+	changed := false
+	err := withCtx(ctx, dao.db).Transaction(func(tx *gorm.DB) error {
+		var res *gorm.DB
+		if on {
+			// 第一次点赞插入，插入不了说明有记录，再把取消了的改回来
+			// 两张表结构一样，用 UserLikeBiz 插入，表名用 table
+			res = tx.Table(table).Clauses(clause.OnConflict{DoNothing: true}).Create(&UserLikeBiz{
+				Uid:    uid,
+				Biz:    biz,
+				BizId:  bizId,
+				Status: UserBizStatusValid,
+				Ctime:  now,
+				Utime:  now,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				res = dao.updateStatus(tx, table, biz, bizId, uid, UserBizStatusCancelled, UserBizStatusValid, now)
+			}
+		} else {
+			res = dao.updateStatus(tx, table, biz, bizId, uid, UserBizStatusValid, UserBizStatusCancelled, now)
+		}
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		changed = true
+		delta := int64(1)
+		if !on {
+			delta = -1
+		}
+		return dao.incr(ctx, tx, biz, bizId, cntCol, delta)
+	})
+	return changed && err == nil, err
+}
+
+// updateStatus 只改状态是 from 的，改了几行就说明状态变没变
+func (dao *GORMInteractiveDao) updateStatus(tx *gorm.DB, table string, biz string, bizId int64, uid int64,
+	from uint8, to uint8, now int64) *gorm.DB {
+	return tx.Table(table).
+		Where("uid=? AND biz=? AND biz_id=? AND status=?", uid, biz, bizId, from).
+		Updates(map[string]any{
+			"status": to,
+			"utime":  now,
+		})
+}
+
+// incr 计数加 delta，没有记录就插入。cntCol 只能是代码里写死的列名，不能来自用户输入
+func (dao *GORMInteractiveDao) incr(ctx context.Context, db *gorm.DB, biz string, bizId int64, cntCol string, delta int64) error {
+	now := nowMilli(ctx)
+	// 取消只会发生在加过之后，插入的时候不会是负数
+	var initial int64
+	if delta > 0 {
+		initial = delta
+	}
+	return db.Model(&Interactive{}).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "biz"}, {Name: "biz_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			cntCol:  gorm.Expr(cntCol+" + ?", delta),
+			"utime": now,
+		}),
+	}).Create(map[string]any{
+		"biz":    biz,
+		"biz_id": bizId,
+		cntCol:   initial,
+		"ctime":  now,
+		"utime":  now,
+	}).Error
+}
+
+func (dao *GORMInteractiveDao) Get(ctx context.Context, biz string, bizId int64) (Interactive, error) {
+	var res Interactive
+	err := withCtx(ctx, dao.db).Where("biz=? AND biz_id=?", biz, bizId).First(&res).Error
+	return res, err
+}
+
+func (dao *GORMInteractiveDao) GetByIds(ctx context.Context, biz string, bizIds []int64) ([]Interactive, error) {
+	var res []Interactive
+	if len(bizIds) == 0 {
+		return res, nil
+	}
+	err := withCtx(ctx, dao.db).Where("biz=? AND biz_id IN ?", biz, bizIds).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMInteractiveDao) Liked(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return dao.exists(ctx, "user_like_bizs", biz, bizId, uid)
+}
+
+func (dao *GORMInteractiveDao) Collected(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return dao.exists(ctx, "user_collection_bizs", biz, bizId, uid)
+}
+
+func (dao *GORMInteractiveDao) exists(ctx context.Context, table string, biz string, bizId int64, uid int64) (bool, error) {
+	var cnt int64
+	// 走唯一索引
+	err := withCtx(ctx, dao.db).Table(table).
+		Where("uid=? AND biz=? AND biz_id=? AND status=?", uid, biz, bizId, UserBizStatusValid).
+		Count(&cnt).Error
+	return cnt > 0, err
+}
+
+// Interactive 每个业务对象一行计数，(biz, biz_id) 唯一
+type Interactive struct {
+	Id         int64  `gorm:"primaryKey,autoIncrement"`
+	Biz        string `gorm:"type:varchar(128);uniqueIndex:uni_interactives_biz_id"`
+	BizId      int64  `gorm:"uniqueIndex:uni_interactives_biz_id"`
+	ReadCnt    int64
+	LikeCnt    int64
+	CollectCnt int64
+	Ctime      int64
+	Utime      int64
+}
+
+// 点赞、收藏记录的状态，取消的时候不删记录，只改状态
+const (
+	UserBizStatusCancelled uint8 = 0
+	UserBizStatusValid     uint8 = 1
+)
+
+// UserLikeBiz 谁点赞了什么，(uid, biz, biz_id) 唯一
+type UserLikeBiz struct {
+	Id     int64  `gorm:"primaryKey,autoIncrement"`
+	Uid    int64  `gorm:"uniqueIndex:uni_user_like_bizs_uid_biz"`
+	Biz    string `gorm:"type:varchar(128);uniqueIndex:uni_user_like_bizs_uid_biz"`
+	BizId  int64  `gorm:"uniqueIndex:uni_user_like_bizs_uid_biz"`
+	Status uint8
+	Ctime  int64
+	Utime  int64
+}
+
+// UserCollectionBiz 谁收藏了什么，结构和 UserLikeBiz 一样
+type UserCollectionBiz UserLikeBiz
@@ -0,0 +1,71 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGORMInteractiveDao(t *testing.T) {
+	db := newTestDB(t)
+	dao := NewInteractiveDao(db)
+	ctx := context.Background()
+
+	// 还没人看过
+	_, err := dao.Get(ctx, "article", 1)
+	assert.Equal(t, ErrRecordNotFound, err)
+
+	require.NoError(t, dao.IncrReadCnt(ctx, "article", 1))
+	require.NoError(t, dao.IncrReadCnt(ctx, "article", 1))
+
+	// 重复点赞只算一次
+	changed, err := dao.InsertLikeInfo(ctx, "article", 1, 100)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = dao.InsertLikeInfo(ctx, "article", 1, 100)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	_, err = dao.InsertLikeInfo(ctx, "article", 1, 101)
+	require.NoError(t, err)
+
+	// 取消没点过的不改计数
+	changed, err = dao.DeleteLikeInfo(ctx, "article", 1, 102)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	changed, err = dao.DeleteLikeInfo(ctx, "article", 1, 101)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// 取消之后可以再点
+	_, err = dao.InsertCollectionInfo(ctx, "article", 1, 100)
+	require.NoError(t, err)
+	_, err = dao.DeleteCollectionInfo(ctx, "article", 1, 100)
+	require.NoError(t, err)
+	changed, err = dao.InsertCollectionInfo(ctx, "article", 1, 100)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	intr, err := dao.Get(ctx, "article", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), intr.ReadCnt)
+	assert.Equal(t, int64(1), intr.LikeCnt)
+	assert.Equal(t, int64(1), intr.CollectCnt)
+
+	liked, err := dao.Liked(ctx, "article", 1, 100)
+	require.NoError(t, err)
+	assert.True(t, liked)
+	liked, err = dao.Liked(ctx, "article", 1, 101)
+	require.NoError(t, err)
+	assert.False(t, liked)
+	collected, err := dao.Collected(ctx, "article", 1, 100)
+	require.NoError(t, err)
+	assert.True(t, collected)
+
+	// 没有记录的不返回，别的业务的不返回
+	require.NoError(t, dao.IncrReadCnt(ctx, "video", 2))
+	intrs, err := dao.GetByIds(ctx, "article", []int64{1, 2})
+	require.NoError(t, err)
+	require.Len(t, intrs, 1)
+	assert.Equal(t, int64(1), intrs[0].BizId)
+}
@@ -0,0 +1,40 @@
+package dao
+
+import (
+	"basic-go/week2/webook/pkg/migrate"
+	"embed"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// 表结构都由 migrations 下面的 SQL 脚本管理，不再用 AutoMigrate，每种数据库一个目录。
+// 要改表结构就在两个目录里各加一个新版本，已经发布的脚本不能再改
+//
+//go:embed migrations
+var migrations embed.FS
+
+// NewMigrator 按 db 连的是什么数据库选迁移脚本
+func NewMigrator(db *gorm.DB) (*migrate.Migrator, error) {
+	m, err := migrate.New(db, migrations, "migrations/"+db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	m.AddCheck(1, checkUsersColumns)
+	return m, nil
+}
+
+// usersV1Columns 0001_create_users 里的列，也就是 AutoMigrate 建的老表里的列。
+// 0001 是 IF NOT EXISTS，老库不会执行，之后加的列都要放到新的版本里，不能加在这里
+var usersV1Columns = []string{
+	"id", "email", "password", "nickname", "birthday", "resume",
+	"phone", "wechat_open_id", "wechat_union_id", "ctime", "utime",
+}
+
+func checkUsersColumns(db *gorm.DB) error {
+	for _, col := range usersV1Columns {
+		if !db.Migrator().HasColumn("users", col) {
+			return fmt.Errorf("users 表缺少 %s 列，老库要先手动补上", col)
+		}
+	}
+	return nil
+}
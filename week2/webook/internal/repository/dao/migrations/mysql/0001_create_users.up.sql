@@ -0,0 +1,19 @@
+-- 之前是 AutoMigrate 建的表，用 IF NOT EXISTS 让老库直接把这个版本当成起点
+-- 有唯一索引的字符串列用 varchar(191)，utf8mb4 下索引不会超过 767 字节
+CREATE TABLE IF NOT EXISTS `users` (
+    `id`              bigint NOT NULL AUTO_INCREMENT,
+    `email`           varchar(191) DEFAULT NULL,
+    `password`        longtext,
+    `nickname`        varchar(20)  DEFAULT NULL,
+    `birthday`        bigint       DEFAULT NULL,
+    `resume`          varchar(200) DEFAULT NULL,
+    `phone`           varchar(191) DEFAULT NULL,
+    `wechat_open_id`  varchar(191) DEFAULT NULL,
+    `wechat_union_id` longtext,
+    `ctime`           bigint       DEFAULT NULL,
+    `utime`           bigint       DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uni_users_email` (`email`),
+    UNIQUE KEY `uni_users_phone` (`phone`),
+    UNIQUE KEY `uni_users_wechat_open_id` (`wechat_open_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `wechat_tokens` (
+    `id`            bigint NOT NULL AUTO_INCREMENT,
+    `uid`           bigint        DEFAULT NULL,
+    `app`           varchar(32)   DEFAULT NULL,
+    `open_id`       varchar(128)  DEFAULT NULL,
+    `access_token`  varchar(1024) DEFAULT NULL,
+    `refresh_token` varchar(1024) DEFAULT NULL,
+    `scope`         varchar(128)  DEFAULT NULL,
+    `expires_at`    bigint        DEFAULT NULL,
+    `ctime`         bigint        DEFAULT NULL,
+    `utime`         bigint        DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uni_wechat_tokens_uid` (`uid`),
+    KEY `idx_wechat_tokens_expires_at` (`expires_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+    MODIFY `nickname` longtext,
+    MODIFY `resume` longtext;
@@ -0,0 +1,5 @@
+-- 以前 gorm tag 写成了 type=varchar(20)，AutoMigrate 不认，建出来的是 longtext
+-- 新库在 0001 里已经是 varchar 了，这里再改一次也没关系
+ALTER TABLE `users`
+    MODIFY `nickname` varchar(20) DEFAULT NULL,
+    MODIFY `resume` varchar(200) DEFAULT NULL;
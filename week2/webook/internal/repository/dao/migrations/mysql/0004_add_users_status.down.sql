@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+    DROP INDEX `idx_users_status_deactivated_at`,
+    DROP COLUMN `status`,
+    DROP COLUMN `deactivated_at`;
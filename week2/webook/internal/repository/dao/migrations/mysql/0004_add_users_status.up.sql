@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+    ADD COLUMN `status` tinyint NOT NULL DEFAULT 0,
+    ADD COLUMN `deactivated_at` bigint NOT NULL DEFAULT 0,
+    ADD INDEX `idx_users_status_deactivated_at` (`status`, `deactivated_at`);
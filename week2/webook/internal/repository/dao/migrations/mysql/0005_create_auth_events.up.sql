@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `auth_events` (
+    `id`         bigint NOT NULL AUTO_INCREMENT,
+    `uid`        bigint       NOT NULL DEFAULT 0,
+    `method`     varchar(20)  NOT NULL DEFAULT '',
+    `ip`         varchar(64)  NOT NULL DEFAULT '',
+    `user_agent` varchar(255) NOT NULL DEFAULT '',
+    `device`     varchar(32)  NOT NULL DEFAULT '',
+    `success`    tinyint(1)   NOT NULL DEFAULT 0,
+    `reason`     varchar(64)  NOT NULL DEFAULT '',
+    `new_device` tinyint(1)   NOT NULL DEFAULT 0,
+    `ctime`      bigint       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_auth_events_uid_ctime` (`uid`, `ctime`),
+    KEY `idx_auth_events_uid_device` (`uid`, `device`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
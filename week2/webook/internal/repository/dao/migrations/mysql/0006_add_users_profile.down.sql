@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+    DROP COLUMN `website`,
+    DROP COLUMN `location`,
+    DROP COLUMN `gender`;
@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+    ADD COLUMN `gender` tinyint NOT NULL DEFAULT 0,
+    ADD COLUMN `location` varchar(64) DEFAULT NULL,
+    ADD COLUMN `website` varchar(256) DEFAULT NULL;
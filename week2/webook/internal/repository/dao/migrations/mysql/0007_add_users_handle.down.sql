@@ -0,0 +1,6 @@
+ALTER TABLE `users`
+    DROP INDEX `uni_users_handle`,
+    DROP COLUMN `hide_resume`,
+    DROP COLUMN `hide_birthday`,
+    DROP COLUMN `handle_utime`,
+    DROP COLUMN `handle`;
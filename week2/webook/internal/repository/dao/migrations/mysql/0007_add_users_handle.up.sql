@@ -0,0 +1,6 @@
+ALTER TABLE `users`
+    ADD COLUMN `handle` varchar(32) DEFAULT NULL,
+    ADD COLUMN `handle_utime` bigint NOT NULL DEFAULT 0,
+    ADD COLUMN `hide_birthday` tinyint(1) NOT NULL DEFAULT 0,
+    ADD COLUMN `hide_resume` tinyint(1) NOT NULL DEFAULT 0,
+    ADD UNIQUE KEY `uni_users_handle` (`handle`);
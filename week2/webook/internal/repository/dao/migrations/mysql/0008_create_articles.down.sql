@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `published_articles`;
+DROP TABLE IF EXISTS `articles`;
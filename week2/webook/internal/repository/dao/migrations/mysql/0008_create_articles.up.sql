@@ -0,0 +1,23 @@
+-- articles 是作者的草稿（制作库），published_articles 是发表出去给读者看的（线上库），两边的 id 一样
+CREATE TABLE IF NOT EXISTS `articles` (
+    `id`        bigint NOT NULL AUTO_INCREMENT,
+    `title`     varchar(256) NOT NULL DEFAULT '',
+    `content`   longtext,
+    `author_id` bigint       NOT NULL DEFAULT 0,
+    `status`    tinyint      NOT NULL DEFAULT 0,
+    `ctime`     bigint       NOT NULL DEFAULT 0,
+    `utime`     bigint       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_articles_author_utime` (`author_id`, `utime`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+CREATE TABLE IF NOT EXISTS `published_articles` (
+    `id`        bigint       NOT NULL,
+    `title`     varchar(256) NOT NULL DEFAULT '',
+    `content`   longtext,
+    `author_id` bigint       NOT NULL DEFAULT 0,
+    `status`    tinyint      NOT NULL DEFAULT 0,
+    `ctime`     bigint       NOT NULL DEFAULT 0,
+    `utime`     bigint       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_published_articles_author_utime` (`author_id`, `utime`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `user_collection_bizs`;
+DROP TABLE IF EXISTS `user_like_bizs`;
+DROP TABLE IF EXISTS `interactives`;
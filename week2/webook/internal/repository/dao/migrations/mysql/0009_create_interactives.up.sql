@@ -0,0 +1,36 @@
+-- interactives 是每个业务对象的计数，user_like_bizs、user_collection_bizs 记录谁点赞、收藏了什么，
+-- 取消的时候只改 status，不删数据
+CREATE TABLE IF NOT EXISTS `interactives` (
+    `id`          bigint       NOT NULL AUTO_INCREMENT,
+    `biz`         varchar(128) NOT NULL DEFAULT '',
+    `biz_id`      bigint       NOT NULL DEFAULT 0,
+    `read_cnt`    bigint       NOT NULL DEFAULT 0,
+    `like_cnt`    bigint       NOT NULL DEFAULT 0,
+    `collect_cnt` bigint       NOT NULL DEFAULT 0,
+    `ctime`       bigint       NOT NULL DEFAULT 0,
+    `utime`       bigint       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uni_interactives_biz_id` (`biz`, `biz_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+CREATE TABLE IF NOT EXISTS `user_like_bizs` (
+    `id`     bigint       NOT NULL AUTO_INCREMENT,
+    `uid`    bigint       NOT NULL DEFAULT 0,
+    `biz`    varchar(128) NOT NULL DEFAULT '',
+    `biz_id` bigint       NOT NULL DEFAULT 0,
+    `status` tinyint      NOT NULL DEFAULT 0,
+    `ctime`  bigint       NOT NULL DEFAULT 0,
+    `utime`  bigint       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uni_user_like_bizs_uid_biz` (`uid`, `biz`, `biz_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+CREATE TABLE IF NOT EXISTS `user_collection_bizs` (
+    `id`     bigint       NOT NULL AUTO_INCREMENT,
+    `uid`    bigint       NOT NULL DEFAULT 0,
+    `biz`    varchar(128) NOT NULL DEFAULT '',
+    `biz_id` bigint       NOT NULL DEFAULT 0,
+    `status` tinyint      NOT NULL DEFAULT 0,
+    `ctime`  bigint       NOT NULL DEFAULT 0,
+    `utime`  bigint       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uni_user_collection_bizs_uid_biz` (`uid`, `biz`, `biz_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+    DROP COLUMN `locale`,
+    DROP COLUMN `avatar`;
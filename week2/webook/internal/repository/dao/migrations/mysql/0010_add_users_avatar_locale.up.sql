@@ -0,0 +1,4 @@
+-- 0001 是 AutoMigrate 建的老表的样子，头像和语言是之后加的，老库靠这个版本补上
+ALTER TABLE `users`
+    ADD COLUMN `avatar` varchar(1024) DEFAULT NULL,
+    ADD COLUMN `locale` varchar(16) DEFAULT NULL;
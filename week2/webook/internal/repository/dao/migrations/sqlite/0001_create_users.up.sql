@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS `users` (
+    `id`              integer PRIMARY KEY AUTOINCREMENT,
+    `email`           text,
+    `password`        text,
+    `nickname`        varchar(20),
+    `birthday`        integer,
+    `resume`          varchar(200),
+    `phone`           text,
+    `wechat_open_id`  text,
+    `wechat_union_id` text,
+    `ctime`           integer,
+    `utime`           integer,
+    CONSTRAINT `uni_users_email` UNIQUE (`email`),
+    CONSTRAINT `uni_users_phone` UNIQUE (`phone`),
+    CONSTRAINT `uni_users_wechat_open_id` UNIQUE (`wechat_open_id`)
+);
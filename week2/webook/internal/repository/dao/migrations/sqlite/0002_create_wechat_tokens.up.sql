@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `wechat_tokens` (
+    `id`            integer PRIMARY KEY AUTOINCREMENT,
+    `uid`           integer,
+    `app`           varchar(32),
+    `open_id`       varchar(128),
+    `access_token`  varchar(1024),
+    `refresh_token` varchar(1024),
+    `scope`         varchar(128),
+    `expires_at`    integer,
+    `ctime`         integer,
+    `utime`         integer,
+    CONSTRAINT `uni_wechat_tokens_uid` UNIQUE (`uid`)
+);
+CREATE INDEX IF NOT EXISTS `idx_wechat_tokens_expires_at` ON `wechat_tokens` (`expires_at`);
@@ -0,0 +1 @@
+-- 同 up，什么都不用做
@@ -0,0 +1 @@
+-- SQLite 不检查 varchar 的长度，不用改。留着这个版本是为了和 mysql 的版本号对齐
@@ -0,0 +1,3 @@
+DROP INDEX `idx_users_status_deactivated_at`;
+ALTER TABLE `users` DROP COLUMN `deactivated_at`;
+ALTER TABLE `users` DROP COLUMN `status`;
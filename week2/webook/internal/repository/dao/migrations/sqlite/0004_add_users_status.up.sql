@@ -0,0 +1,3 @@
+ALTER TABLE `users` ADD COLUMN `status` integer NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `deactivated_at` integer NOT NULL DEFAULT 0;
+CREATE INDEX `idx_users_status_deactivated_at` ON `users` (`status`, `deactivated_at`);
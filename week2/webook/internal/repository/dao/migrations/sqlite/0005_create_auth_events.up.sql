@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `auth_events` (
+    `id`         integer PRIMARY KEY AUTOINCREMENT,
+    `uid`        integer      NOT NULL DEFAULT 0,
+    `method`     varchar(20)  NOT NULL DEFAULT '',
+    `ip`         varchar(64)  NOT NULL DEFAULT '',
+    `user_agent` varchar(255) NOT NULL DEFAULT '',
+    `device`     varchar(32)  NOT NULL DEFAULT '',
+    `success`    numeric      NOT NULL DEFAULT 0,
+    `reason`     varchar(64)  NOT NULL DEFAULT '',
+    `new_device` numeric      NOT NULL DEFAULT 0,
+    `ctime`      integer      NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS `idx_auth_events_uid_ctime` ON `auth_events` (`uid`, `ctime`);
+CREATE INDEX IF NOT EXISTS `idx_auth_events_uid_device` ON `auth_events` (`uid`, `device`);
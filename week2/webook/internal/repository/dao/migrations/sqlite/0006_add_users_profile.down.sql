@@ -0,0 +1,3 @@
+ALTER TABLE `users` DROP COLUMN `website`;
+ALTER TABLE `users` DROP COLUMN `location`;
+ALTER TABLE `users` DROP COLUMN `gender`;
@@ -0,0 +1,3 @@
+ALTER TABLE `users` ADD COLUMN `gender` integer NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `location` varchar(64);
+ALTER TABLE `users` ADD COLUMN `website` varchar(256);
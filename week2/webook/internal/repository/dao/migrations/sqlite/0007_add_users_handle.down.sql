@@ -0,0 +1,5 @@
+DROP INDEX `uni_users_handle`;
+ALTER TABLE `users` DROP COLUMN `hide_resume`;
+ALTER TABLE `users` DROP COLUMN `hide_birthday`;
+ALTER TABLE `users` DROP COLUMN `handle_utime`;
+ALTER TABLE `users` DROP COLUMN `handle`;
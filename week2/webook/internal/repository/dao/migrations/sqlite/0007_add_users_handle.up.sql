@@ -0,0 +1,6 @@
+-- SQLite 的 ADD COLUMN 不能带 UNIQUE，唯一索引单独建
+ALTER TABLE `users` ADD COLUMN `handle` varchar(32);
+ALTER TABLE `users` ADD COLUMN `handle_utime` integer NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `hide_birthday` integer NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `hide_resume` integer NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX `uni_users_handle` ON `users` (`handle`);
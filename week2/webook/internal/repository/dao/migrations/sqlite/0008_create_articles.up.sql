@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS `articles` (
+    `id`        integer PRIMARY KEY AUTOINCREMENT,
+    `title`     varchar(256) NOT NULL DEFAULT '',
+    `content`   text,
+    `author_id` integer      NOT NULL DEFAULT 0,
+    `status`    integer      NOT NULL DEFAULT 0,
+    `ctime`     integer      NOT NULL DEFAULT 0,
+    `utime`     integer      NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS `idx_articles_author_utime` ON `articles` (`author_id`, `utime`);
+CREATE TABLE IF NOT EXISTS `published_articles` (
+    `id`        integer PRIMARY KEY,
+    `title`     varchar(256) NOT NULL DEFAULT '',
+    `content`   text,
+    `author_id` integer      NOT NULL DEFAULT 0,
+    `status`    integer      NOT NULL DEFAULT 0,
+    `ctime`     integer      NOT NULL DEFAULT 0,
+    `utime`     integer      NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS `idx_published_articles_author_utime` ON `published_articles` (`author_id`, `utime`);
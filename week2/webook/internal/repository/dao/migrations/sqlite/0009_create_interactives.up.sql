@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS `interactives` (
+    `id`          integer PRIMARY KEY AUTOINCREMENT,
+    `biz`         varchar(128) NOT NULL DEFAULT '',
+    `biz_id`      integer      NOT NULL DEFAULT 0,
+    `read_cnt`    integer      NOT NULL DEFAULT 0,
+    `like_cnt`    integer      NOT NULL DEFAULT 0,
+    `collect_cnt` integer      NOT NULL DEFAULT 0,
+    `ctime`       integer      NOT NULL DEFAULT 0,
+    `utime`       integer      NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `uni_interactives_biz_id` ON `interactives` (`biz`, `biz_id`);
+CREATE TABLE IF NOT EXISTS `user_like_bizs` (
+    `id`     integer PRIMARY KEY AUTOINCREMENT,
+    `uid`    integer      NOT NULL DEFAULT 0,
+    `biz`    varchar(128) NOT NULL DEFAULT '',
+    `biz_id` integer      NOT NULL DEFAULT 0,
+    `status` integer      NOT NULL DEFAULT 0,
+    `ctime`  integer      NOT NULL DEFAULT 0,
+    `utime`  integer      NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `uni_user_like_bizs_uid_biz` ON `user_like_bizs` (`uid`, `biz`, `biz_id`);
+CREATE TABLE IF NOT EXISTS `user_collection_bizs` (
+    `id`     integer PRIMARY KEY AUTOINCREMENT,
+    `uid`    integer      NOT NULL DEFAULT 0,
+    `biz`    varchar(128) NOT NULL DEFAULT '',
+    `biz_id` integer      NOT NULL DEFAULT 0,
+    `status` integer      NOT NULL DEFAULT 0,
+    `ctime`  integer      NOT NULL DEFAULT 0,
+    `utime`  integer      NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `uni_user_collection_bizs_uid_biz` ON `user_collection_bizs` (`uid`, `biz`, `biz_id`);
@@ -0,0 +1,2 @@
+ALTER TABLE `users` ADD COLUMN `avatar` varchar(1024);
+ALTER TABLE `users` ADD COLUMN `locale` varchar(16);
@@ -4,26 +4,46 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
-	"time"
 )
 
 // ErrDuplicateEmail 预自定义一个错误
 var (
-	ErrDuplicateEmail = errors.New("邮箱冲突")
-	ErrDuplicatePhone = errors.New("手机号冲突")
+	ErrDuplicateEmail  = errors.New("邮箱冲突")
+	ErrDuplicatePhone  = errors.New("手机号冲突")
+	ErrDuplicateWechat = errors.New("微信账号冲突")
+	ErrDuplicateHandle = errors.New("用户名冲突")
 	// ErrRecordNotFound gorm框架有 未找到某条数据 得错误
 	ErrRecordNotFound = gorm.ErrRecordNotFound
+	// ErrUserConflict 乐观锁冲突：按 utime 更新的时候，数据已经被别人改过了
+	ErrUserConflict = errors.New("用户数据已被修改")
 )
 
 type UserDao interface {
-	Insert(ctx context.Context, u User) error
+	// Insert 返回新用户的 id。u.Id 不是 0 的话就用这个 id（双写的时候两边的 id 要一样）
+	Insert(ctx context.Context, u User) (int64, error)
 	FindByEmail(ctx context.Context, email string) (User, error)
 	FindByPhone(ctx context.Context, phone string) (User, error)
 	UpdateById(ctx context.Context, persistent User) error
+	UpdateLocale(ctx context.Context, id int64, locale string) error
+	UpdateAvatar(ctx context.Context, id int64, avatar string) error
+	// UpdateHandle 改用户名，要求上次改用户名是在 before 之前，不满足返回 ErrRecordNotFound；用户名被占用返回 ErrDuplicateHandle
+	UpdateHandle(ctx context.Context, id int64, handle string, before int64) error
+	UpdatePrivacy(ctx context.Context, id int64, hideBirthday bool, hideResume bool) error
+	// UpdateProfile 只更新 fields 里的列，并且要求现在的 utime 和传进来的一样，不一样返回 ErrUserConflict。
+	// 返回新的 utime
+	UpdateProfile(ctx context.Context, id int64, utime int64, fields map[string]any) (int64, error)
 	FindById(ctx context.Context, id int64) (User, error)
 	FindByWechat(ctx context.Context, openId string) (User, error)
+	FindByHandle(ctx context.Context, handle string) (User, error)
+	// Deactivate 注销，只有正常的账号才会被改
+	Deactivate(ctx context.Context, id int64, at int64) error
+	// Reactivate 恢复在 since 之后注销的账号，不满足条件返回 ErrRecordNotFound
+	Reactivate(ctx context.Context, id int64, since int64) error
+	// FindDeactivated 按 id 从小到大，找出在 before 之前注销、还没清除个人信息的账号
+	FindDeactivated(ctx context.Context, before int64, startId int64, limit int) ([]User, error)
+	// Anonymize 清除注销账号的个人信息，一起删掉微信凭证和登录记录。账号已经恢复了返回 ErrRecordNotFound
+	Anonymize(ctx context.Context, id int64) error
 }
 type GORMUserDao struct {
 	db *gorm.DB
@@ -36,68 +56,254 @@ func NewUserDao(db *gorm.DB) UserDao {
 }
 
 // Insert dao层要返回自己定义的User，而不是domain.User
-func (dao *GORMUserDao) Insert(ctx context.Context, u User) error {
+func (dao *GORMUserDao) Insert(ctx context.Context, u User) (int64, error) {
 	// 取当前毫秒数
-	now := time.Now().UnixMilli()
+	now := nowMilli(ctx)
 	u.Ctime = now
 	u.Utime = now
 
-	// 获取err，检查是否是邮箱冲突
-	err := dao.db.WithContext(ctx).Create(&u).Error
-	// note 类型断言
-	if me, ok := err.(*mysql.MySQLError); ok {
-		const duplicateErr uint16 = 1062 // 从控制台看到的具体Error Number
-		if me.Number == duplicateErr {
-			// 邮箱冲突
-			// return一个特定的错误
-			return ErrDuplicateEmail
+	// 获取err，检查是哪个唯一索引冲突了
+	err := withCtx(ctx, dao.db).Create(&u).Error
+	if key, ok := duplicateKey(err); ok {
+		// return一个特定的错误
+		if derr, ok := userDuplicateErrs[key]; ok {
+			return 0, derr
 		}
 	}
-	return err
+	// 认不出来的原样返回，当成系统错误
+	return u.Id, err
+}
+
+// userDuplicateErrs 唯一索引和冲突错误的对应关系。
+// gorm 按 unique tag 建的索引，老版本的名字就是列名，新版本是 uni_users_列名；SQLite 报的是列名
+var userDuplicateErrs = map[string]error{
+	"email":                    ErrDuplicateEmail,
+	"uni_users_email":          ErrDuplicateEmail,
+	"phone":                    ErrDuplicatePhone,
+	"uni_users_phone":          ErrDuplicatePhone,
+	"wechat_open_id":           ErrDuplicateWechat,
+	"uni_users_wechat_open_id": ErrDuplicateWechat,
+	"handle":                   ErrDuplicateHandle,
+	"uni_users_handle":         ErrDuplicateHandle,
 }
 
 func (dao *GORMUserDao) FindByEmail(ctx context.Context, email string) (User, error) {
 	var u User
-	err := dao.db.WithContext(ctx).Where("email=?", email).First(&u).Error
+	err := withCtx(ctx, dao.db).Where("email=?", email).First(&u).Error
 	return u, err
 }
 
 func (dao *GORMUserDao) UpdateById(ctx context.Context, persistent User) error {
-	return dao.db.WithContext(ctx).Model(&persistent).Where("id=?", persistent.Id).Updates(map[string]any{
-		"utime":    time.Now().UnixMilli(), // 更新时间
+	fields := map[string]any{
+		"utime":    nowMilli(ctx), // 更新时间
 		"nickname": persistent.Nickname,
 		"birthday": persistent.Birthday,
 		"resume":   persistent.Resume,
+	}
+	// 编辑资料的时候不带头像，不能把头像清掉
+	if persistent.Avatar != "" {
+		fields["avatar"] = persistent.Avatar
+	}
+	return withCtx(ctx, dao.db).Model(&persistent).Where("id=?", persistent.Id).Updates(fields).Error
+}
+
+func (dao *GORMUserDao) UpdateProfile(ctx context.Context, id int64, utime int64, fields map[string]any) (int64, error) {
+	now := nowMilli(ctx)
+	if now <= utime {
+		// 同一毫秒里改了两次的话 utime 不变，第二个拿着旧 utime 的请求也能改成功，乐观锁就失效了
+		now = utime + 1
+	}
+	updates := make(map[string]any, len(fields)+1)
+	for k, v := range fields {
+		updates[k] = v
+	}
+	updates["utime"] = now
+	res := withCtx(ctx, dao.db).Model(&User{}).Where("id=? AND utime=?", id, utime).Updates(updates)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return 0, ErrUserConflict
+	}
+	return now, nil
+}
+
+func (dao *GORMUserDao) UpdateAvatar(ctx context.Context, id int64, avatar string) error {
+	return withCtx(ctx, dao.db).Model(&User{}).Where("id=?", id).Updates(map[string]any{
+		"utime":  nowMilli(ctx),
+		"avatar": avatar,
+	}).Error
+}
+
+func (dao *GORMUserDao) UpdateHandle(ctx context.Context, id int64, handle string, before int64) error {
+	now := nowMilli(ctx)
+	res := withCtx(ctx, dao.db).Model(&User{}).Where("id=? AND handle_utime<?", id, before).
+		Updates(map[string]any{
+			"utime":        now,
+			"handle":       handle,
+			"handle_utime": now,
+		})
+	if key, ok := duplicateKey(res.Error); ok {
+		if derr, ok := userDuplicateErrs[key]; ok {
+			return derr
+		}
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		// 冷静期内并发改了两次，只有一个能成功
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (dao *GORMUserDao) UpdatePrivacy(ctx context.Context, id int64, hideBirthday bool, hideResume bool) error {
+	return withCtx(ctx, dao.db).Model(&User{}).Where("id=?", id).Updates(map[string]any{
+		"utime":         nowMilli(ctx),
+		"hide_birthday": hideBirthday,
+		"hide_resume":   hideResume,
+	}).Error
+}
+
+func (dao *GORMUserDao) UpdateLocale(ctx context.Context, id int64, locale string) error {
+	return withCtx(ctx, dao.db).Model(&User{}).Where("id=?", id).Updates(map[string]any{
+		"utime":  nowMilli(ctx),
+		"locale": locale,
 	}).Error
 }
 
+func (dao *GORMUserDao) Deactivate(ctx context.Context, id int64, at int64) error {
+	return withCtx(ctx, dao.db).Model(&User{}).
+		Where("id=? AND status=?", id, UserStatusActive).
+		Updates(map[string]any{
+			"utime":          nowMilli(ctx),
+			"status":         UserStatusDeactivated,
+			"deactivated_at": at,
+		}).Error
+}
+
+func (dao *GORMUserDao) Reactivate(ctx context.Context, id int64, since int64) error {
+	res := withCtx(ctx, dao.db).Model(&User{}).
+		Where("id=? AND status=? AND deactivated_at>=?", id, UserStatusDeactivated, since).
+		Updates(map[string]any{
+			"utime":          nowMilli(ctx),
+			"status":         UserStatusActive,
+			"deactivated_at": 0,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		// 冷静期刚好过了，或者已经被清除了
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (dao *GORMUserDao) FindDeactivated(ctx context.Context, before int64, startId int64, limit int) ([]User, error) {
+	var res []User
+	err := withCtx(ctx, dao.db).
+		Where("status=? AND deactivated_at<? AND id>?", UserStatusDeactivated, before, startId).
+		Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMUserDao) Anonymize(ctx context.Context, id int64) error {
+	now := nowMilli(ctx)
+	return withCtx(ctx, dao.db).Transaction(func(tx *gorm.DB) error {
+		// 邮箱、手机号、微信、用户名置成 NULL，唯一索引就释放了，可以用来注册新账号
+		res := tx.Model(&User{}).Where("id=? AND status=?", id, UserStatusDeactivated).
+			Updates(map[string]any{
+				"utime":           now,
+				"status":          UserStatusDeleted,
+				"email":           nil,
+				"phone":           nil,
+				"wechat_open_id":  nil,
+				"wechat_union_id": nil,
+				"password":        "",
+				"nickname":        "",
+				"birthday":        0,
+				"resume":          "",
+				"avatar":          "",
+				"gender":          0,
+				"location":        "",
+				"website":         "",
+				"handle":          nil,
+				"hide_birthday":   false,
+				"hide_resume":     false,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// 已经恢复了（或者已经清除过了），什么都不做，调用方也不能再删头像之类的
+			return ErrRecordNotFound
+		}
+		if err := tx.Where("uid=?", id).Delete(&WechatToken{}).Error; err != nil {
+			return err
+		}
+		// 登录记录里有 IP 和 UA，也是个人信息
+		return NewAuthEventDao(tx).DeleteByUid(ctx, id)
+	})
+}
+
 func (dao *GORMUserDao) FindById(ctx context.Context, id int64) (User, error) {
 	var u User
-	err := dao.db.WithContext(ctx).Where("id=?", id).First(&u).Error
+	err := withCtx(ctx, dao.db).Where("id=?", id).First(&u).Error
 	return u, err
 }
 
 func (dao *GORMUserDao) FindByPhone(ctx context.Context, phone string) (User, error) {
 	var u User
-	err := dao.db.WithContext(ctx).Where("phone=?", phone).First(&u).Error
+	err := withCtx(ctx, dao.db).Where("phone=?", phone).First(&u).Error
 	return u, err
 }
 
 func (dao *GORMUserDao) FindByWechat(ctx context.Context, openId string) (User, error) {
 	var u User
-	err := dao.db.WithContext(ctx).Where("wechat_open_id=?", openId).First(&u).Error
+	err := withCtx(ctx, dao.db).Where("wechat_open_id=?", openId).First(&u).Error
 	return u, err
 }
 
+func (dao *GORMUserDao) FindByHandle(ctx context.Context, handle string) (User, error) {
+	var u User
+	err := withCtx(ctx, dao.db).Where("handle=?", handle).First(&u).Error
+	return u, err
+}
+
+// 账号状态，和 domain.UserStatus 一一对应
+const (
+	UserStatusActive      uint8 = 0
+	UserStatusDeactivated uint8 = 1
+	UserStatusDeleted     uint8 = 2
+)
+
 // User 相当于PO，即属性与表字段一一对应
+// note 表结构以 migrations 下面的 SQL 为准，改了字段要加迁移脚本，gorm tag 只是给读代码的人看的
 type User struct {
 	Id int64 `gorm:"primaryKey,autoIncrement"`
 	// 代表可为Null（因为用户用phone注册的话，会没有email）
 	Email    sql.NullString `gorm:"unique"`
 	Password string
-	Nickname string `gorm:"type=varchar(20)"`
+	Nickname string `gorm:"type:varchar(20)"`
 	Birthday int64
-	Resume   string `gorm:"type=varchar(200)"`
+	Resume   string `gorm:"type:varchar(200)"`
+	// 头像的 url
+	Avatar string `gorm:"type:varchar(1024)"`
+	// Gender 0 没填，1 男，2 女，3 其他
+	Gender   uint8  `gorm:"type:tinyint;not null;default:0"`
+	Location string `gorm:"type:varchar(64)"`
+	Website  string `gorm:"type:varchar(256)"`
+	// 界面语言，比如 zh-CN
+	Locale string `gorm:"type:varchar(16)"`
+	// Handle 用户名，公开主页的地址用它，统一存小写。没设置是 NULL
+	Handle sql.NullString `gorm:"type:varchar(32);unique"`
+	// HandleUtime 上次改用户名的时间，UTC 0 的毫秒数，没改过是 0
+	HandleUtime int64 `gorm:"not null;default:0"`
+	// 隐私设置，别人看公开主页的时候不展示
+	HideBirthday bool `gorm:"not null;default:false"`
+	HideResume   bool `gorm:"not null;default:false"`
 
 	Phone sql.NullString `gorm:"unique"`
 	// 索引设计的方案：
@@ -107,6 +313,11 @@ type User struct {
 	WechatOpenId  sql.NullString `gorm:"unique"`
 	WechatUnionId sql.NullString
 
+	// Status 账号状态，0 正常，1 注销了在冷静期，2 已清除个人信息
+	Status uint8 `gorm:"type:tinyint;not null;default:0"`
+	// DeactivatedAt 注销的时间，UTC 0 的毫秒数，没注销是 0
+	DeactivatedAt int64 `gorm:"not null;default:0"`
+
 	// 创建时间  避免时区问题，一律用 UTC 0 的毫秒数【若要转成符合中国的时区，要么让前端处理，要么在web层给前端的时候转成UTC 8 的时区】
 	Ctime int64
 	// 更新时间
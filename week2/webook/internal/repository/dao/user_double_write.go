@@ -0,0 +1,243 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+)
+
+// 双写的几个阶段，迁移的时候按顺序一步步切：
+// src_only -> src_first -> dst_first -> dst_only
+// 每一步都可以切回上一步，出问题的时候回滚不用停服
+const (
+	// PatternSrcOnly 只读写源表，还没开始迁移
+	PatternSrcOnly = "src_only"
+	// PatternSrcFirst 读源表；先写源表，成功了再写目标表，目标表写失败只打日志，靠校验修复
+	PatternSrcFirst = "src_first"
+	// PatternDstFirst 读目标表；先写目标表，成功了再写源表
+	PatternDstFirst = "dst_first"
+	// PatternDstOnly 只读写目标表，迁移完成
+	PatternDstOnly = "dst_only"
+)
+
+var ErrUnknownPattern = errors.New("未知的双写模式")
+
+// ValidPattern pattern 是不是上面的四种之一
+func ValidPattern(pattern string) bool {
+	switch pattern {
+	case PatternSrcOnly, PatternSrcFirst, PatternDstFirst, PatternDstOnly:
+		return true
+	default:
+		return false
+	}
+}
+
+// WriteFailedFunc 后写的那边失败了，pattern 是当时的模式，用来判断以哪边为准
+type WriteFailedFunc func(ctx context.Context, id int64, pattern string)
+
+// DoubleWriteUserDao 迁移 users 表用的装饰器，同时读写源表和目标表，模式可以在运行时切换
+type DoubleWriteUserDao struct {
+	src UserDao
+	dst UserDao
+	// 当前的模式，运行的时候会被配置变更改掉
+	pattern atomic.Value
+	// 后写的那边失败了马上通知修复，不用等下一轮校验
+	onWriteFailed WriteFailedFunc
+}
+
+func NewDoubleWriteUserDao(src UserDao, dst UserDao, pattern string) (*DoubleWriteUserDao, error) {
+	res := &DoubleWriteUserDao{
+		src: src,
+		dst: dst,
+	}
+	return res, res.UpdatePattern(pattern)
+}
+
+// UpdatePattern 切换模式，不认识的模式不切
+func (d *DoubleWriteUserDao) UpdatePattern(pattern string) error {
+	if !ValidPattern(pattern) {
+		return ErrUnknownPattern
+	}
+	d.pattern.Store(pattern)
+	return nil
+}
+
+func (d *DoubleWriteUserDao) Pattern() string {
+	return d.pattern.Load().(string)
+}
+
+// OnWriteFailed 设置后写的那边失败了的时候调什么，要在开始处理请求之前设置
+func (d *DoubleWriteUserDao) OnWriteFailed(fn WriteFailedFunc) {
+	d.onWriteFailed = fn
+}
+
+func (d *DoubleWriteUserDao) writeFailed(ctx context.Context, id int64, pattern string, err error) {
+	if pattern == PatternSrcFirst {
+		log.Println("双写目标表失败", id, err)
+	} else {
+		log.Println("双写源表失败", id, err)
+	}
+	if d.onWriteFailed != nil {
+		d.onWriteFailed(ctx, id, pattern)
+	}
+}
+
+// Insert 后写的那边要用先写那边生成的 id，不然两边的 id 对不上
+func (d *DoubleWriteUserDao) Insert(ctx context.Context, u User) (int64, error) {
+	ctx = withNow(ctx, nowMilli(ctx))
+	pattern := d.Pattern()
+	switch pattern {
+	case PatternSrcOnly:
+		return d.src.Insert(ctx, u)
+	case PatternSrcFirst:
+		id, err := d.src.Insert(ctx, u)
+		if err != nil {
+			return id, err
+		}
+		u.Id = id
+		if _, err = d.dst.Insert(ctx, u); err != nil {
+			d.writeFailed(ctx, id, pattern, err)
+		}
+		return id, nil
+	case PatternDstFirst:
+		id, err := d.dst.Insert(ctx, u)
+		if err != nil {
+			return id, err
+		}
+		u.Id = id
+		if _, err = d.src.Insert(ctx, u); err != nil {
+			d.writeFailed(ctx, id, pattern, err)
+		}
+		return id, nil
+	case PatternDstOnly:
+		return d.dst.Insert(ctx, u)
+	default:
+		return 0, ErrUnknownPattern
+	}
+}
+
+func (d *DoubleWriteUserDao) UpdateById(ctx context.Context, persistent User) error {
+	return d.write(ctx, persistent.Id, func(ctx context.Context, dao UserDao) error {
+		return dao.UpdateById(ctx, persistent)
+	})
+}
+
+func (d *DoubleWriteUserDao) UpdateProfile(ctx context.Context, id int64, utime int64, fields map[string]any) (int64, error) {
+	var res int64
+	err := d.write(ctx, id, func(ctx context.Context, dao UserDao) error {
+		// 两边的 now 是同一个，算出来的新 utime 也一样，以先写的那边为准
+		newUtime, err := dao.UpdateProfile(ctx, id, utime, fields)
+		if err == nil && res == 0 {
+			res = newUtime
+		}
+		return err
+	})
+	return res, err
+}
+
+func (d *DoubleWriteUserDao) UpdateAvatar(ctx context.Context, id int64, avatar string) error {
+	return d.write(ctx, id, func(ctx context.Context, dao UserDao) error {
+		return dao.UpdateAvatar(ctx, id, avatar)
+	})
+}
+
+func (d *DoubleWriteUserDao) UpdateHandle(ctx context.Context, id int64, handle string, before int64) error {
+	return d.write(ctx, id, func(ctx context.Context, dao UserDao) error {
+		return dao.UpdateHandle(ctx, id, handle, before)
+	})
+}
+
+func (d *DoubleWriteUserDao) UpdatePrivacy(ctx context.Context, id int64, hideBirthday bool, hideResume bool) error {
+	return d.write(ctx, id, func(ctx context.Context, dao UserDao) error {
+		return dao.UpdatePrivacy(ctx, id, hideBirthday, hideResume)
+	})
+}
+
+func (d *DoubleWriteUserDao) UpdateLocale(ctx context.Context, id int64, locale string) error {
+	return d.write(ctx, id, func(ctx context.Context, dao UserDao) error {
+		return dao.UpdateLocale(ctx, id, locale)
+	})
+}
+
+func (d *DoubleWriteUserDao) Deactivate(ctx context.Context, id int64, at int64) error {
+	return d.write(ctx, id, func(ctx context.Context, dao UserDao) error {
+		return dao.Deactivate(ctx, id, at)
+	})
+}
+
+func (d *DoubleWriteUserDao) Reactivate(ctx context.Context, id int64, since int64) error {
+	return d.write(ctx, id, func(ctx context.Context, dao UserDao) error {
+		return dao.Reactivate(ctx, id, since)
+	})
+}
+
+func (d *DoubleWriteUserDao) Anonymize(ctx context.Context, id int64) error {
+	return d.write(ctx, id, func(ctx context.Context, dao UserDao) error {
+		return dao.Anonymize(ctx, id)
+	})
+}
+
+// write 按模式写一边或者两边，后写的那边失败了不影响业务，通知修复 id 这一条
+func (d *DoubleWriteUserDao) write(ctx context.Context, id int64, fn func(ctx context.Context, dao UserDao) error) error {
+	ctx = withNow(ctx, nowMilli(ctx))
+	pattern := d.Pattern()
+	switch pattern {
+	case PatternSrcOnly:
+		return fn(ctx, d.src)
+	case PatternSrcFirst:
+		if err := fn(ctx, d.src); err != nil {
+			return err
+		}
+		if err := fn(ctx, d.dst); err != nil {
+			d.writeFailed(ctx, id, pattern, err)
+		}
+		return nil
+	case PatternDstFirst:
+		if err := fn(ctx, d.dst); err != nil {
+			return err
+		}
+		if err := fn(ctx, d.src); err != nil {
+			d.writeFailed(ctx, id, pattern, err)
+		}
+		return nil
+	case PatternDstOnly:
+		return fn(ctx, d.dst)
+	default:
+		return ErrUnknownPattern
+	}
+}
+
+func (d *DoubleWriteUserDao) FindByEmail(ctx context.Context, email string) (User, error) {
+	return d.reader().FindByEmail(ctx, email)
+}
+
+func (d *DoubleWriteUserDao) FindByPhone(ctx context.Context, phone string) (User, error) {
+	return d.reader().FindByPhone(ctx, phone)
+}
+
+func (d *DoubleWriteUserDao) FindById(ctx context.Context, id int64) (User, error) {
+	return d.reader().FindById(ctx, id)
+}
+
+func (d *DoubleWriteUserDao) FindByWechat(ctx context.Context, openId string) (User, error) {
+	return d.reader().FindByWechat(ctx, openId)
+}
+
+func (d *DoubleWriteUserDao) FindByHandle(ctx context.Context, handle string) (User, error) {
+	return d.reader().FindByHandle(ctx, handle)
+}
+
+func (d *DoubleWriteUserDao) FindDeactivated(ctx context.Context, before int64, startId int64, limit int) ([]User, error) {
+	return d.reader().FindDeactivated(ctx, before, startId, limit)
+}
+
+// reader 以哪边为准就读哪边
+func (d *DoubleWriteUserDao) reader() UserDao {
+	switch d.Pattern() {
+	case PatternDstFirst, PatternDstOnly:
+		return d.dst
+	default:
+		return d.src
+	}
+}
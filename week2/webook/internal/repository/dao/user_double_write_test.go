@@ -0,0 +1,82 @@
+package dao_test
+
+import (
+	"basic-go/week2/webook/internal/repository/dao"
+	"basic-go/week2/webook/internal/repository/dao/daotest"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDoubleWriteUserDao(t *testing.T) {
+	srcDB, dstDB := daotest.NewSQLiteDB(t, "src.db"), daotest.NewSQLiteDB(t, "dst.db")
+	src, dst := dao.NewUserDao(srcDB), dao.NewUserDao(dstDB)
+	d, err := dao.NewDoubleWriteUserDao(src, dst, dao.PatternSrcOnly)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// 只写源表
+	id1, err := d.Insert(ctx, dao.User{Phone: sql.NullString{String: "15200000001", Valid: true}})
+	require.NoError(t, err)
+	_, err = dst.FindById(ctx, id1)
+	assert.Equal(t, dao.ErrRecordNotFound, err)
+
+	// 先写源表，目标表用同一个 id 和同一个时间
+	require.NoError(t, d.UpdatePattern(dao.PatternSrcFirst))
+	id2, err := d.Insert(ctx, dao.User{Phone: sql.NullString{String: "15200000002", Valid: true}})
+	require.NoError(t, err)
+	require.NoError(t, d.UpdateLocale(ctx, id2, "en-US"))
+	su, err := src.FindById(ctx, id2)
+	require.NoError(t, err)
+	du, err := dst.FindById(ctx, id2)
+	require.NoError(t, err)
+	assert.Equal(t, su, du)
+	assert.Equal(t, "en-US", du.Locale)
+
+	// 目标表写失败不影响业务：源表的 id1 在目标表里没有，更新的时候目标表什么都不做
+	require.NoError(t, d.UpdateById(ctx, dao.User{Id: id1, Nickname: "大明"}))
+
+	// 读目标表
+	require.NoError(t, d.UpdatePattern(dao.PatternDstFirst))
+	_, err = d.FindById(ctx, id1)
+	assert.Equal(t, dao.ErrRecordNotFound, err)
+	u, err := d.FindByPhone(ctx, "15200000002")
+	require.NoError(t, err)
+	assert.Equal(t, id2, u.Id)
+
+	assert.Equal(t, dao.ErrUnknownPattern, d.UpdatePattern("abc"))
+	assert.Equal(t, dao.PatternDstFirst, d.Pattern())
+}
+
+// failingUserDao 写都失败，模拟目标库出问题
+type failingUserDao struct {
+	dao.UserDao
+}
+
+func (f failingUserDao) UpdateLocale(ctx context.Context, id int64, locale string) error {
+	return errors.New("目标库挂了")
+}
+
+func TestDoubleWriteUserDao_OnWriteFailed(t *testing.T) {
+	src := dao.NewUserDao(daotest.NewSQLiteDB(t, "src.db"))
+	d, err := dao.NewDoubleWriteUserDao(src, failingUserDao{}, dao.PatternSrcFirst)
+	require.NoError(t, err)
+	type failed struct {
+		id      int64
+		pattern string
+	}
+	var got []failed
+	d.OnWriteFailed(func(ctx context.Context, id int64, pattern string) {
+		got = append(got, failed{id: id, pattern: pattern})
+	})
+	ctx := context.Background()
+	id, err := src.Insert(ctx, dao.User{Phone: sql.NullString{String: "15200000001", Valid: true}})
+	require.NoError(t, err)
+
+	// 后写的那边失败了不影响业务，但是要通知修复这一条
+	require.NoError(t, d.UpdateLocale(ctx, id, "en-US"))
+	assert.Equal(t, []failed{{id: id, pattern: dao.PatternSrcFirst}}, got)
+}
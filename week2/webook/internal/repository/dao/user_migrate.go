@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserMigrateDao 迁移 users 表的时候，校验和修复数据用的，业务代码不要用
+type UserMigrateDao interface {
+	// FindAfter 按 id 从小到大，取 id 大于 startId 的 limit 条
+	FindAfter(ctx context.Context, startId int64, limit int) ([]User, error)
+	FindByIds(ctx context.Context, ids []int64) ([]User, error)
+	// Upsert 按 id 整行覆盖，包括 ctime 和 utime
+	Upsert(ctx context.Context, u User) error
+	DeleteById(ctx context.Context, id int64) error
+}
+
+func NewUserMigrateDao(db *gorm.DB) UserMigrateDao {
+	return &GORMUserDao{
+		db: db,
+	}
+}
+
+func (dao *GORMUserDao) FindAfter(ctx context.Context, startId int64, limit int) ([]User, error) {
+	var res []User
+	err := withCtx(ctx, dao.db).Where("id>?", startId).Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMUserDao) FindByIds(ctx context.Context, ids []int64) ([]User, error) {
+	var res []User
+	err := withCtx(ctx, dao.db).Where("id IN ?", ids).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMUserDao) Upsert(ctx context.Context, u User) error {
+	return withCtx(ctx, dao.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		UpdateAll: true,
+	}).Create(&u).Error
+}
+
+func (dao *GORMUserDao) DeleteById(ctx context.Context, id int64) error {
+	return withCtx(ctx, dao.db).Where("id=?", id).Delete(&User{}).Error
+}
@@ -0,0 +1,321 @@
+package dao
+
+import (
+	"basic-go/week2/webook/pkg/dbx"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGORMUserDao_Insert(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mockErr error
+		wantErr error
+	}{
+		{
+			name:    "插入成功",
+			mockErr: nil,
+		},
+		{
+			name:    "邮箱冲突",
+			mockErr: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@qq.com' for key 'users.email'"},
+			wantErr: ErrDuplicateEmail,
+		},
+		{
+			name:    "手机号冲突，MySQL 5.7 没有表名",
+			mockErr: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '15212345678' for key 'phone'"},
+			wantErr: ErrDuplicatePhone,
+		},
+		{
+			name:    "微信冲突，新版 gorm 建的索引名",
+			mockErr: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'open-1' for key 'users.uni_users_wechat_open_id'"},
+			wantErr: ErrDuplicateWechat,
+		},
+		{
+			name:    "不认识的索引",
+			mockErr: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'users.PRIMARY'"},
+			wantErr: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'users.PRIMARY'"},
+		},
+		{
+			name:    "其它错误",
+			mockErr: errors.New("数据库出错"),
+			wantErr: errors.New("数据库出错"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer sqlDB.Close()
+			exp := mock.ExpectExec("INSERT INTO `users`.*")
+			if tc.mockErr != nil {
+				exp.WillReturnError(tc.mockErr)
+			} else {
+				exp.WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      sqlDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				// 不然会多一个 begin 和 commit
+				SkipDefaultTransaction: true,
+				Logger:                 logger.Discard,
+			})
+			require.NoError(t, err)
+
+			_, err = NewUserDao(db).Insert(context.Background(), User{
+				Email: sql.NullString{String: "a@qq.com", Valid: true},
+			})
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// 用两个 sqlite 文件模拟主库和还没同步的从库
+func TestGORMUserDao_ReadPrimary(t *testing.T) {
+	dir := t.TempDir()
+	replica, err := gorm.Open(sqlite.Open(filepath.Join(dir, "replica.db")))
+	require.NoError(t, err)
+	migrateUp(t, replica)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "primary.db")))
+	require.NoError(t, err)
+	migrateUp(t, db)
+	require.NoError(t, db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{sqlite.Open(filepath.Join(dir, "replica.db"))},
+	})))
+
+	dao := NewUserDao(db)
+	ctx := context.Background()
+	// 写走主库
+	_, err = dao.Insert(ctx, User{Phone: sql.NullString{String: "15212345678", Valid: true}})
+	require.NoError(t, err)
+
+	// 默认读从库，还没同步，读不到
+	_, err = dao.FindByPhone(ctx, "15212345678")
+	assert.Equal(t, ErrRecordNotFound, err)
+
+	// 强制读主库
+	u, err := dao.FindByPhone(dbx.WithPrimary(ctx), "15212345678")
+	require.NoError(t, err)
+	assert.Equal(t, "15212345678", u.Phone.String)
+}
+
+// SQLite 也能认出是哪个唯一索引冲突了
+func TestGORMUserDao_Insert_SQLite(t *testing.T) {
+	db := newTestDB(t)
+	dao := NewUserDao(db)
+	ctx := context.Background()
+
+	id, err := dao.Insert(ctx, User{
+		Email:        sql.NullString{String: "a@qq.com", Valid: true},
+		Phone:        sql.NullString{String: "15212345678", Valid: true},
+		WechatOpenId: sql.NullString{String: "open-1", Valid: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	_, err = dao.Insert(ctx, User{Email: sql.NullString{String: "a@qq.com", Valid: true}})
+	assert.Equal(t, ErrDuplicateEmail, err)
+	_, err = dao.Insert(ctx, User{Phone: sql.NullString{String: "15212345678", Valid: true}})
+	assert.Equal(t, ErrDuplicatePhone, err)
+	_, err = dao.Insert(ctx, User{WechatOpenId: sql.NullString{String: "open-1", Valid: true}})
+	assert.Equal(t, ErrDuplicateWechat, err)
+	// 邮箱、手机号都是 NULL 的不算冲突
+	_, err = dao.Insert(ctx, User{})
+	assert.NoError(t, err)
+	_, err = dao.Insert(ctx, User{})
+	assert.NoError(t, err)
+}
+
+// newTestDB 每个测试一个新的 SQLite 文件，表结构和线上一样由迁移脚本建。
+// 外部的测试用 daotest.NewSQLiteDB，这里不能用，daotest 依赖了 dao
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "webook.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	require.NoError(t, err)
+	migrateUp(t, db)
+	return db
+}
+
+func migrateUp(t *testing.T, db *gorm.DB) {
+	m, err := NewMigrator(db)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	// 后面的版本不能把前面检查的列改掉
+	require.NoError(t, m.Verify(context.Background()))
+}
+
+// 上线之前的老库：users 表是 AutoMigrate 按当时的 User 建的，0001 会跳过，后面的版本补上新加的列
+func TestNewMigrator_AutoMigratedUsers(t *testing.T) {
+	type User struct {
+		Id            int64          `gorm:"primaryKey,autoIncrement"`
+		Email         sql.NullString `gorm:"unique"`
+		Password      string
+		Nickname      string `gorm:"type=varchar(20)"`
+		Birthday      int64
+		Resume        string         `gorm:"type=varchar(200)"`
+		Phone         sql.NullString `gorm:"unique"`
+		WechatOpenId  sql.NullString `gorm:"unique"`
+		WechatUnionId sql.NullString
+		Ctime         int64
+		Utime         int64
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "webook.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&User{}))
+	require.NoError(t, db.Create(&User{Email: sql.NullString{String: "a@qq.com", Valid: true}}).Error)
+
+	migrateUp(t, db)
+	assert.True(t, db.Migrator().HasColumn("users", "avatar"))
+	assert.True(t, db.Migrator().HasColumn("users", "locale"))
+	u, err := NewUserDao(db).FindByEmail(context.Background(), "a@qq.com")
+	require.NoError(t, err)
+	assert.Equal(t, "", u.Avatar)
+}
+
+func TestGORMUserDao_Deactivate(t *testing.T) {
+	db := newTestDB(t)
+	dao := NewUserDao(db)
+	ctx := context.Background()
+
+	id, err := dao.Insert(ctx, User{
+		Email:        sql.NullString{String: "a@qq.com", Valid: true},
+		Phone:        sql.NullString{String: "15212345678", Valid: true},
+		WechatOpenId: sql.NullString{String: "open-1", Valid: true},
+		Nickname:     "大明",
+	})
+	require.NoError(t, err)
+	require.NoError(t, NewWechatTokenDao(db).Upsert(ctx, WechatToken{Uid: id, OpenId: "open-1"}))
+	authEvents := NewAuthEventDao(db)
+	require.NoError(t, authEvents.BatchInsert(ctx, []AuthEvent{
+		{Uid: id, Ip: "127.0.0.1", UserAgent: "Chrome", Success: true},
+		{Uid: id + 1, Ip: "127.0.0.2", UserAgent: "Safari", Success: true},
+	}))
+
+	require.NoError(t, dao.Deactivate(ctx, id, 1000))
+	u, err := dao.FindById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, UserStatusDeactivated, u.Status)
+	assert.Equal(t, int64(1000), u.DeactivatedAt)
+
+	// 冷静期从 2000 开始算，1000 注销的已经过了，不能恢复
+	assert.Equal(t, ErrRecordNotFound, dao.Reactivate(ctx, id, 2000))
+	require.NoError(t, dao.Reactivate(ctx, id, 500))
+	u, err = dao.FindById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, UserStatusActive, u.Status)
+
+	// 正常的账号不会被清除
+	assert.Equal(t, ErrRecordNotFound, dao.Anonymize(ctx, id))
+	u, err = dao.FindById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "大明", u.Nickname)
+
+	require.NoError(t, dao.Deactivate(ctx, id, 1000))
+	us, err := dao.FindDeactivated(ctx, 1001, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, us, 1)
+	us, err = dao.FindDeactivated(ctx, 1000, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, us, 0)
+
+	require.NoError(t, dao.Anonymize(ctx, id))
+	u, err = dao.FindById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, UserStatusDeleted, u.Status)
+	assert.False(t, u.Email.Valid)
+	assert.False(t, u.Phone.Valid)
+	assert.False(t, u.WechatOpenId.Valid)
+	assert.Equal(t, "", u.Nickname)
+	_, err = NewWechatTokenDao(db).FindByUid(ctx, id)
+	assert.Equal(t, ErrRecordNotFound, err)
+	// 登录记录一起删掉，别人的不动
+	events, err := authEvents.FindByUid(ctx, id, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 0)
+	events, err = authEvents.FindByUid(ctx, id+1, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	// 手机号释放了，可以注册新账号
+	_, err = dao.Insert(ctx, User{Phone: sql.NullString{String: "15212345678", Valid: true}})
+	assert.NoError(t, err)
+}
+
+func TestGORMUserDao_UpdateProfile(t *testing.T) {
+	db := newTestDB(t)
+	dao := NewUserDao(db)
+	ctx := context.Background()
+
+	id, err := dao.Insert(ctx, User{Nickname: "大明", Resume: "简介"})
+	require.NoError(t, err)
+	u, err := dao.FindById(ctx, id)
+	require.NoError(t, err)
+
+	utime, err := dao.UpdateProfile(ctx, id, u.Utime, map[string]any{"location": "深圳", "gender": 1})
+	require.NoError(t, err)
+	assert.Greater(t, utime, u.Utime)
+	u, err = dao.FindById(ctx, id)
+	require.NoError(t, err)
+	// 没带的字段不变
+	assert.Equal(t, "大明", u.Nickname)
+	assert.Equal(t, "简介", u.Resume)
+	assert.Equal(t, "深圳", u.Location)
+	assert.Equal(t, uint8(1), u.Gender)
+	assert.Equal(t, utime, u.Utime)
+
+	// 拿着旧的 utime 来改，说明没看到上一次的修改
+	_, err = dao.UpdateProfile(ctx, id, utime-1, map[string]any{"nickname": "小明"})
+	assert.Equal(t, ErrUserConflict, err)
+	_, err = dao.UpdateProfile(ctx, id, utime, map[string]any{"nickname": "小明"})
+	assert.NoError(t, err)
+}
+
+func TestGORMUserDao_UpdateHandle(t *testing.T) {
+	db := newTestDB(t)
+	dao := NewUserDao(db)
+	ctx := context.Background()
+
+	id1, err := dao.Insert(ctx, User{Nickname: "大明"})
+	require.NoError(t, err)
+	id2, err := dao.Insert(ctx, User{Nickname: "小明"})
+	require.NoError(t, err)
+
+	// 第一次设置，handle_utime 是 0
+	before := time.Now().Add(-time.Hour).UnixMilli()
+	require.NoError(t, dao.UpdateHandle(ctx, id1, "daming", before))
+	u, err := dao.FindByHandle(ctx, "daming")
+	require.NoError(t, err)
+	assert.Equal(t, id1, u.Id)
+	assert.Greater(t, u.HandleUtime, before)
+
+	// 冷却期内再改
+	assert.Equal(t, ErrRecordNotFound, dao.UpdateHandle(ctx, id1, "daming2", before))
+	// 被别人占用了
+	assert.Equal(t, ErrDuplicateHandle, dao.UpdateHandle(ctx, id2, "daming", before))
+
+	// 注销清除之后用户名就释放了
+	require.NoError(t, dao.Deactivate(ctx, id1, time.Now().UnixMilli()))
+	require.NoError(t, dao.Anonymize(ctx, id1))
+	_, err = dao.FindByHandle(ctx, "daming")
+	assert.Equal(t, ErrRecordNotFound, err)
+	assert.NoError(t, dao.UpdateHandle(ctx, id2, "daming", before))
+}
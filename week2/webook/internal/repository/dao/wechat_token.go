@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type WechatTokenDao interface {
+	// Upsert 一个用户只保留一份最新的凭证
+	Upsert(ctx context.Context, t WechatToken) error
+	FindByUid(ctx context.Context, uid int64) (WechatToken, error)
+	// FindExpiring 找出 access_token 在 deadline 之前过期的凭证，按 uid 从小到大分批，每批从 startUid 之后开始
+	FindExpiring(ctx context.Context, deadline int64, startUid int64, limit int) ([]WechatToken, error)
+}
+
+type GORMWechatTokenDao struct {
+	db *gorm.DB
+}
+
+func NewWechatTokenDao(db *gorm.DB) WechatTokenDao {
+	return &GORMWechatTokenDao{
+		db: db,
+	}
+}
+
+func (dao *GORMWechatTokenDao) Upsert(ctx context.Context, t WechatToken) error {
+	now := time.Now().UnixMilli()
+	t.Ctime = now
+	t.Utime = now
+	// uid 冲突就更新凭证（MySQL 的 INSERT ... ON DUPLICATE KEY UPDATE）
+	return withCtx(ctx, dao.db).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "uid"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"app":           t.App,
+			"open_id":       t.OpenId,
+			"access_token":  t.AccessToken,
+			"refresh_token": t.RefreshToken,
+			"scope":         t.Scope,
+			"expires_at":    t.ExpiresAt,
+			"utime":         now,
+		}),
+	}).Create(&t).Error
+}
+
+func (dao *GORMWechatTokenDao) FindByUid(ctx context.Context, uid int64) (WechatToken, error) {
+	var t WechatToken
+	err := withCtx(ctx, dao.db).Where("uid=?", uid).First(&t).Error
+	return t, err
+}
+
+func (dao *GORMWechatTokenDao) FindExpiring(ctx context.Context, deadline int64, startUid int64, limit int) ([]WechatToken, error) {
+	var res []WechatToken
+	err := withCtx(ctx, dao.db).Where("expires_at<? AND uid>?", deadline, startUid).
+		Order("uid").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// WechatToken 用户的微信凭证，access_token 和 refresh_token 都是加密后的密文
+type WechatToken struct {
+	Id           int64  `gorm:"primaryKey,autoIncrement"`
+	Uid          int64  `gorm:"unique"`
+	App          string `gorm:"type:varchar(32)"`
+	OpenId       string `gorm:"type:varchar(128)"`
+	AccessToken  string `gorm:"type:varchar(1024)"`
+	RefreshToken string `gorm:"type:varchar(1024)"`
+	Scope        string `gorm:"type:varchar(128)"`
+	// access_token 的过期时间，UTC 0 的毫秒数
+	ExpiresAt int64 `gorm:"index"`
+
+	Ctime int64
+	Utime int64
+}
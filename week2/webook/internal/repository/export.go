@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository/cache"
+	"context"
+	"errors"
+)
+
+// ErrUserExportNotFound 没有这个导出，或者已经过期了。
+// 不直接用 cache.ErrKeyNotExist，它就是 redis.Nil，别的地方漏出来的 redis.Nil 不能当成导出不存在
+var ErrUserExportNotFound = errors.New("导出不存在")
+
+type UserExportRepository interface {
+	Save(ctx context.Context, t domain.UserExport) error
+	FindById(ctx context.Context, id string) (domain.UserExport, error)
+	FindLatest(ctx context.Context, uid int64) (domain.UserExport, error)
+	SaveArchive(ctx context.Context, id string, data []byte) error
+	FindArchive(ctx context.Context, id string) ([]byte, error)
+}
+
+type CachedUserExportRepository struct {
+	cache cache.UserExportCache
+}
+
+func NewUserExportRepository(c cache.UserExportCache) UserExportRepository {
+	return &CachedUserExportRepository{
+		cache: c,
+	}
+}
+
+func (repo *CachedUserExportRepository) Save(ctx context.Context, t domain.UserExport) error {
+	return repo.cache.SetTask(ctx, t)
+}
+
+func (repo *CachedUserExportRepository) FindById(ctx context.Context, id string) (domain.UserExport, error) {
+	t, err := repo.cache.GetTask(ctx, id)
+	return t, repo.translate(err)
+}
+
+func (repo *CachedUserExportRepository) FindLatest(ctx context.Context, uid int64) (domain.UserExport, error) {
+	t, err := repo.cache.GetLatest(ctx, uid)
+	return t, repo.translate(err)
+}
+
+func (repo *CachedUserExportRepository) SaveArchive(ctx context.Context, id string, data []byte) error {
+	return repo.cache.SetArchive(ctx, id, data)
+}
+
+func (repo *CachedUserExportRepository) FindArchive(ctx context.Context, id string) ([]byte, error) {
+	data, err := repo.cache.GetArchive(ctx, id)
+	return data, repo.translate(err)
+}
+
+func (repo *CachedUserExportRepository) translate(err error) error {
+	if errors.Is(err, cache.ErrKeyNotExist) {
+		return ErrUserExportNotFound
+	}
+	return err
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository/cache"
+	"basic-go/week2/webook/internal/repository/dao"
+	"context"
+	"errors"
+	"log"
+)
+
+type InteractiveRepository interface {
+	IncrReadCnt(ctx context.Context, biz string, bizId int64) error
+	// Like 点赞，重复点赞不会重复计数
+	Like(ctx context.Context, biz string, bizId int64, uid int64) error
+	// CancelLike 取消点赞，没点过的什么也不做
+	CancelLike(ctx context.Context, biz string, bizId int64, uid int64) error
+	Collect(ctx context.Context, biz string, bizId int64, uid int64) error
+	CancelCollect(ctx context.Context, biz string, bizId int64, uid int64) error
+	// Get 只有计数，还没人看过的计数都是 0
+	Get(ctx context.Context, biz string, bizId int64) (domain.Interactive, error)
+	// GetByIds 列表页用，每个 id 都有，还没人看过的计数都是 0
+	GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error)
+	Liked(ctx context.Context, biz string, bizId int64, uid int64) (bool, error)
+	Collected(ctx context.Context, biz string, bizId int64, uid int64) (bool, error)
+}
+
+// CachedInteractiveRepository 计数走缓存，缓存策略和 CachedUserRepository 不一样：
+// 1. 写：先更新数据库，再在缓存上加减（缓存里有的话），不删缓存。
+// 计数允许有一点误差，并发的读请求把旧的计数写回缓存了也只是差几个，过期之后就对了
+// 2. 读：缓存没有就查数据库，写回缓存
+// 3. 谁点赞、收藏了不缓存，只在看详情的时候查一次，走唯一索引
+type CachedInteractiveRepository struct {
+	dao   dao.InteractiveDao
+	cache cache.InteractiveCache
+}
+
+func NewInteractiveRepository(d dao.InteractiveDao, c cache.InteractiveCache) InteractiveRepository {
+	return &CachedInteractiveRepository{
+		dao:   d,
+		cache: c,
+	}
+}
+
+// 数据库已经改成功了，缓存改失败了打日志，靠过期时间兜底
+
+func (repo *CachedInteractiveRepository) IncrReadCnt(ctx context.Context, biz string, bizId int64) error {
+	if err := repo.dao.IncrReadCnt(ctx, biz, bizId); err != nil {
+		return err
+	}
+	if err := repo.cache.IncrReadCntIfPresent(ctx, biz, bizId); err != nil {
+		log.Println("更新阅读数缓存失败", biz, bizId, err)
+	}
+	return nil
+}
+
+func (repo *CachedInteractiveRepository) Like(ctx context.Context, biz string, bizId int64, uid int64) error {
+	changed, err := repo.dao.InsertLikeInfo(ctx, biz, bizId, uid)
+	return repo.incrLikeCnt(ctx, biz, bizId, changed, err, 1)
+}
+
+func (repo *CachedInteractiveRepository) CancelLike(ctx context.Context, biz string, bizId int64, uid int64) error {
+	changed, err := repo.dao.DeleteLikeInfo(ctx, biz, bizId, uid)
+	return repo.incrLikeCnt(ctx, biz, bizId, changed, err, -1)
+}
+
+func (repo *CachedInteractiveRepository) incrLikeCnt(ctx context.Context, biz string, bizId int64,
+	changed bool, err error, delta int64) error {
+	// 没变就不用动缓存，不然重复点赞缓存会多算
+	if err != nil || !changed {
+		return err
+	}
+	if err := repo.cache.IncrLikeCntIfPresent(ctx, biz, bizId, delta); err != nil {
+		log.Println("更新点赞数缓存失败", biz, bizId, err)
+	}
+	return nil
+}
+
+func (repo *CachedInteractiveRepository) Collect(ctx context.Context, biz string, bizId int64, uid int64) error {
+	changed, err := repo.dao.InsertCollectionInfo(ctx, biz, bizId, uid)
+	return repo.incrCollectCnt(ctx, biz, bizId, changed, err, 1)
+}
+
+func (repo *CachedInteractiveRepository) CancelCollect(ctx context.Context, biz string, bizId int64, uid int64) error {
+	changed, err := repo.dao.DeleteCollectionInfo(ctx, biz, bizId, uid)
+	return repo.incrCollectCnt(ctx, biz, bizId, changed, err, -1)
+}
+
+func (repo *CachedInteractiveRepository) incrCollectCnt(ctx context.Context, biz string, bizId int64,
+	changed bool, err error, delta int64) error {
+	if err != nil || !changed {
+		return err
+	}
+	if err := repo.cache.IncrCollectCntIfPresent(ctx, biz, bizId, delta); err != nil {
+		log.Println("更新收藏数缓存失败", biz, bizId, err)
+	}
+	return nil
+}
+
+func (repo *CachedInteractiveRepository) Get(ctx context.Context, biz string, bizId int64) (domain.Interactive, error) {
+	intr, err := repo.cache.Get(ctx, biz, bizId)
+	if err == nil {
+		return intr, nil
+	}
+	// 缓存没有或者 redis 出错了都查数据库
+	ie, err := repo.dao.Get(ctx, biz, bizId)
+	switch {
+	case err == nil:
+		intr = repo.toDomain(ie)
+	case errors.Is(err, dao.ErrRecordNotFound):
+		// 还没人看过，也写进缓存，之后的阅读、点赞直接在缓存上加
+		intr = domain.Interactive{Biz: biz, BizId: bizId}
+	default:
+		return domain.Interactive{}, err
+	}
+	if err := repo.cache.Set(ctx, intr); err != nil {
+		log.Println("写计数缓存失败", biz, bizId, err)
+	}
+	return intr, nil
+}
+
+func (repo *CachedInteractiveRepository) GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error) {
+	res, err := repo.cache.GetByIds(ctx, biz, bizIds)
+	if err != nil {
+		// redis 出错了全部查数据库
+		log.Println("批量查询计数缓存失败", biz, err)
+		res = make(map[int64]domain.Interactive, len(bizIds))
+	}
+	missed := make([]int64, 0, len(bizIds)-len(res))
+	for _, id := range bizIds {
+		if _, ok := res[id]; !ok {
+			missed = append(missed, id)
+		}
+	}
+	if len(missed) == 0 {
+		return res, nil
+	}
+	ies, err := repo.dao.GetByIds(ctx, biz, missed)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range missed {
+		res[id] = domain.Interactive{Biz: biz, BizId: id}
+	}
+	for _, ie := range ies {
+		res[ie.BizId] = repo.toDomain(ie)
+	}
+	loaded := make([]domain.Interactive, 0, len(missed))
+	for _, id := range missed {
+		loaded = append(loaded, res[id])
+	}
+	if err := repo.cache.SetBatch(ctx, loaded); err != nil {
+		log.Println("批量写计数缓存失败", biz, err)
+	}
+	return res, nil
+}
+
+func (repo *CachedInteractiveRepository) Liked(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return repo.dao.Liked(ctx, biz, bizId, uid)
+}
+
+func (repo *CachedInteractiveRepository) Collected(ctx context.Context, biz string, bizId int64, uid int64) (bool, error) {
+	return repo.dao.Collected(ctx, biz, bizId, uid)
+}
+
+func (repo *CachedInteractiveRepository) toDomain(ie dao.Interactive) domain.Interactive {
+	return domain.Interactive{
+		Biz:        ie.Biz,
+		BizId:      ie.BizId,
+		ReadCnt:    ie.ReadCnt,
+		LikeCnt:    ie.LikeCnt,
+		CollectCnt: ie.CollectCnt,
+	}
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	cachemocks "basic-go/week2/webook/internal/repository/cache/mocks"
+	"basic-go/week2/webook/internal/repository/dao"
+	daomocks "basic-go/week2/webook/internal/repository/dao/mocks"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+)
+
+func TestCachedInteractiveRepository_GetByIds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d := daomocks.NewMockInteractiveDao(ctrl)
+	c := cachemocks.NewMockInteractiveCache(ctrl)
+	c.EXPECT().GetByIds(gomock.Any(), "article", []int64{1, 2, 3}).Return(map[int64]domain.Interactive{
+		1: {Biz: "article", BizId: 1, ReadCnt: 10},
+	}, nil)
+	// 缓存没有的查数据库，数据库也没有的是 0，都写回缓存
+	d.EXPECT().GetByIds(gomock.Any(), "article", []int64{2, 3}).Return([]dao.Interactive{
+		{Biz: "article", BizId: 2, ReadCnt: 5, LikeCnt: 1},
+	}, nil)
+	c.EXPECT().SetBatch(gomock.Any(), []domain.Interactive{
+		{Biz: "article", BizId: 2, ReadCnt: 5, LikeCnt: 1},
+		{Biz: "article", BizId: 3},
+	}).Return(nil)
+	repo := NewInteractiveRepository(d, c)
+	res, err := repo.GetByIds(context.Background(), "article", []int64{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]domain.Interactive{
+		1: {Biz: "article", BizId: 1, ReadCnt: 10},
+		2: {Biz: "article", BizId: 2, ReadCnt: 5, LikeCnt: 1},
+		3: {Biz: "article", BizId: 3},
+	}, res)
+}
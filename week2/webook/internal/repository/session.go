@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository/cache"
+	"context"
+)
+
+type SessionRepository interface {
+	// RevokeAll 让这个用户在所有设备上的登录都失效
+	RevokeAll(ctx context.Context, uid int64) error
+	// List 还有效的登录
+	List(ctx context.Context, uid int64) ([]domain.Session, error)
+	// LoginHistory 最近的登录历史，最新的在前面
+	LoginHistory(ctx context.Context, uid int64) ([]domain.Session, error)
+}
+
+type CachedSessionRepository struct {
+	cache cache.SessionCache
+}
+
+func NewSessionRepository(c cache.SessionCache) SessionRepository {
+	return &CachedSessionRepository{
+		cache: c,
+	}
+}
+
+func (repo *CachedSessionRepository) RevokeAll(ctx context.Context, uid int64) error {
+	_, err := repo.cache.RevokeAll(ctx, uid)
+	return err
+}
+
+func (repo *CachedSessionRepository) List(ctx context.Context, uid int64) ([]domain.Session, error) {
+	return repo.cache.List(ctx, uid)
+}
+
+func (repo *CachedSessionRepository) LoginHistory(ctx context.Context, uid int64) ([]domain.Session, error) {
+	return repo.cache.LoginHistory(ctx, uid)
+}
@@ -4,17 +4,28 @@ import (
 	"basic-go/week2/webook/internal/domain"
 	"basic-go/week2/webook/internal/repository/cache"
 	"basic-go/week2/webook/internal/repository/dao"
+	"basic-go/week2/webook/pkg/limiter"
 	"context"
 	"database/sql"
+	"errors"
+	"golang.org/x/sync/singleflight"
+	"log"
+	"strconv"
 	"time"
 )
 
 // ErrDuplicateEmail 小技巧：如果dao层返回了这个err，则service层可直接从repo层调用来进行判定
 var (
-	ErrDuplicateEmail = dao.ErrDuplicateEmail
-	ErrDuplicatePhone = dao.ErrDuplicatePhone
+	ErrDuplicateEmail  = dao.ErrDuplicateEmail
+	ErrDuplicatePhone  = dao.ErrDuplicatePhone
+	ErrDuplicateWechat = dao.ErrDuplicateWechat
+	ErrDuplicateHandle = dao.ErrDuplicateHandle
 	// ErrUserNotFound 得重新命名为 User 相关的，因为Service在通过repo层调用时是在具体业务中的（如User业务，而不能用Record）
 	ErrUserNotFound = dao.ErrRecordNotFound
+	// ErrUserConflict 资料已经被别人改过了
+	ErrUserConflict = dao.ErrUserConflict
+	// ErrDBFallbackLimited redis 不可用的时候，查数据库的请求太多被限流了
+	ErrDBFallbackLimited = errors.New("缓存不可用，查询数据库被限流")
 )
 
 type UserRepository interface {
@@ -23,23 +34,62 @@ type UserRepository interface {
 	FindByPhone(ctx context.Context, phone string) (domain.User, error)
 	FindById(ctx context.Context, uid int64) (domain.User, error)
 	UpdateNonZeroFields(ctx context.Context, user domain.User) error
+	UpdateLocale(ctx context.Context, uid int64, locale string) error
+	UpdateAvatar(ctx context.Context, uid int64, avatar string) error
+	// UpdateHandle 改用户名，上次改是在 before 之后的话返回 ErrUserNotFound
+	UpdateHandle(ctx context.Context, uid int64, handle string, before time.Time) error
+	UpdatePrivacy(ctx context.Context, uid int64, privacy domain.UserPrivacy) error
+	// UpdateProfile 只改 patch 里不是 nil 的字段，返回新的更新时间。资料在 patch.Utime 之后被改过就返回 ErrUserConflict
+	UpdateProfile(ctx context.Context, uid int64, patch domain.UserProfilePatch) (time.Time, error)
 	FindByWechat(ctx context.Context, openId string) (domain.User, error)
+	// FindByHandle 不走缓存，缓存是按 id 存的
+	FindByHandle(ctx context.Context, handle string) (domain.User, error)
+	Deactivate(ctx context.Context, uid int64, at time.Time) error
+	// Reactivate 恢复在 since 之后注销的账号，已经过了冷静期返回 ErrUserNotFound
+	Reactivate(ctx context.Context, uid int64, since time.Time) error
+	// FindDeactivated 按 id 从小到大分批找出在 before 之前注销、还没清除个人信息的账号
+	FindDeactivated(ctx context.Context, before time.Time, startId int64, limit int) ([]domain.User, error)
+	// Anonymize 清除注销账号的个人信息，账号已经恢复了返回 ErrUserNotFound
+	Anonymize(ctx context.Context, uid int64) error
 }
 
+// CachedUserRepository FindById 走缓存，缓存策略：
+// 1. 写：先更新数据库，再删缓存，过一会儿再删一次（延迟双删）。
+// 第二次删是为了防止并发读：读请求在更新之前查到了旧数据，却在第一次删缓存之后才写回缓存
+// 2. 读：数据库出错不写缓存；用户不存在就写一个很短的负缓存，防止缓存穿透
+// 3. 重建：同一个实例里的并发请求用 singleflight 合并，不同实例之间用 redis 里的锁，
+// 只让一个请求去查数据库，防止热点用户的缓存过期的时候大量请求同时打到数据库（缓存击穿）
+// 4. 降级：redis 不可用（出错或者熔断了，见 cache.BreakerUserCache）的时候，本地缓存（见 cache.MultiLevelUserCache）也没有，
+// 就查数据库，查数据库要先从令牌桶拿令牌，拿不到就返回 ErrDBFallbackLimited，不能让数据库跟着被打挂
 type CachedUserRepository struct {
 	dao   dao.UserDao
 	cache cache.UserCache
+	// 第二次删缓存前等多久，要比一次"查数据库+写缓存"的耗时长
+	doubleDeleteDelay time.Duration
+
+	group singleflight.Group
+	// 锁被别的实例拿着的时候，最多等几次、每次等多久，等不到就自己查数据库
+	lockRetries       int
+	lockRetryInterval time.Duration
+	// 限制 redis 不可用的时候每个实例查数据库的速度
+	dbLimiter *limiter.TokenBucket
 }
 
 func NewCachedUserRepository(d dao.UserDao, c cache.UserCache) UserRepository {
 	return &CachedUserRepository{
-		dao:   d,
-		cache: c,
+		dao:               d,
+		cache:             c,
+		doubleDeleteDelay: time.Millisecond * 500,
+		lockRetries:       5,
+		lockRetryInterval: time.Millisecond * 50,
+		dbLimiter:         limiter.NewTokenBucket(200, 200),
 	}
 }
 
+// Create 不用管负缓存：新用户的 id 是自增的，就算之前被人用这个 id 查过，负缓存最多一分钟也就过期了
 func (repo *CachedUserRepository) Create(ctx context.Context, u domain.User) error {
-	return repo.dao.Insert(ctx, toPersistent(u))
+	_, err := repo.dao.Insert(ctx, toPersistent(u))
+	return err
 }
 
 func (repo *CachedUserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
@@ -51,24 +101,242 @@ func (repo *CachedUserRepository) FindByEmail(ctx context.Context, email string)
 }
 
 func (repo *CachedUserRepository) UpdateNonZeroFields(ctx context.Context, user domain.User) error {
-	return repo.dao.UpdateById(ctx, toPersistent(user))
+	err := repo.dao.UpdateById(ctx, toPersistent(user))
+	if err != nil {
+		return err
+	}
+	repo.invalidate(ctx, user.Id)
+	return nil
+}
+
+func (repo *CachedUserRepository) UpdateProfile(ctx context.Context, uid int64, patch domain.UserProfilePatch) (time.Time, error) {
+	fields := make(map[string]any, 7)
+	if patch.Nickname != nil {
+		fields["nickname"] = *patch.Nickname
+	}
+	if patch.Birthday != nil {
+		// 清空生日存 0，time.Time{}.UnixMilli() 是个很大的负数
+		var birthday int64
+		if !patch.Birthday.IsZero() {
+			birthday = patch.Birthday.UnixMilli()
+		}
+		fields["birthday"] = birthday
+	}
+	if patch.Resume != nil {
+		fields["resume"] = *patch.Resume
+	}
+	if patch.Gender != nil {
+		fields["gender"] = uint8(*patch.Gender)
+	}
+	if patch.Location != nil {
+		fields["location"] = *patch.Location
+	}
+	if patch.Website != nil {
+		fields["website"] = *patch.Website
+	}
+	if patch.Avatar != nil {
+		fields["avatar"] = *patch.Avatar
+	}
+	utime, err := repo.dao.UpdateProfile(ctx, uid, patch.Utime.UnixMilli(), fields)
+	if err != nil {
+		return time.Time{}, err
+	}
+	repo.invalidate(ctx, uid)
+	return time.UnixMilli(utime), nil
+}
+
+func (repo *CachedUserRepository) UpdateAvatar(ctx context.Context, uid int64, avatar string) error {
+	err := repo.dao.UpdateAvatar(ctx, uid, avatar)
+	if err != nil {
+		return err
+	}
+	repo.invalidate(ctx, uid)
+	return nil
+}
+
+func (repo *CachedUserRepository) UpdateHandle(ctx context.Context, uid int64, handle string, before time.Time) error {
+	err := repo.dao.UpdateHandle(ctx, uid, handle, before.UnixMilli())
+	if err != nil {
+		return err
+	}
+	repo.invalidate(ctx, uid)
+	return nil
+}
+
+func (repo *CachedUserRepository) UpdatePrivacy(ctx context.Context, uid int64, privacy domain.UserPrivacy) error {
+	err := repo.dao.UpdatePrivacy(ctx, uid, privacy.HideBirthday, privacy.HideResume)
+	if err != nil {
+		return err
+	}
+	repo.invalidate(ctx, uid)
+	return nil
+}
+
+func (repo *CachedUserRepository) UpdateLocale(ctx context.Context, uid int64, locale string) error {
+	err := repo.dao.UpdateLocale(ctx, uid, locale)
+	if err != nil {
+		return err
+	}
+	// 中间件每个请求都会通过 FindById 读用户的语言，不删缓存的话要等缓存过期才生效
+	repo.invalidate(ctx, uid)
+	return nil
+}
+
+func (repo *CachedUserRepository) Deactivate(ctx context.Context, uid int64, at time.Time) error {
+	err := repo.dao.Deactivate(ctx, uid, at.UnixMilli())
+	if err != nil {
+		return err
+	}
+	repo.invalidate(ctx, uid)
+	return nil
+}
+
+func (repo *CachedUserRepository) Reactivate(ctx context.Context, uid int64, since time.Time) error {
+	err := repo.dao.Reactivate(ctx, uid, since.UnixMilli())
+	if err != nil {
+		return err
+	}
+	repo.invalidate(ctx, uid)
+	return nil
+}
+
+func (repo *CachedUserRepository) FindDeactivated(ctx context.Context, before time.Time,
+	startId int64, limit int) ([]domain.User, error) {
+	us, err := repo.dao.FindDeactivated(ctx, before.UnixMilli(), startId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.User, 0, len(us))
+	for _, u := range us {
+		res = append(res, toDomain(u))
+	}
+	return res, nil
+}
+
+func (repo *CachedUserRepository) Anonymize(ctx context.Context, uid int64) error {
+	err := repo.dao.Anonymize(ctx, uid)
+	if err != nil {
+		return err
+	}
+	repo.invalidate(ctx, uid)
+	return nil
+}
+
+// invalidate 数据库已经更新成功了，删缓存失败也不能让这次更新失败，打个日志，靠第二次删和过期时间兜底
+func (repo *CachedUserRepository) invalidate(ctx context.Context, uid int64) {
+	if err := repo.cache.Delete(ctx, uid); err != nil {
+		log.Println("删除用户缓存失败", uid, err)
+	}
+	// note 这时候请求可能已经结束了，ctx 会被取消，所以第二次删要用新的 ctx
+	time.AfterFunc(repo.doubleDeleteDelay, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := repo.cache.Delete(ctx, uid); err != nil {
+			log.Println("延迟删除用户缓存失败", uid, err)
+		}
+	})
 }
 
 func (repo *CachedUserRepository) FindById(ctx context.Context, uid int64) (domain.User, error) {
+	du, hit, err := repo.getCached(ctx, uid)
+	if hit {
+		return du, err
+	}
+	// redis 不可用
+	degraded := !errors.Is(err, cache.ErrKeyNotExist)
+	// note singleflight 里用的是第一个请求的 ctx，它被取消了，合并进来的请求也会一起失败
+	val, err, _ := repo.group.Do(strconv.FormatInt(uid, 10), func() (any, error) {
+		if degraded {
+			return repo.findInDBLimited(ctx, uid)
+		}
+		return repo.rebuild(ctx, uid)
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	return val.(domain.User), nil
+}
+
+// getCached 查缓存，hit 为 true 说明缓存里有结论（用户或者用户不存在），不用再查数据库
+func (repo *CachedUserRepository) getCached(ctx context.Context, uid int64) (domain.User, bool, error) {
 	du, err := repo.cache.Get(ctx, uid)
-	if err == nil {
-		// 从缓存中查到了
-		return du, nil
+	switch {
+	case err == nil:
+		return du, true, nil
+	case errors.Is(err, cache.ErrUserNotFound):
+		// 负缓存，刚查过数据库，没有这个用户
+		return domain.User{}, true, ErrUserNotFound
+	default:
+		// 两种可能，调用方用 err 区分：
+		// 1） 缓存中没有key，但redis正常，err 是 cache.ErrKeyNotExist
+		// 2） 访问redis有问题。可能是连不上网，也可能redis本身崩了，或者已经熔断了
+		return domain.User{}, false, err
+	}
+}
+
+// rebuild 缓存没命中，抢锁去数据库查
+func (repo *CachedUserRepository) rebuild(ctx context.Context, uid int64) (domain.User, error) {
+	token, err := repo.cache.Lock(ctx, uid)
+	switch {
+	case err == nil:
+		defer func() {
+			// 解锁失败也没关系，锁很快会过期
+			_ = repo.cache.Unlock(ctx, uid, token)
+		}()
+		// 拿到锁的时候，上一个拿锁的可能刚重建完
+		if du, hit, err := repo.getCached(ctx, uid); hit {
+			return du, err
+		}
+	case errors.Is(err, cache.ErrLockHeld):
+		// 别的实例正在重建，等它写进缓存
+		if du, hit, err := repo.waitRebuild(ctx, uid); hit {
+			return du, err
+		}
+		// 等太久了，宁可自己多查一次数据库，也不能让请求失败
+	default:
+		// redis 有问题，走降级
+		return repo.findInDBLimited(ctx, uid)
+	}
+	return repo.findInDB(ctx, uid)
+}
+
+// findInDBLimited redis 不可用时查数据库，要先拿到令牌
+func (repo *CachedUserRepository) findInDBLimited(ctx context.Context, uid int64) (domain.User, error) {
+	if !repo.dbLimiter.Allow() {
+		return domain.User{}, ErrDBFallbackLimited
 	}
+	return repo.findInDB(ctx, uid)
+}
+
+func (repo *CachedUserRepository) waitRebuild(ctx context.Context, uid int64) (domain.User, bool, error) {
+	for i := 0; i < repo.lockRetries; i++ {
+		select {
+		case <-ctx.Done():
+			return domain.User{}, true, ctx.Err()
+		case <-time.After(repo.lockRetryInterval):
+		}
+		if du, hit, err := repo.getCached(ctx, uid); hit {
+			return du, true, err
+		}
+	}
+	return domain.User{}, false, nil
+}
 
-	// TODO err不为nil有多种可能：
-	// 1） 缓存中没有key，但redis正常
-	// 2） 访问redis有问题。可能是连不上网，也可能redis本身崩了
+// findInDB 查数据库并写缓存
+func (repo *CachedUserRepository) findInDB(ctx context.Context, uid int64) (domain.User, error) {
 	u, err := repo.dao.FindById(ctx, uid)
-	// 将du存入缓存
-	du = toDomain(u)
+	if errors.Is(err, dao.ErrRecordNotFound) {
+		// 写失败了也没关系，只是下次还要查数据库
+		_ = repo.cache.SetNotFound(ctx, uid)
+		return domain.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		// 数据库出错了，不能把空的用户写进缓存
+		return domain.User{}, err
+	}
+	du := toDomain(u)
+	// 可以不处理err，因为这次没存进缓存，下次直接查数据库就行了。而且处理了err，也只说明连接redis的网络和本身有问题，无法解决。
 	_ = repo.cache.Set(ctx, du)
-	// TODO 可以不接收err，因为这次没存进缓存，下次直接查数据库就行了。而且接受了err，也只说明连接redis的网络和本身有问题，无法解决。
 	return du, nil
 }
 
@@ -88,9 +356,17 @@ func (repo *CachedUserRepository) FindByWechat(ctx context.Context, openId strin
 	return toDomain(u), nil
 }
 
+func (repo *CachedUserRepository) FindByHandle(ctx context.Context, handle string) (domain.User, error) {
+	u, err := repo.dao.FindByHandle(ctx, handle)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return toDomain(u), nil
+}
+
 // 私有方法（首字母小写）
 func toDomain(u dao.User) domain.User {
-	return domain.User{
+	res := domain.User{
 		Id:       u.Id,
 		Email:    u.Email.String,
 		Phone:    u.Phone.String,
@@ -99,13 +375,34 @@ func toDomain(u dao.User) domain.User {
 		// UTC 0的毫秒 -> time
 		Birthday: time.UnixMilli(u.Birthday),
 		Resume:   u.Resume,
+		Avatar:   u.Avatar,
+		Gender:   domain.Gender(u.Gender),
+		Location: u.Location,
+		Website:  u.Website,
+		Locale:   u.Locale,
+		Handle:   u.Handle.String,
+		Privacy: domain.UserPrivacy{
+			HideBirthday: u.HideBirthday,
+			HideResume:   u.HideResume,
+		},
 		// UTC 0的毫秒 -> time
 		Ctime: time.UnixMilli(u.Ctime),
 		WechatInfo: domain.WechatInfo{
 			OpenId:  u.WechatOpenId.String,
 			UnionId: u.WechatUnionId.String,
 		},
+		Status: domain.UserStatus(u.Status),
+	}
+	if u.Utime > 0 {
+		res.Utime = time.UnixMilli(u.Utime)
+	}
+	if u.HandleUtime > 0 {
+		res.HandleUtime = time.UnixMilli(u.HandleUtime)
 	}
+	if u.DeactivatedAt > 0 {
+		res.DeactivatedAt = time.UnixMilli(u.DeactivatedAt)
+	}
+	return res
 }
 
 func toPersistent(u domain.User) dao.User {
@@ -123,6 +420,17 @@ func toPersistent(u domain.User) dao.User {
 		Nickname: u.Nickname,
 		Birthday: u.Birthday.UnixMilli(),
 		Resume:   u.Resume,
+		Avatar:   u.Avatar,
+		Gender:   uint8(u.Gender),
+		Location: u.Location,
+		Website:  u.Website,
+		Locale:   u.Locale,
+		Handle: sql.NullString{
+			String: u.Handle,
+			Valid:  u.Handle != "",
+		},
+		HideBirthday: u.Privacy.HideBirthday,
+		HideResume:   u.Privacy.HideResume,
 		WechatOpenId: sql.NullString{
 			String: u.WechatInfo.OpenId,
 			Valid:  u.WechatInfo.OpenId != "",
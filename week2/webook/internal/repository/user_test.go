@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository/cache"
+	cachemocks "basic-go/week2/webook/internal/repository/cache/mocks"
+	"basic-go/week2/webook/internal/repository/dao"
+	daomocks "basic-go/week2/webook/internal/repository/dao/mocks"
+	"basic-go/week2/webook/pkg/limiter"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCachedUserRepository_FindById(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache)
+
+		wantUser domain.User
+		wantErr  error
+	}{
+		{
+			name: "缓存命中",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Nickname: "缓存"}, nil)
+				return daomocks.NewMockUserDao(ctrl), c
+			},
+			wantUser: domain.User{Id: 1, Nickname: "缓存"},
+		},
+		{
+			name: "缓存没有，查数据库并写缓存",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				d := daomocks.NewMockUserDao(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Times(2).Return(domain.User{}, cache.ErrKeyNotExist)
+				c.EXPECT().Lock(gomock.Any(), int64(1)).Return("token", nil)
+				d.EXPECT().FindById(gomock.Any(), int64(1)).
+					Return(dao.User{Id: 1, Nickname: "数据库", Birthday: now.UnixMilli(), Ctime: now.UnixMilli()}, nil)
+				c.EXPECT().Set(gomock.Any(), domain.User{Id: 1, Nickname: "数据库", Birthday: now, Ctime: now}).Return(nil)
+				c.EXPECT().Unlock(gomock.Any(), int64(1), "token").Return(nil)
+				return d, c
+			},
+			wantUser: domain.User{Id: 1, Nickname: "数据库", Birthday: now, Ctime: now},
+		},
+		{
+			name: "负缓存命中，不查数据库",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{}, cache.ErrUserNotFound)
+				return daomocks.NewMockUserDao(ctrl), c
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "用户不存在，写负缓存",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				d := daomocks.NewMockUserDao(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Times(2).Return(domain.User{}, cache.ErrKeyNotExist)
+				c.EXPECT().Lock(gomock.Any(), int64(1)).Return("token", nil)
+				d.EXPECT().FindById(gomock.Any(), int64(1)).Return(dao.User{}, dao.ErrRecordNotFound)
+				c.EXPECT().SetNotFound(gomock.Any(), int64(1)).Return(nil)
+				c.EXPECT().Unlock(gomock.Any(), int64(1), "token").Return(nil)
+				return d, c
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "数据库出错，不写缓存",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				d := daomocks.NewMockUserDao(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Times(2).Return(domain.User{}, cache.ErrKeyNotExist)
+				c.EXPECT().Lock(gomock.Any(), int64(1)).Return("token", nil)
+				c.EXPECT().Unlock(gomock.Any(), int64(1), "token").Return(nil)
+				d.EXPECT().FindById(gomock.Any(), int64(1)).Return(dao.User{}, errors.New("数据库出错"))
+				return d, c
+			},
+			wantErr: errors.New("数据库出错"),
+		},
+		{
+			name: "拿到锁的时候别人已经重建好了",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				gomock.InOrder(
+					c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{}, cache.ErrKeyNotExist),
+					c.EXPECT().Lock(gomock.Any(), int64(1)).Return("token", nil),
+					c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Nickname: "缓存"}, nil),
+					c.EXPECT().Unlock(gomock.Any(), int64(1), "token").Return(nil),
+				)
+				return daomocks.NewMockUserDao(ctrl), c
+			},
+			wantUser: domain.User{Id: 1, Nickname: "缓存"},
+		},
+		{
+			name: "锁被别人拿着，等别人重建",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				gomock.InOrder(
+					c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{}, cache.ErrKeyNotExist),
+					c.EXPECT().Lock(gomock.Any(), int64(1)).Return("", cache.ErrLockHeld),
+					c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{}, cache.ErrKeyNotExist),
+					c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Nickname: "别人重建的"}, nil),
+				)
+				return daomocks.NewMockUserDao(ctrl), c
+			},
+			wantUser: domain.User{Id: 1, Nickname: "别人重建的"},
+		},
+		{
+			name: "锁被别人拿着，等不到就自己查",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				d := daomocks.NewMockUserDao(ctrl)
+				// 第一次查加上重试的 5 次
+				c.EXPECT().Get(gomock.Any(), int64(1)).Times(6).Return(domain.User{}, cache.ErrKeyNotExist)
+				c.EXPECT().Lock(gomock.Any(), int64(1)).Return("", cache.ErrLockHeld)
+				d.EXPECT().FindById(gomock.Any(), int64(1)).Return(dao.User{Id: 1}, nil)
+				c.EXPECT().Set(gomock.Any(), gomock.Any()).Return(nil)
+				return d, c
+			},
+			wantUser: domain.User{Id: 1, Birthday: time.UnixMilli(0), Ctime: time.UnixMilli(0)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := NewCachedUserRepository(tc.mock(ctrl)).(*CachedUserRepository)
+			repo.lockRetryInterval = time.Millisecond
+			u, err := repo.FindById(context.Background(), 1)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
+
+func TestCachedUserRepository_FindById_Coalesce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d := daomocks.NewMockUserDao(ctrl)
+	c := cachemocks.NewMockUserCache(ctrl)
+	const n = 10
+	c.EXPECT().Get(gomock.Any(), int64(1)).AnyTimes().Return(domain.User{}, cache.ErrKeyNotExist)
+	c.EXPECT().Lock(gomock.Any(), int64(1)).Return("token", nil)
+	c.EXPECT().Unlock(gomock.Any(), int64(1), "token").Return(nil)
+	c.EXPECT().Set(gomock.Any(), gomock.Any()).Return(nil)
+	// 所有请求都在等的时候才返回，保证它们被合并成一次数据库查询
+	release := make(chan struct{})
+	d.EXPECT().FindById(gomock.Any(), int64(1)).Times(1).DoAndReturn(func(ctx context.Context, id int64) (dao.User, error) {
+		<-release
+		return dao.User{Id: 1}, nil
+	})
+	repo := NewCachedUserRepository(d, c)
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			u, err := repo.FindById(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), u.Id)
+		}()
+	}
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+	wg.Wait()
+}
+
+func TestCachedUserRepository_FindById_Degraded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d := daomocks.NewMockUserDao(ctrl)
+	c := cachemocks.NewMockUserCache(ctrl)
+	// redis 一直不可用，写缓存也失败
+	c.EXPECT().Get(gomock.Any(), gomock.Any()).AnyTimes().Return(domain.User{}, cache.ErrCacheUnavailable)
+	c.EXPECT().Set(gomock.Any(), gomock.Any()).AnyTimes().Return(cache.ErrCacheUnavailable)
+	d.EXPECT().FindById(gomock.Any(), int64(1)).Times(1).Return(dao.User{Id: 1, Nickname: "数据库"}, nil)
+	repo := NewCachedUserRepository(d, c).(*CachedUserRepository)
+	// 每秒 1 个令牌，桶里只有 1 个
+	repo.dbLimiter = limiter.NewTokenBucket(1, 1)
+
+	// 第一次查数据库，用掉了唯一的令牌
+	u, err := repo.FindById(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "数据库", u.Nickname)
+	// 令牌没了，被限流
+	_, err = repo.FindById(context.Background(), 2)
+	assert.Equal(t, ErrDBFallbackLimited, err)
+}
+
+func TestCachedUserRepository_UpdateNonZeroFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d := daomocks.NewMockUserDao(ctrl)
+	c := cachemocks.NewMockUserCache(ctrl)
+	d.EXPECT().UpdateById(gomock.Any(), gomock.Any()).Return(nil)
+	deleted := make(chan struct{}, 2)
+	// 先删一次，延迟之后再删一次
+	c.EXPECT().Delete(gomock.Any(), int64(1)).Times(2).DoAndReturn(func(ctx context.Context, uid int64) error {
+		deleted <- struct{}{}
+		return nil
+	})
+	repo := NewCachedUserRepository(d, c).(*CachedUserRepository)
+	repo.doubleDeleteDelay = time.Millisecond * 10
+	err := repo.UpdateNonZeroFields(context.Background(), domain.User{Id: 1, Nickname: "新昵称"})
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-deleted:
+		case <-time.After(time.Second):
+			t.Fatal("缓存没有被删两次")
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository/dao"
+	"basic-go/week2/webook/pkg/cryptox"
+	"context"
+	"time"
+)
+
+// ErrWechatTokenNotFound 用户没有绑定微信或者还没保存过凭证
+var ErrWechatTokenNotFound = dao.ErrRecordNotFound
+
+type WechatTokenRepository interface {
+	Save(ctx context.Context, t domain.WechatToken) error
+	FindByUid(ctx context.Context, uid int64) (domain.WechatToken, error)
+	// FindExpiring 返回在 deadline 之前就要过期的凭证，按 uid 分批，startUid 是上一批最后一个 uid
+	FindExpiring(ctx context.Context, deadline time.Time, startUid int64, limit int) ([]domain.WechatToken, error)
+}
+
+// EncryptedWechatTokenRepository 凭证落库前加密，读出来再解密，数据库里不会出现明文
+type EncryptedWechatTokenRepository struct {
+	dao    dao.WechatTokenDao
+	cipher cryptox.Cipher
+}
+
+func NewWechatTokenRepository(d dao.WechatTokenDao, c cryptox.Cipher) WechatTokenRepository {
+	return &EncryptedWechatTokenRepository{
+		dao:    d,
+		cipher: c,
+	}
+}
+
+func (repo *EncryptedWechatTokenRepository) Save(ctx context.Context, t domain.WechatToken) error {
+	accessToken, err := repo.cipher.Encrypt(t.AccessToken)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := repo.cipher.Encrypt(t.RefreshToken)
+	if err != nil {
+		return err
+	}
+	return repo.dao.Upsert(ctx, dao.WechatToken{
+		Uid:          t.Uid,
+		App:          t.App,
+		OpenId:       t.OpenId,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Scope:        t.Scope,
+		ExpiresAt:    t.ExpiresAt.UnixMilli(),
+	})
+}
+
+func (repo *EncryptedWechatTokenRepository) FindByUid(ctx context.Context, uid int64) (domain.WechatToken, error) {
+	t, err := repo.dao.FindByUid(ctx, uid)
+	if err != nil {
+		return domain.WechatToken{}, err
+	}
+	return repo.toDomain(t)
+}
+
+func (repo *EncryptedWechatTokenRepository) FindExpiring(ctx context.Context, deadline time.Time, startUid int64, limit int) ([]domain.WechatToken, error) {
+	ts, err := repo.dao.FindExpiring(ctx, deadline.UnixMilli(), startUid, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.WechatToken, 0, len(ts))
+	for _, t := range ts {
+		dt, err := repo.toDomain(t)
+		if err != nil {
+			// 有一条解不出来（比如换过密钥）不影响其它的，但 uid 要留着给下一批定位
+			res = append(res, domain.WechatToken{Uid: t.Uid})
+			continue
+		}
+		res = append(res, dt)
+	}
+	return res, nil
+}
+
+func (repo *EncryptedWechatTokenRepository) toDomain(t dao.WechatToken) (domain.WechatToken, error) {
+	accessToken, err := repo.cipher.Decrypt(t.AccessToken)
+	if err != nil {
+		return domain.WechatToken{}, err
+	}
+	refreshToken, err := repo.cipher.Decrypt(t.RefreshToken)
+	if err != nil {
+		return domain.WechatToken{}, err
+	}
+	return domain.WechatToken{
+		Uid:          t.Uid,
+		App:          t.App,
+		OpenId:       t.OpenId,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Scope:        t.Scope,
+		ExpiresAt:    time.UnixMilli(t.ExpiresAt),
+	}, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository"
+	"context"
+	"log"
+)
+
+// ErrArticleNotFound 文章不存在，或者不是这个作者的
+var ErrArticleNotFound = repository.ErrArticleNotFound
+
+// ArticleService 作者写文章：保存草稿、发表、撤回。调用方要把 art.Author 设成当前登录的用户
+type ArticleService interface {
+	// Save 保存草稿，id 是 0 就新建。已经发表的文章改了之后，读者看到的还是上一次发表的，要重新发表
+	Save(ctx context.Context, art domain.Article) (int64, error)
+	// Publish 保存并发表，id 是 0 就新建
+	Publish(ctx context.Context, art domain.Article) (int64, error)
+	// Withdraw 撤回，读者就看不到了，作者自己还能看到、还能再发表
+	Withdraw(ctx context.Context, uid int64, id int64) error
+	// List 作者自己的文章，最近改过的在前面
+	List(ctx context.Context, uid int64, offset int, limit int) ([]domain.Article, error)
+	// Detail 作者自己的文章的详情，别人的返回 ErrArticleNotFound
+	Detail(ctx context.Context, uid int64, id int64) (domain.Article, error)
+
+	// 下面是给读者的，只能看到已经发表的
+
+	// PublishedDetail 已经发表的文章，带上作者的名字。撤回了的返回 ErrArticleNotFound
+	PublishedDetail(ctx context.Context, id int64) (domain.Article, error)
+	// PublishedList 作者发表的文章，Content 只有摘要。next 是下一页的 cursor，零值说明没有下一页了
+	PublishedList(ctx context.Context, uid int64, cursor domain.ArticleCursor,
+		limit int) (arts []domain.Article, next domain.ArticleCursor, err error)
+}
+
+type articleService struct {
+	repo  repository.ArticleRepository
+	users repository.UserRepository
+}
+
+func NewArticleService(repo repository.ArticleRepository, users repository.UserRepository) ArticleService {
+	return &articleService{
+		repo:  repo,
+		users: users,
+	}
+}
+
+func (svc *articleService) Save(ctx context.Context, art domain.Article) (int64, error) {
+	art.Status = domain.ArticleStatusUnpublished
+	if art.Id > 0 {
+		return art.Id, svc.repo.Update(ctx, art)
+	}
+	return svc.repo.Create(ctx, art)
+}
+
+func (svc *articleService) Publish(ctx context.Context, art domain.Article) (int64, error) {
+	art.Status = domain.ArticleStatusPublished
+	return svc.repo.Sync(ctx, art)
+}
+
+func (svc *articleService) Withdraw(ctx context.Context, uid int64, id int64) error {
+	return svc.repo.SyncStatus(ctx, uid, id, domain.ArticleStatusPrivate)
+}
+
+func (svc *articleService) List(ctx context.Context, uid int64, offset int, limit int) ([]domain.Article, error) {
+	return svc.repo.GetByAuthor(ctx, uid, offset, limit)
+}
+
+func (svc *articleService) Detail(ctx context.Context, uid int64, id int64) (domain.Article, error) {
+	art, err := svc.repo.GetById(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	if art.Author.Id != uid {
+		// 别人的文章当成不存在
+		return domain.Article{}, ErrArticleNotFound
+	}
+	return art, nil
+}
+
+func (svc *articleService) PublishedDetail(ctx context.Context, id int64) (domain.Article, error) {
+	art, err := svc.repo.GetPubById(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	if art.Status != domain.ArticleStatusPublished {
+		return domain.Article{}, ErrArticleNotFound
+	}
+	art.Author.Name = svc.authorName(ctx, art.Author.Id)
+	return art, nil
+}
+
+func (svc *articleService) PublishedList(ctx context.Context, uid int64, cursor domain.ArticleCursor,
+	limit int) ([]domain.Article, domain.ArticleCursor, error) {
+	// 多取一篇，取到了说明还有下一页
+	arts, err := svc.repo.ListPub(ctx, uid, cursor, limit+1)
+	if err != nil {
+		return nil, domain.ArticleCursor{}, err
+	}
+	var next domain.ArticleCursor
+	if len(arts) > limit {
+		arts = arts[:limit]
+		last := arts[limit-1]
+		next = domain.ArticleCursor{Utime: last.Utime, Id: last.Id}
+	}
+	if len(arts) > 0 {
+		name := svc.authorName(ctx, uid)
+		for i := range arts {
+			arts[i].Author.Name = name
+		}
+	}
+	return arts, next, nil
+}
+
+// authorName 作者的昵称，查不到也不影响看文章，打个日志
+func (svc *articleService) authorName(ctx context.Context, uid int64) string {
+	u, err := svc.users.FindById(ctx, uid)
+	if err != nil {
+		log.Println("查询文章作者失败", uid, err)
+		return ""
+	}
+	return u.Nickname
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+// AuthAuditService 认证的审计日志：登录、刷新 token、退出登录都记一条，用户可以查看自己最近的记录
+type AuthAuditService interface {
+	// Record 记一条认证事件。不会阻塞请求，缓冲满了就丢掉
+	Record(ctx context.Context, e domain.AuthEvent)
+	// Recent 用户最近的 limit 条认证事件，最新的在前面
+	Recent(ctx context.Context, uid int64, limit int) ([]domain.AuthEvent, error)
+}
+
+// NewDeviceHook 用户在新设备上登录成功之后调用，比如发短信、发邮件提醒用户
+type NewDeviceHook interface {
+	OnNewDevice(ctx context.Context, e domain.AuthEvent) error
+}
+
+// LogNewDeviceHook 只打日志
+type LogNewDeviceHook struct{}
+
+func (LogNewDeviceHook) OnNewDevice(ctx context.Context, e domain.AuthEvent) error {
+	log.Println("用户在新设备上登录", e.Uid, e.Method, e.IP, e.UserAgent)
+	return nil
+}
+
+// AsyncAuthAuditService 先把事件放进缓冲，后台攒够一批或者到了时间再一起写进数据库。
+// 新设备也是在写库之前判断的，不占用登录请求的时间
+type AsyncAuthAuditService struct {
+	repo  repository.AuthEventRepository
+	hooks []NewDeviceHook
+
+	events chan domain.AuthEvent
+	// 攒够多少条写一次
+	batchSize int
+	// 没攒够的话最多等多久
+	flushInterval time.Duration
+	// 写一批最多多久
+	timeout time.Duration
+}
+
+func NewAsyncAuthAuditService(repo repository.AuthEventRepository, bufferSize int,
+	hooks ...NewDeviceHook) *AsyncAuthAuditService {
+	return &AsyncAuthAuditService{
+		repo:          repo,
+		hooks:         hooks,
+		events:        make(chan domain.AuthEvent, bufferSize),
+		batchSize:     100,
+		flushInterval: time.Second,
+		timeout:       time.Second * 5,
+	}
+}
+
+func (s *AsyncAuthAuditService) BatchSize(size int) *AsyncAuthAuditService {
+	s.batchSize = size
+	return s
+}
+
+func (s *AsyncAuthAuditService) FlushInterval(interval time.Duration) *AsyncAuthAuditService {
+	s.flushInterval = interval
+	return s
+}
+
+func (s *AsyncAuthAuditService) Record(ctx context.Context, e domain.AuthEvent) {
+	e.Device = deviceOf(e.UserAgent)
+	if e.Ctime.IsZero() {
+		e.Ctime = time.Now()
+	}
+	select {
+	case s.events <- e:
+	default:
+		// 审计日志不能影响登录，数据库写不过来的时候宁可丢
+		log.Println("审计日志缓冲已满，丢弃", e.Uid, e.Method, e.Success)
+	}
+}
+
+func (s *AsyncAuthAuditService) Recent(ctx context.Context, uid int64, limit int) ([]domain.AuthEvent, error) {
+	return s.repo.FindByUid(ctx, uid, limit)
+}
+
+// Start 在后台把缓冲里的事件写进数据库，ctx 被取消之后把已经收到的写完再退出
+func (s *AsyncAuthAuditService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.flushInterval)
+		defer ticker.Stop()
+		batch := make([]domain.AuthEvent, 0, s.batchSize)
+		for {
+			select {
+			case e := <-s.events:
+				batch = append(batch, e)
+				if len(batch) < s.batchSize {
+					continue
+				}
+			case <-ticker.C:
+				if len(batch) == 0 {
+					continue
+				}
+			case <-ctx.Done():
+				for {
+					select {
+					case e := <-s.events:
+						batch = append(batch, e)
+					default:
+						if len(batch) > 0 {
+							s.flush(batch)
+						}
+						return
+					}
+				}
+			}
+			s.flush(batch)
+			batch = batch[:0]
+		}
+	}()
+}
+
+func (s *AsyncAuthAuditService) flush(batch []domain.AuthEvent) {
+	// 请求的 ctx 早就结束了，用新的
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	s.markNewDevices(ctx, batch)
+	if err := s.repo.BatchCreate(ctx, batch); err != nil {
+		log.Println("写入审计日志失败，丢弃", len(batch), "条", err)
+		return
+	}
+	for _, e := range batch {
+		if !e.NewDevice {
+			continue
+		}
+		for _, h := range s.hooks {
+			if err := h.OnNewDevice(ctx, e); err != nil {
+				log.Println("新设备登录的通知失败", e.Uid, err)
+			}
+		}
+	}
+}
+
+// markNewDevices 登录成功，并且这个用户以前登录成功过，但是没用过这个设备，就是新设备。
+// 注册之后的第一次登录不算。同一批里同一个用户的设备也要算进去
+func (s *AsyncAuthAuditService) markNewDevices(ctx context.Context, batch []domain.AuthEvent) {
+	known := make(map[int64]map[string]struct{})
+	for i := range batch {
+		e := &batch[i]
+		if !e.Success || !e.Method.IsLogin() || e.Uid == 0 || e.Device == "" {
+			continue
+		}
+		devices, ok := known[e.Uid]
+		if !ok {
+			list, err := s.repo.FindDevices(ctx, e.Uid)
+			if err != nil {
+				// 查不出来就不标记，宁可漏报也不要误报
+				log.Println("查询用户登录过的设备失败", e.Uid, err)
+				continue
+			}
+			devices = make(map[string]struct{}, len(list))
+			for _, d := range list {
+				devices[d] = struct{}{}
+			}
+			known[e.Uid] = devices
+		}
+		_, seen := devices[e.Device]
+		e.NewDevice = !seen && len(devices) > 0
+		devices[e.Device] = struct{}{}
+	}
+}
+
+// deviceOf 用 User-Agent 粗略地区分设备，同一个浏览器升级了版本也会被当成新设备
+func deviceOf(userAgent string) string {
+	if userAgent == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(userAgent))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memAuthEventRepository 把审计日志存在内存里
+type memAuthEventRepository struct {
+	mu     sync.Mutex
+	events []domain.AuthEvent
+}
+
+func (r *memAuthEventRepository) BatchCreate(ctx context.Context, events []domain.AuthEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *memAuthEventRepository) FindByUid(ctx context.Context, uid int64, limit int) ([]domain.AuthEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var res []domain.AuthEvent
+	for i := len(r.events) - 1; i >= 0 && len(res) < limit; i-- {
+		if r.events[i].Uid == uid {
+			res = append(res, r.events[i])
+		}
+	}
+	return res, nil
+}
+
+func (r *memAuthEventRepository) FindDevices(ctx context.Context, uid int64) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var res []string
+	for _, e := range r.events {
+		if e.Uid == uid && e.Success && e.Device != "" {
+			res = append(res, e.Device)
+		}
+	}
+	return res, nil
+}
+
+type chanNewDeviceHook chan domain.AuthEvent
+
+func (h chanNewDeviceHook) OnNewDevice(ctx context.Context, e domain.AuthEvent) error {
+	h <- e
+	return nil
+}
+
+func TestAsyncAuthAuditService(t *testing.T) {
+	repo := &memAuthEventRepository{}
+	hook := make(chanNewDeviceHook, 10)
+	svc := NewAsyncAuthAuditService(repo, 100, hook).
+		BatchSize(3).
+		FlushInterval(time.Millisecond * 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc.Start(ctx)
+
+	login := func(uid int64, ua string, success bool) domain.AuthEvent {
+		return domain.AuthEvent{Uid: uid, Method: domain.AuthMethodPassword, UserAgent: ua, Success: success}
+	}
+	// 第一次登录不算新设备，同一批里换了个浏览器算
+	svc.Record(ctx, login(1, "Chrome", true))
+	svc.Record(ctx, login(1, "Safari", true))
+	// 失败的不算
+	svc.Record(ctx, login(1, "Firefox", false))
+	// 下一批，用过的设备不算
+	svc.Record(ctx, login(1, "Chrome", true))
+	svc.Record(ctx, domain.AuthEvent{Uid: 1, Method: domain.AuthMethodRefresh, UserAgent: "Edge", Success: true})
+
+	select {
+	case e := <-hook:
+		assert.Equal(t, "Safari", e.UserAgent)
+	case <-time.After(time.Second):
+		t.Fatal("没有收到新设备的通知")
+	}
+	require.Eventually(t, func() bool {
+		events, err := svc.Recent(ctx, 1, 10)
+		return err == nil && len(events) == 5
+	}, time.Second, time.Millisecond*10)
+
+	events, err := svc.Recent(ctx, 1, 10)
+	require.NoError(t, err)
+	var newDevices []string
+	for _, e := range events {
+		if e.NewDevice {
+			newDevices = append(newDevices, e.UserAgent)
+		}
+	}
+	assert.Equal(t, []string{"Safari"}, newDevices)
+	assert.Empty(t, hook)
+}
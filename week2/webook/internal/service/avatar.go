@@ -0,0 +1,128 @@
+package service
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository"
+	"basic-go/week2/webook/pkg/imagex"
+	"basic-go/week2/webook/pkg/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrAvatarInvalid 不是 JPEG、PNG、GIF，或者图片坏了
+	ErrAvatarInvalid = errors.New("头像不是支持的图片格式")
+	// ErrAvatarTooLarge 图片的宽高太大，解码要占用太多内存
+	ErrAvatarTooLarge = errors.New("头像图片尺寸太大")
+)
+
+// 支持的头像格式，按文件内容判断，不相信前端传的 Content-Type 和文件名
+var avatarContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type AvatarService interface {
+	// Upload 从图片中间裁出正方形，生成头像和缩略图保存起来，并且换成用户的头像
+	Upload(ctx context.Context, uid int64, data []byte) (domain.Avatar, error)
+}
+
+type avatarService struct {
+	repo  repository.UserRepository
+	store storage.Storage
+	// 头像和缩略图的边长
+	size          int
+	thumbnailSize int
+	// 宽和高最大多少像素
+	maxDimension int
+}
+
+func NewAvatarService(repo repository.UserRepository, store storage.Storage) AvatarService {
+	return &avatarService{
+		repo:          repo,
+		store:         store,
+		size:          256,
+		thumbnailSize: 64,
+		maxDimension:  4096,
+	}
+}
+
+func (svc *avatarService) Upload(ctx context.Context, uid int64, data []byte) (domain.Avatar, error) {
+	if !avatarContentTypes[http.DetectContentType(data)] {
+		return domain.Avatar{}, ErrAvatarInvalid
+	}
+	// 先只读宽高，太大的图片不解码，防止用一张很小的文件撑爆内存
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return domain.Avatar{}, ErrAvatarInvalid
+	}
+	if cfg.Width > svc.maxDimension || cfg.Height > svc.maxDimension {
+		return domain.Avatar{}, ErrAvatarTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return domain.Avatar{}, ErrAvatarInvalid
+	}
+	old, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return domain.Avatar{}, err
+	}
+
+	// 每次上传都用新的文件名，CDN 和浏览器里缓存的旧头像不会有影响
+	name := uuid.New().String()
+	key, thumbnailKey := avatarKeys(uid, name)
+	if err = svc.put(ctx, key, imagex.Thumbnail(img, svc.size)); err != nil {
+		return domain.Avatar{}, err
+	}
+	if err = svc.put(ctx, thumbnailKey, imagex.Thumbnail(img, svc.thumbnailSize)); err != nil {
+		return domain.Avatar{}, err
+	}
+	res := domain.Avatar{
+		URL:          svc.store.URL(key),
+		ThumbnailURL: svc.store.URL(thumbnailKey),
+	}
+	if err = svc.repo.UpdateAvatar(ctx, uid, res.URL); err != nil {
+		return domain.Avatar{}, err
+	}
+	deleteAvatar(ctx, svc.store, uid, old.Avatar)
+	return res, nil
+}
+
+func avatarKeys(uid int64, name string) (string, string) {
+	dir := fmt.Sprintf("avatars/%d/", uid)
+	return dir + name + ".jpg", dir + name + "_thumb.jpg"
+}
+
+func (svc *avatarService) put(ctx context.Context, key string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return err
+	}
+	return svc.store.Put(ctx, key, buf.Bytes(), "image/jpeg")
+}
+
+// deleteAvatar 删掉上传过的头像和缩略图，换头像和清除注销账号的时候用。
+// 微信的头像之类不是我们存的，不用管。删不掉只会多占一点空间，不影响调用方
+func deleteAvatar(ctx context.Context, store storage.Storage, uid int64, url string) {
+	prefix := store.URL(fmt.Sprintf("avatars/%d/", uid))
+	if !strings.HasPrefix(url, prefix) {
+		return
+	}
+	key, thumbnailKey := avatarKeys(uid, strings.TrimSuffix(strings.TrimPrefix(url, prefix), ".jpg"))
+	for _, k := range []string{key, thumbnailKey} {
+		if err := store.Delete(ctx, k); err != nil {
+			log.Println("删除头像失败", uid, k, err)
+		}
+	}
+}
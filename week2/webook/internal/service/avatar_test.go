@@ -0,0 +1,70 @@
+package service
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository"
+	"basic-go/week2/webook/pkg/storage"
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// avatarUserRepository 只实现了上传头像用到的方法
+type avatarUserRepository struct {
+	repository.UserRepository
+	user domain.User
+}
+
+func (r *avatarUserRepository) FindById(ctx context.Context, uid int64) (domain.User, error) {
+	return r.user, nil
+}
+
+func (r *avatarUserRepository) UpdateAvatar(ctx context.Context, uid int64, avatar string) error {
+	r.user.Avatar = avatar
+	return nil
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))))
+	return buf.Bytes()
+}
+
+func TestAvatarService_Upload(t *testing.T) {
+	root := t.TempDir()
+	repo := &avatarUserRepository{user: domain.User{Id: 1, Avatar: "https://thirdwx.qlogo.cn/avatar.png"}}
+	svc := NewAvatarService(repo, storage.NewLocalStorage(root, "/uploads"))
+	ctx := context.Background()
+	exists := func(url string) bool {
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(url, "/uploads/"))))
+		return err == nil
+	}
+
+	first, err := svc.Upload(ctx, 1, encodePNG(t, 600, 400))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(first.URL, "/uploads/avatars/1/"))
+	assert.Equal(t, first.URL, repo.user.Avatar)
+	assert.True(t, exists(first.URL))
+	assert.True(t, exists(first.ThumbnailURL))
+
+	// 再传一次，上一次的要删掉
+	second, err := svc.Upload(ctx, 1, encodePNG(t, 100, 100))
+	require.NoError(t, err)
+	assert.Equal(t, second.URL, repo.user.Avatar)
+	assert.False(t, exists(first.URL))
+	assert.False(t, exists(first.ThumbnailURL))
+	assert.True(t, exists(second.URL))
+
+	_, err = svc.Upload(ctx, 1, []byte("<html></html>"))
+	assert.Equal(t, ErrAvatarInvalid, err)
+	_, err = svc.Upload(ctx, 1, encodePNG(t, 4097, 1))
+	assert.Equal(t, ErrAvatarTooLarge, err)
+	assert.Equal(t, second.URL, repo.user.Avatar)
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"archive/zip"
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+// ErrUserExportNotFound 导出不存在、过期了，或者不是这个用户的
+var ErrUserExportNotFound = repository.ErrUserExportNotFound
+
+type UserExportService interface {
+	// Request 发起导出，在后台打包。上一次导出还在打包，或者刚发起不久，就直接返回上一次的
+	Request(ctx context.Context, uid int64) (domain.UserExport, error)
+	// Find 查导出的进度，只能查自己的
+	Find(ctx context.Context, uid int64, id string) (domain.UserExport, error)
+	// Archive 打包好的 ZIP，调用方要先校验过下载链接
+	Archive(ctx context.Context, id string) ([]byte, error)
+}
+
+type userExportService struct {
+	users        repository.UserRepository
+	wechatTokens repository.WechatTokenRepository
+	sessions     repository.SessionRepository
+	authEvents   repository.AuthEventRepository
+	exports      repository.UserExportRepository
+	// 同一个用户两次导出至少隔多久
+	minInterval time.Duration
+	// 一次打包最多多久
+	timeout time.Duration
+	// 最多导出多少条认证记录，最新的在前面
+	authEventLimit int
+}
+
+func NewUserExportService(users repository.UserRepository, wechatTokens repository.WechatTokenRepository,
+	sessions repository.SessionRepository, authEvents repository.AuthEventRepository,
+	exports repository.UserExportRepository) UserExportService {
+	return &userExportService{
+		users:          users,
+		wechatTokens:   wechatTokens,
+		sessions:       sessions,
+		authEvents:     authEvents,
+		exports:        exports,
+		minInterval:    time.Minute * 10,
+		timeout:        time.Minute,
+		authEventLimit: 10000,
+	}
+}
+
+func (svc *userExportService) Request(ctx context.Context, uid int64) (domain.UserExport, error) {
+	latest, err := svc.exports.FindLatest(ctx, uid)
+	switch {
+	case err == nil:
+		switch {
+		case latest.Status == domain.UserExportPending && time.Since(latest.Ctime) > svc.timeout:
+			// 打包最多 timeout，过了还是打包中说明打包的实例挂了，当成失败，不然要等任务过期才能重新导出
+			latest.Status = domain.UserExportFailed
+			if err = svc.exports.Save(ctx, latest); err != nil {
+				return domain.UserExport{}, err
+			}
+		case latest.Status == domain.UserExportPending,
+			latest.Status == domain.UserExportReady && time.Since(latest.Ctime) < svc.minInterval:
+			return latest, nil
+		}
+	case !errors.Is(err, repository.ErrUserExportNotFound):
+		return domain.UserExport{}, err
+	}
+	t := domain.UserExport{
+		Id:     uuid.New().String(),
+		Uid:    uid,
+		Status: domain.UserExportPending,
+		Ctime:  time.Now(),
+	}
+	if err = svc.exports.Save(ctx, t); err != nil {
+		return domain.UserExport{}, err
+	}
+	// note 请求结束 ctx 就被取消了，后台打包要用新的 ctx。实例挂了任务会一直是打包中，超过 timeout 再发起就当成失败
+	// t 传值进去，后台改状态不影响返回的
+	go func(t domain.UserExport) {
+		ctx, cancel := context.WithTimeout(context.Background(), svc.timeout)
+		t.Status = domain.UserExportReady
+		if err := svc.build(ctx, t); err != nil {
+			log.Println("导出用户数据失败", t.Uid, t.Id, err)
+			t.Status = domain.UserExportFailed
+		}
+		cancel()
+		// 打包超时了 ctx 也超时了，保存结果要用新的 ctx，不然任务一直是打包中
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := svc.exports.Save(ctx, t); err != nil {
+			log.Println("保存导出任务失败", t.Uid, t.Id, err)
+		}
+	}(t)
+	return t, nil
+}
+
+func (svc *userExportService) Find(ctx context.Context, uid int64, id string) (domain.UserExport, error) {
+	t, err := svc.exports.FindById(ctx, id)
+	if err != nil {
+		return domain.UserExport{}, err
+	}
+	if t.Uid != uid {
+		// 别人的导出当成不存在，不能让人知道这个 id 是有效的
+		return domain.UserExport{}, ErrUserExportNotFound
+	}
+	return t, nil
+}
+
+func (svc *userExportService) Archive(ctx context.Context, id string) ([]byte, error) {
+	return svc.exports.FindArchive(ctx, id)
+}
+
+// build 收集这个用户的所有数据，每一类一个 JSON 文件，打成一个 ZIP
+func (svc *userExportService) build(ctx context.Context, t domain.UserExport) error {
+	u, err := svc.users.FindById(ctx, t.Uid)
+	if err != nil {
+		return err
+	}
+	type file struct {
+		name string
+		val  any
+	}
+	files := []file{{"profile.json", newExportProfile(u)}}
+	if u.WechatInfo.OpenId != "" {
+		wechat := exportWechat{
+			OpenId:  u.WechatInfo.OpenId,
+			UnionId: u.WechatInfo.UnionId,
+		}
+		token, err := svc.wechatTokens.FindByUid(ctx, t.Uid)
+		switch {
+		case err == nil:
+			wechat.App = token.App
+			wechat.Scope = token.Scope
+			wechat.TokenExpiresAt = &token.ExpiresAt
+		case !errors.Is(err, repository.ErrWechatTokenNotFound):
+			return err
+		}
+		files = append(files, file{"wechat.json", wechat})
+	}
+	sessions, err := svc.sessions.List(ctx, t.Uid)
+	if err != nil {
+		return err
+	}
+	files = append(files, file{"sessions.json", newExportSessions(sessions)})
+	logins, err := svc.sessions.LoginHistory(ctx, t.Uid)
+	if err != nil {
+		return err
+	}
+	files = append(files, file{"login_history.json", newExportSessions(logins)})
+	events, err := svc.authEvents.FindByUid(ctx, t.Uid, svc.authEventLimit)
+	if err != nil {
+		return err
+	}
+	files = append(files, file{"auth_events.json", newExportAuthEvents(events)})
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(f.val); err != nil {
+			return err
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	return svc.exports.SaveArchive(ctx, t.Id, buf.Bytes())
+}
+
+// 导出文件里的结构，字段名是给用户看的，改了要考虑已经下载过的用户
+// note 密码和微信的 access_token、refresh_token 是凭证，不是个人数据，不导出
+
+type exportProfile struct {
+	Id            int64      `json:"id"`
+	Email         string     `json:"email,omitempty"`
+	Phone         string     `json:"phone,omitempty"`
+	Handle        string     `json:"handle,omitempty"`
+	Nickname      string     `json:"nickname,omitempty"`
+	Birthday      string     `json:"birthday,omitempty"`
+	Resume        string     `json:"resume,omitempty"`
+	Avatar        string     `json:"avatar,omitempty"`
+	Gender        string     `json:"gender,omitempty"`
+	Location      string     `json:"location,omitempty"`
+	Website       string     `json:"website,omitempty"`
+	Locale        string     `json:"locale,omitempty"`
+	HideBirthday  bool       `json:"hideBirthday"`
+	HideResume    bool       `json:"hideResume"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty"`
+}
+
+func newExportProfile(u domain.User) exportProfile {
+	res := exportProfile{
+		Id:           u.Id,
+		Email:        u.Email,
+		Phone:        u.Phone,
+		Handle:       u.Handle,
+		Nickname:     u.Nickname,
+		Resume:       u.Resume,
+		Avatar:       u.Avatar,
+		Gender:       exportGenders[u.Gender],
+		Location:     u.Location,
+		Website:      u.Website,
+		Locale:       u.Locale,
+		HideBirthday: u.Privacy.HideBirthday,
+		HideResume:   u.Privacy.HideResume,
+		Status:       "active",
+		CreatedAt:    u.Ctime,
+	}
+	if u.Birthday.UnixMilli() != 0 {
+		res.Birthday = u.Birthday.Format(time.DateOnly)
+	}
+	if u.Status == domain.UserStatusDeactivated {
+		res.Status = "deactivated"
+		res.DeactivatedAt = &u.DeactivatedAt
+	}
+	return res
+}
+
+// 没填的性别不导出
+var exportGenders = map[domain.Gender]string{
+	domain.GenderMale:   "male",
+	domain.GenderFemale: "female",
+	domain.GenderOther:  "other",
+}
+
+type exportWechat struct {
+	OpenId         string     `json:"openId"`
+	UnionId        string     `json:"unionId,omitempty"`
+	App            string     `json:"app,omitempty"`
+	Scope          string     `json:"scope,omitempty"`
+	TokenExpiresAt *time.Time `json:"tokenExpiresAt,omitempty"`
+}
+
+// exportSession 不导出 ssid，它和 token 绑在一起
+type exportSession struct {
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	LoginAt   time.Time `json:"loginAt"`
+}
+
+func newExportSessions(sessions []domain.Session) []exportSession {
+	res := make([]exportSession, 0, len(sessions))
+	for _, s := range sessions {
+		res = append(res, exportSession{
+			IP:        s.IP,
+			UserAgent: s.UserAgent,
+			LoginAt:   s.LoginAt,
+		})
+	}
+	return res
+}
+
+// exportAuthEvent 登录、刷新 token、退出登录的记录，失败的也有
+type exportAuthEvent struct {
+	Method    string    `json:"method"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	NewDevice bool      `json:"newDevice"`
+	Time      time.Time `json:"time"`
+}
+
+func newExportAuthEvents(events []domain.AuthEvent) []exportAuthEvent {
+	res := make([]exportAuthEvent, 0, len(events))
+	for _, e := range events {
+		res = append(res, exportAuthEvent{
+			Method:    string(e.Method),
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Success:   e.Success,
+			Reason:    e.Reason,
+			NewDevice: e.NewDevice,
+			Time:      e.Ctime,
+		})
+	}
+	return res
+}
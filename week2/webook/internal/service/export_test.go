@@ -0,0 +1,287 @@
+package service
+
+import (
+	"archive/zip"
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memUserExportRepository 把导出任务和文件存在内存里
+type memUserExportRepository struct {
+	mu       sync.Mutex
+	tasks    map[string]domain.UserExport
+	latest   map[int64]string
+	archives map[string][]byte
+}
+
+func newMemUserExportRepository() *memUserExportRepository {
+	return &memUserExportRepository{
+		tasks:    map[string]domain.UserExport{},
+		latest:   map[int64]string{},
+		archives: map[string][]byte{},
+	}
+}
+
+func (r *memUserExportRepository) Save(ctx context.Context, t domain.UserExport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tasks[t.Id] = t
+	r.latest[t.Uid] = t.Id
+	return nil
+}
+
+func (r *memUserExportRepository) FindById(ctx context.Context, id string) (domain.UserExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tasks[id]
+	if !ok {
+		return domain.UserExport{}, repository.ErrUserExportNotFound
+	}
+	return t, nil
+}
+
+func (r *memUserExportRepository) FindLatest(ctx context.Context, uid int64) (domain.UserExport, error) {
+	r.mu.Lock()
+	id, ok := r.latest[uid]
+	r.mu.Unlock()
+	if !ok {
+		return domain.UserExport{}, repository.ErrUserExportNotFound
+	}
+	return r.FindById(ctx, id)
+}
+
+func (r *memUserExportRepository) SaveArchive(ctx context.Context, id string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.archives[id] = data
+	return nil
+}
+
+func (r *memUserExportRepository) FindArchive(ctx context.Context, id string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.archives[id]
+	if !ok {
+		return nil, repository.ErrUserExportNotFound
+	}
+	return data, nil
+}
+
+// exportUserRepository 只实现了导出用到的方法
+type exportUserRepository struct {
+	repository.UserRepository
+	user domain.User
+	err  error
+}
+
+func (r *exportUserRepository) FindById(ctx context.Context, uid int64) (domain.User, error) {
+	return r.user, r.err
+}
+
+type exportWechatTokenRepository struct {
+	repository.WechatTokenRepository
+	token domain.WechatToken
+}
+
+func (r *exportWechatTokenRepository) FindByUid(ctx context.Context, uid int64) (domain.WechatToken, error) {
+	return r.token, nil
+}
+
+type exportSessionRepository struct {
+	repository.SessionRepository
+	sessions []domain.Session
+}
+
+func (r *exportSessionRepository) List(ctx context.Context, uid int64) ([]domain.Session, error) {
+	return r.sessions, nil
+}
+
+func (r *exportSessionRepository) LoginHistory(ctx context.Context, uid int64) ([]domain.Session, error) {
+	return r.sessions, nil
+}
+
+// 带了各种凭证的用户，导出的文件里一个都不能有
+func newExportTestService(exports repository.UserExportRepository, userErr error) UserExportService {
+	users := &exportUserRepository{
+		user: domain.User{
+			Id:         1,
+			Email:      "123@qq.com",
+			Password:   "$2a$10$password-hash",
+			Nickname:   "大明",
+			WechatInfo: domain.WechatInfo{OpenId: "open-id"},
+		},
+		err: userErr,
+	}
+	tokens := &exportWechatTokenRepository{token: domain.WechatToken{
+		Uid:          1,
+		App:          "web",
+		OpenId:       "open-id",
+		AccessToken:  "access-token-secret",
+		RefreshToken: "refresh-token-secret",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}}
+	sessions := &exportSessionRepository{sessions: []domain.Session{
+		{Ssid: "ssid-secret", IP: "127.0.0.1", UserAgent: "Chrome", LoginAt: time.Now()},
+	}}
+	authEvents := &memAuthEventRepository{events: []domain.AuthEvent{
+		{Uid: 1, Method: domain.AuthMethodPassword, IP: "127.0.0.1", UserAgent: "Chrome", Success: true},
+		{Uid: 2, Method: domain.AuthMethodPassword, IP: "127.0.0.2", UserAgent: "other-user-agent", Success: true},
+	}}
+	return NewUserExportService(users, tokens, sessions, authEvents, exports)
+}
+
+// waitExportDone 等后台打包结束
+func waitExportDone(t *testing.T, repo *memUserExportRepository, id string) domain.UserExport {
+	var res domain.UserExport
+	require.Eventually(t, func() bool {
+		var err error
+		res, err = repo.FindById(context.Background(), id)
+		return err == nil && res.Status != domain.UserExportPending
+	}, time.Second, time.Millisecond*10)
+	return res
+}
+
+func TestUserExportService_Request(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 上一次导出，nil 就是没导出过
+		latest *domain.UserExport
+		// 是不是直接返回上一次的
+		wantLatest bool
+		// 上一次导出最后的状态
+		wantLatestStatus domain.UserExportStatus
+	}{
+		{
+			name: "第一次导出",
+		},
+		{
+			name:             "上一次还在打包",
+			latest:           &domain.UserExport{Id: "latest", Uid: 1, Status: domain.UserExportPending, Ctime: time.Now().Add(-time.Second * 10)},
+			wantLatest:       true,
+			wantLatestStatus: domain.UserExportPending,
+		},
+		{
+			name:             "上一次刚打包好",
+			latest:           &domain.UserExport{Id: "latest", Uid: 1, Status: domain.UserExportReady, Ctime: time.Now().Add(-time.Minute)},
+			wantLatest:       true,
+			wantLatestStatus: domain.UserExportReady,
+		},
+		{
+			name:             "上一次打包好很久了",
+			latest:           &domain.UserExport{Id: "latest", Uid: 1, Status: domain.UserExportReady, Ctime: time.Now().Add(-time.Minute * 11)},
+			wantLatestStatus: domain.UserExportReady,
+		},
+		{
+			name:             "上一次失败了",
+			latest:           &domain.UserExport{Id: "latest", Uid: 1, Status: domain.UserExportFailed, Ctime: time.Now().Add(-time.Second * 10)},
+			wantLatestStatus: domain.UserExportFailed,
+		},
+		{
+			name:             "上一次打包超时了",
+			latest:           &domain.UserExport{Id: "latest", Uid: 1, Status: domain.UserExportPending, Ctime: time.Now().Add(-time.Minute * 2)},
+			wantLatestStatus: domain.UserExportFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newMemUserExportRepository()
+			ctx := context.Background()
+			if tc.latest != nil {
+				require.NoError(t, repo.Save(ctx, *tc.latest))
+			}
+			svc := newExportTestService(repo, nil)
+
+			res, err := svc.Request(ctx, 1)
+			require.NoError(t, err)
+			if tc.wantLatest {
+				assert.Equal(t, "latest", res.Id)
+			} else {
+				assert.NotEqual(t, "latest", res.Id)
+				assert.Equal(t, domain.UserExportPending, res.Status)
+				assert.Equal(t, domain.UserExportReady, waitExportDone(t, repo, res.Id).Status)
+			}
+			if tc.latest != nil {
+				latest, err := repo.FindById(ctx, "latest")
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantLatestStatus, latest.Status)
+			}
+		})
+	}
+}
+
+func TestUserExportService_Build(t *testing.T) {
+	repo := newMemUserExportRepository()
+	svc := newExportTestService(repo, nil)
+	ctx := context.Background()
+
+	task, err := svc.Request(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, domain.UserExportReady, waitExportDone(t, repo, task.Id).Status)
+
+	data, err := svc.Archive(ctx, task.Id)
+	require.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	var names []string
+	var content []byte
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		rc, err := f.Open()
+		require.NoError(t, err)
+		val, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		content = append(content, val...)
+	}
+	assert.Equal(t, []string{"profile.json", "wechat.json", "sessions.json", "login_history.json", "auth_events.json"}, names)
+	assert.Contains(t, string(content), "123@qq.com")
+	// 别人的登录记录不能导出
+	assert.NotContains(t, string(content), "other-user-agent")
+	for _, secret := range []string{"password-hash", "access-token-secret", "refresh-token-secret", "ssid-secret"} {
+		assert.NotContains(t, string(content), secret)
+	}
+}
+
+func TestUserExportService_BuildFailed(t *testing.T) {
+	repo := newMemUserExportRepository()
+	svc := newExportTestService(repo, errors.New("数据库出错"))
+	ctx := context.Background()
+
+	task, err := svc.Request(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserExportFailed, waitExportDone(t, repo, task.Id).Status)
+	_, err = svc.Archive(ctx, task.Id)
+	assert.Equal(t, ErrUserExportNotFound, err)
+
+	// 失败了可以马上重新发起
+	retry, err := svc.Request(ctx, 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, task.Id, retry.Id)
+	waitExportDone(t, repo, retry.Id)
+}
+
+func TestUserExportService_Find(t *testing.T) {
+	repo := newMemUserExportRepository()
+	ctx := context.Background()
+	require.NoError(t, repo.Save(ctx, domain.UserExport{Id: "export-1", Uid: 1, Status: domain.UserExportReady}))
+	svc := newExportTestService(repo, nil)
+
+	res, err := svc.Find(ctx, 1, "export-1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserExportReady, res.Status)
+
+	// 别人的导出当成不存在
+	_, err = svc.Find(ctx, 2, "export-1")
+	assert.Equal(t, ErrUserExportNotFound, err)
+	_, err = svc.Find(ctx, 1, "export-2")
+	assert.Equal(t, ErrUserExportNotFound, err)
+}
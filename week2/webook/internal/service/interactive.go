@@ -0,0 +1,88 @@
+package service
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository"
+	"context"
+	"golang.org/x/sync/errgroup"
+)
+
+// InteractiveService 阅读、点赞、收藏。biz 是业务，比如 "article"，调用方要自己保证 bizId 存在
+type InteractiveService interface {
+	IncrReadCnt(ctx context.Context, biz string, bizId int64) error
+	// Like 点赞，重复点赞不会重复计数
+	Like(ctx context.Context, biz string, bizId int64, uid int64) error
+	// CancelLike 取消点赞，没点过的什么也不做
+	CancelLike(ctx context.Context, biz string, bizId int64, uid int64) error
+	Collect(ctx context.Context, biz string, bizId int64, uid int64) error
+	CancelCollect(ctx context.Context, biz string, bizId int64, uid int64) error
+	// Get 详情页用，计数加上 uid 有没有点赞、收藏
+	Get(ctx context.Context, biz string, bizId int64, uid int64) (domain.Interactive, error)
+	// GetByIds 列表页用，只有计数，每个 id 都有
+	GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error)
+}
+
+type interactiveService struct {
+	repo repository.InteractiveRepository
+}
+
+func NewInteractiveService(repo repository.InteractiveRepository) InteractiveService {
+	return &interactiveService{
+		repo: repo,
+	}
+}
+
+func (svc *interactiveService) IncrReadCnt(ctx context.Context, biz string, bizId int64) error {
+	return svc.repo.IncrReadCnt(ctx, biz, bizId)
+}
+
+func (svc *interactiveService) Like(ctx context.Context, biz string, bizId int64, uid int64) error {
+	return svc.repo.Like(ctx, biz, bizId, uid)
+}
+
+func (svc *interactiveService) CancelLike(ctx context.Context, biz string, bizId int64, uid int64) error {
+	return svc.repo.CancelLike(ctx, biz, bizId, uid)
+}
+
+func (svc *interactiveService) Collect(ctx context.Context, biz string, bizId int64, uid int64) error {
+	return svc.repo.Collect(ctx, biz, bizId, uid)
+}
+
+func (svc *interactiveService) CancelCollect(ctx context.Context, biz string, bizId int64, uid int64) error {
+	return svc.repo.CancelCollect(ctx, biz, bizId, uid)
+}
+
+func (svc *interactiveService) Get(ctx context.Context, biz string, bizId int64, uid int64) (domain.Interactive, error) {
+	// 三个查询互不依赖，一起查
+	var (
+		eg        errgroup.Group
+		intr      domain.Interactive
+		liked     bool
+		collected bool
+	)
+	eg.Go(func() error {
+		var err error
+		intr, err = svc.repo.Get(ctx, biz, bizId)
+		return err
+	})
+	eg.Go(func() error {
+		var err error
+		liked, err = svc.repo.Liked(ctx, biz, bizId, uid)
+		return err
+	})
+	eg.Go(func() error {
+		var err error
+		collected, err = svc.repo.Collected(ctx, biz, bizId, uid)
+		return err
+	})
+	if err := eg.Wait(); err != nil {
+		return domain.Interactive{}, err
+	}
+	intr.Liked = liked
+	intr.Collected = collected
+	return intr, nil
+}
+
+func (svc *interactiveService) GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error) {
+	return svc.repo.GetByIds(ctx, biz, bizIds)
+}
@@ -0,0 +1,27 @@
+package wechat
+
+// Apps 同时接入的多个微信应用，比如网站扫码登录和微信内 H5 登录，key 是应用名
+type Apps struct {
+	services   map[string]Service
+	defaultApp string
+}
+
+func NewApps(defaultApp string, services map[string]Service) *Apps {
+	return &Apps{
+		services:   services,
+		defaultApp: defaultApp,
+	}
+}
+
+// Get name 为空的时候返回默认的应用
+func (a *Apps) Get(name string) (Service, bool) {
+	if name == "" {
+		name = a.defaultApp
+	}
+	svc, ok := a.services[name]
+	return svc, ok
+}
+
+func (a *Apps) DefaultApp() string {
+	return a.defaultApp
+}
@@ -0,0 +1,91 @@
+package wechat
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const (
+	// ScopeLogin 网站应用扫码登录
+	ScopeLogin = "snsapi_login"
+	// ScopeUserInfo 微信内 H5 网页授权，会弹出授权页，可以拿到昵称和头像
+	ScopeUserInfo = "snsapi_userinfo"
+	// ScopeBase 微信内 H5 静默授权，只能拿到 openid
+	ScopeBase = "snsapi_base"
+
+	// 网站应用扫码登录的授权页
+	defaultQRConnectURL = "https://open.weixin.qq.com/connect/qrconnect"
+	// 公众号 H5 网页授权的授权页
+	defaultOAuth2AuthorizeURL = "https://open.weixin.qq.com/connect/oauth2/authorize"
+	// 微信接口的域名，测试的时候替换成本地的假服务器
+	defaultAPIBaseURL = "https://api.weixin.qq.com"
+)
+
+// Config 一个微信应用的配置，对应配置文件里 wechat.apps 下面的一项
+type Config struct {
+	// Name 应用名，比如 web（网站扫码登录）、h5（微信内网页登录），由配置的 key 填进来
+	Name        string
+	AppID       string
+	AppSecret   string
+	RedirectURI string
+	Scope       string
+	// AuthURL 授权页地址，不填就按 Scope 选
+	AuthURL string
+	// APIBaseURL 微信接口的域名，不填就是 https://api.weixin.qq.com
+	APIBaseURL string
+}
+
+// WithDefaults 把没填的 AuthURL 和 APIBaseURL 补上默认值
+func (c Config) WithDefaults() Config {
+	if c.AuthURL == "" {
+		if c.Scope == ScopeLogin {
+			c.AuthURL = defaultQRConnectURL
+		} else {
+			c.AuthURL = defaultOAuth2AuthorizeURL
+		}
+	}
+	if c.APIBaseURL == "" {
+		c.APIBaseURL = defaultAPIBaseURL
+	}
+	return c
+}
+
+// Validate 配置不对就在启动的时候报出来，而不是等到用户登录的时候才发现
+func (c Config) Validate() error {
+	if c.AppID == "" {
+		return fmt.Errorf("微信应用 %s 缺少 appId", c.Name)
+	}
+	if c.AppSecret == "" {
+		return fmt.Errorf("微信应用 %s 缺少 appSecret", c.Name)
+	}
+	switch c.Scope {
+	case ScopeLogin, ScopeUserInfo, ScopeBase:
+	default:
+		return fmt.Errorf("微信应用 %s 的 scope 不合法: %q", c.Name, c.Scope)
+	}
+	for name, val := range map[string]string{
+		"redirectURI": c.RedirectURI,
+		"authURL":     c.AuthURL,
+		"apiBaseURL":  c.APIBaseURL,
+	} {
+		if err := validateURL(val); err != nil {
+			return fmt.Errorf("微信应用 %s 的 %s 不合法: %w", c.Name, name, err)
+		}
+	}
+	return nil
+}
+
+func validateURL(val string) error {
+	u, err := url.Parse(val)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("必须是 http 或者 https 的地址")
+	}
+	if u.Host == "" {
+		return errors.New("缺少域名")
+	}
+	return nil
+}
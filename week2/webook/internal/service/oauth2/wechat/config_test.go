@@ -0,0 +1,62 @@
+package wechat
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{
+		Name:        "h5",
+		AppID:       "app-id",
+		AppSecret:   "app-secret",
+		RedirectURI: "https://m.meoying.com/oauth2/wechat/callback",
+		Scope:       ScopeUserInfo,
+	}.WithDefaults()
+
+	testCases := []struct {
+		name    string
+		cfg     func() Config
+		wantErr bool
+	}{
+		{
+			name: "合法的配置",
+			cfg:  func() Config { return valid },
+		},
+		{
+			name: "缺少 appSecret",
+			cfg: func() Config {
+				c := valid
+				c.AppSecret = ""
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "scope 不对",
+			cfg: func() Config {
+				c := valid
+				c.Scope = "snsapi_all"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "回调地址不是绝对地址",
+			cfg: func() Config {
+				c := valid
+				c.RedirectURI = "/oauth2/wechat/callback"
+				return c
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg().Validate()
+			assert.Equal(t, tc.wantErr, err != nil)
+		})
+	}
+	// H5 登录默认用的是网页授权的授权页
+	assert.Equal(t, defaultOAuth2AuthorizeURL, valid.AuthURL)
+}
@@ -2,11 +2,12 @@ package wechat
 
 import (
 	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/pkg/httpx"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type Service interface {
@@ -14,53 +15,42 @@ type Service interface {
 	AuthURL(ctx context.Context, state string) (string, error)
 	// VerifyCode 拿到wx确认后跳转回来带有code临时授权码的url
 	VerifyCode(ctx context.Context, code string) (domain.WechatInfo, error)
+	// UserInfo 用 access_token 拉取用户的昵称和头像
+	UserInfo(ctx context.Context, token domain.WechatToken) (domain.WechatUserInfo, error)
+	// RefreshToken access_token 过期后用 refresh_token 换一个新的
+	RefreshToken(ctx context.Context, refreshToken string) (domain.WechatToken, error)
 }
 
-// 替换掉 APPID  REDIRECT_URI SCOPE STATE
-const authURLPattern = `https://open.weixin.qq.com/connect/qrconnect?appid=%s&redirect_uri=%s&response_type=code&scope=snsapi_login&state=%s#wechat_redirect`
-
-// 1. 从wx返回的地址 2. 将AuthURL中的redirectURI进行URL encode（使得url中的"://"编码成"%3A%2F%2F"）
-var redirectURI = url.PathEscape("https://meoying.com/oauth2/wechat/callback")
+// 替换掉 AUTH_URL APPID REDIRECT_URI SCOPE STATE
+const authURLPattern = `%s?appid=%s&redirect_uri=%s&response_type=code&scope=%s&state=%s#wechat_redirect`
 
 type service struct {
 	// 一个应用中appID是不会变的
-	appID     string
-	appSecret string
-	client    *http.Client
+	cfg    Config
+	client httpx.Client
 }
 
-func NewService(appID string, appSecret string) Service {
+// NewService cfg 要先 Validate 过；client 负责超时、重试和状态码检查
+func NewService(cfg Config, client httpx.Client) Service {
 	return &service{
-		appID:     appID,
-		client:    http.DefaultClient, // 先用一个默认的client，但并不符合依赖注入，因为目前不需要定制client
-		appSecret: appSecret,
+		cfg:    cfg.WithDefaults(),
+		client: client,
 	}
 }
 
 func (s *service) AuthURL(ctx context.Context, state string) (string, error) {
-	return fmt.Sprintf(authURLPattern, s.appID, redirectURI, state), nil
+	// 将AuthURL中的redirectURI进行URL encode（使得url中的"://"编码成"%3A%2F%2F"）
+	redirectURI := url.QueryEscape(s.cfg.RedirectURI)
+	return fmt.Sprintf(authURLPattern, s.cfg.AuthURL, s.cfg.AppID, redirectURI, s.cfg.Scope, state), nil
 }
 
 func (s *service) VerifyCode(ctx context.Context, code string) (domain.WechatInfo, error) {
 	// 在代码里面向”https://api.weixin.qq.com/sns/oauth2/access_token?appid=APPID&secret=SECRET&code=CODE&grant_type=authorization_code“发送请求
-	accessTokenUrl := fmt.Sprintf(`https://api.weixin.qq.com/sns/oauth2/access_token?appid=%s&secret=%s&code=%s&grant_type=authorization_code`,
-		s.appID, s.appSecret, code)
-	// 创建一个req
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, accessTokenUrl, nil)
-	if err != nil {
-		return domain.WechatInfo{}, err
-	}
-	// 发送请求
-	rep, err := s.client.Do(req)
-	if err != nil {
-		return domain.WechatInfo{}, err
-	}
-	// 将返回的rep中body中的json反序列化成结构体
-	// note 注意json.NewDecoder().Decode和json.Unmarshal的区别
+	accessTokenUrl := fmt.Sprintf(`%s/sns/oauth2/access_token?appid=%s&secret=%s&code=%s&grant_type=authorization_code`,
+		s.cfg.APIBaseURL, s.cfg.AppID, s.cfg.AppSecret, code)
 	var res Result
-	err = json.NewDecoder(rep.Body).Decode(&res)
+	err := s.get(ctx, accessTokenUrl, &res)
 	if err != nil {
-		// 转json为结构体出错
 		return domain.WechatInfo{}, err
 	}
 	if res.ErrCode != 0 {
@@ -71,9 +61,60 @@ func (s *service) VerifyCode(ctx context.Context, code string) (domain.WechatInf
 	return domain.WechatInfo{
 		OpenId:  res.Openid,
 		UnionId: res.UnionId,
+		// 凭证要带出去，后面拉取用户信息和刷新凭证都要用
+		Token: res.toToken(s.cfg.Name),
 	}, nil
 }
 
+func (s *service) UserInfo(ctx context.Context, token domain.WechatToken) (domain.WechatUserInfo, error) {
+	userInfoUrl := fmt.Sprintf(`%s/sns/userinfo?access_token=%s&openid=%s`,
+		s.cfg.APIBaseURL, token.AccessToken, token.OpenId)
+	var res UserInfoResult
+	err := s.get(ctx, userInfoUrl, &res)
+	if err != nil {
+		return domain.WechatUserInfo{}, err
+	}
+	if res.ErrCode != 0 {
+		return domain.WechatUserInfo{}, fmt.Errorf("微信接口调用失败，错误码：%d，错误信息：%s", res.ErrCode, res.ErrMsg)
+	}
+	return domain.WechatUserInfo{
+		OpenId:   res.Openid,
+		UnionId:  res.UnionId,
+		Nickname: res.Nickname,
+		Avatar:   res.HeadImgURL,
+	}, nil
+}
+
+func (s *service) RefreshToken(ctx context.Context, refreshToken string) (domain.WechatToken, error) {
+	refreshUrl := fmt.Sprintf(`%s/sns/oauth2/refresh_token?appid=%s&grant_type=refresh_token&refresh_token=%s`,
+		s.cfg.APIBaseURL, s.cfg.AppID, refreshToken)
+	var res Result
+	err := s.get(ctx, refreshUrl, &res)
+	if err != nil {
+		return domain.WechatToken{}, err
+	}
+	if res.ErrCode != 0 {
+		return domain.WechatToken{}, fmt.Errorf("微信接口调用失败，错误码：%d，错误信息：%s", res.ErrCode, res.ErrMsg)
+	}
+	return res.toToken(s.cfg.Name), nil
+}
+
+// get 发送 GET 请求，并把返回的 json 反序列化到 res 里
+func (s *service) get(ctx context.Context, url string, res any) error {
+	// 创建一个req
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	// 发送请求，非 200 的响应在 client 里面就变成 error 了
+	rep, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	// 将返回的rep中body中的json反序列化成结构体
+	return rep.JSON(res)
+}
+
 type Result struct {
 	// （1）正确情况下的返回字段
 	// 接口调用凭证
@@ -92,3 +133,30 @@ type Result struct {
 	ErrCode int    `json:"errcode"`
 	ErrMsg  string `json:"errmsg"`
 }
+
+func (r Result) toToken(app string) domain.WechatToken {
+	return domain.WechatToken{
+		App:          app,
+		OpenId:       r.Openid,
+		AccessToken:  r.AccessToken,
+		RefreshToken: r.RefreshToken,
+		Scope:        r.Scope,
+		ExpiresAt:    time.Now().Add(time.Duration(r.ExpiresIn) * time.Second),
+	}
+}
+
+// UserInfoResult 微信 userinfo 接口的返回
+type UserInfoResult struct {
+	Openid   string `json:"openid"`
+	Nickname string `json:"nickname"`
+	// 1 为男性，2 为女性
+	Sex        int    `json:"sex"`
+	Province   string `json:"province"`
+	City       string `json:"city"`
+	Country    string `json:"country"`
+	HeadImgURL string `json:"headimgurl"`
+	UnionId    string `json:"unionid"`
+
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
@@ -0,0 +1,169 @@
+package wechat
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/pkg/httpx"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newFakeWechatServer 模拟微信开放平台的三个接口
+func newFakeWechatServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sns/oauth2/access_token", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code") != "good-code" {
+			_ = json.NewEncoder(w).Encode(Result{ErrCode: 40029, ErrMsg: "invalid code"})
+			return
+		}
+		assert.Equal(t, "test-app-id", q.Get("appid"))
+		assert.Equal(t, "test-app-secret", q.Get("secret"))
+		_ = json.NewEncoder(w).Encode(Result{
+			AccessToken:  "access-1",
+			ExpiresIn:    7200,
+			RefreshToken: "refresh-1",
+			Openid:       "open-1",
+			Scope:        "snsapi_login",
+			UnionId:      "union-1",
+		})
+	})
+	mux.HandleFunc("/sns/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("access_token") != "access-1" {
+			_ = json.NewEncoder(w).Encode(UserInfoResult{ErrCode: 42001, ErrMsg: "access_token expired"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(UserInfoResult{
+			Openid:     q.Get("openid"),
+			Nickname:   "微信用户",
+			HeadImgURL: "https://thirdwx.qlogo.cn/avatar.png",
+			UnionId:    "union-1",
+		})
+	})
+	mux.HandleFunc("/sns/oauth2/refresh_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("refresh_token") != "refresh-1" {
+			_ = json.NewEncoder(w).Encode(Result{ErrCode: 40030, ErrMsg: "invalid refresh_token"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Result{
+			AccessToken:  "access-2",
+			ExpiresIn:    7200,
+			RefreshToken: "refresh-1",
+			Openid:       "open-1",
+			Scope:        "snsapi_login",
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+func newTestService(baseURL string) Service {
+	return NewService(Config{
+		Name:        "web",
+		AppID:       "test-app-id",
+		AppSecret:   "test-app-secret",
+		RedirectURI: "https://meoying.com/oauth2/wechat/callback",
+		Scope:       ScopeLogin,
+		APIBaseURL:  baseURL,
+	}, httpx.NewRetryClient(http.DefaultClient))
+}
+
+func TestService_AuthURL(t *testing.T) {
+	svc := newTestService("")
+	authURL, err := svc.AuthURL(context.Background(), "state-1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://open.weixin.qq.com/connect/qrconnect?appid=test-app-id"+
+		"&redirect_uri=https%3A%2F%2Fmeoying.com%2Foauth2%2Fwechat%2Fcallback"+
+		"&response_type=code&scope=snsapi_login&state=state-1#wechat_redirect", authURL)
+}
+
+func TestService_VerifyCode(t *testing.T) {
+	server := newFakeWechatServer(t)
+	defer server.Close()
+	svc := newTestService(server.URL)
+
+	testCases := []struct {
+		name     string
+		code     string
+		wantInfo domain.WechatInfo
+		wantErr  bool
+	}{
+		{
+			name: "成功拿到凭证",
+			code: "good-code",
+			wantInfo: domain.WechatInfo{
+				OpenId:  "open-1",
+				UnionId: "union-1",
+				Token: domain.WechatToken{
+					App:          "web",
+					OpenId:       "open-1",
+					AccessToken:  "access-1",
+					RefreshToken: "refresh-1",
+					Scope:        "snsapi_login",
+				},
+			},
+		},
+		{
+			name:    "授权码不对",
+			code:    "bad-code",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := svc.VerifyCode(context.Background(), tc.code)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			// 过期时间是按当前时间算的，单独比较
+			assert.WithinDuration(t, time.Now().Add(time.Hour*2), info.Token.ExpiresAt, time.Minute)
+			info.Token.ExpiresAt = time.Time{}
+			assert.Equal(t, tc.wantInfo, info)
+		})
+	}
+}
+
+func TestService_UserInfo(t *testing.T) {
+	server := newFakeWechatServer(t)
+	defer server.Close()
+	svc := newTestService(server.URL)
+
+	info, err := svc.UserInfo(context.Background(), domain.WechatToken{
+		OpenId:      "open-1",
+		AccessToken: "access-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, domain.WechatUserInfo{
+		OpenId:   "open-1",
+		UnionId:  "union-1",
+		Nickname: "微信用户",
+		Avatar:   "https://thirdwx.qlogo.cn/avatar.png",
+	}, info)
+
+	_, err = svc.UserInfo(context.Background(), domain.WechatToken{
+		OpenId:      "open-1",
+		AccessToken: "expired",
+	})
+	assert.Error(t, err)
+}
+
+func TestService_RefreshToken(t *testing.T) {
+	server := newFakeWechatServer(t)
+	defer server.Close()
+	svc := newTestService(server.URL)
+
+	token, err := svc.RefreshToken(context.Background(), "refresh-1")
+	require.NoError(t, err)
+	assert.Equal(t, "access-2", token.AccessToken)
+	assert.Equal(t, "refresh-1", token.RefreshToken)
+
+	_, err = svc.RefreshToken(context.Background(), "refresh-expired")
+	assert.Error(t, err)
+}
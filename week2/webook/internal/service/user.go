@@ -3,32 +3,100 @@ package service
 import (
 	"basic-go/week2/webook/internal/domain"
 	"basic-go/week2/webook/internal/repository"
+	"basic-go/week2/webook/pkg/dbx"
+	"basic-go/week2/webook/pkg/storage"
 	"context"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
 )
 
 // ErrDuplicateEmail 小技巧：如果repo层返回了这个err，则web层可直接从service层调用来进行判定
 var (
 	ErrDuplicateEmail        = repository.ErrDuplicateEmail
 	ErrInvalidUserOrPassword = errors.New("账号或密码错误")
+	ErrUserNotFound          = repository.ErrUserNotFound
+	// ErrSystemBusy 缓存不可用，查数据库又被限流了
+	ErrSystemBusy = repository.ErrDBFallbackLimited
+	// ErrUserDeactivated 账号注销了，冷静期内可以恢复
+	ErrUserDeactivated = errors.New("账号已注销")
+	// ErrReactivateExpired 注销超过了冷静期，不能恢复
+	ErrReactivateExpired = errors.New("账号注销已超过冷静期")
+	// ErrUserProfileConflict 部分更新资料的时候，资料已经被别人（比如另一个设备）改过了，要重新读一次再改
+	ErrUserProfileConflict = repository.ErrUserConflict
+	// ErrDuplicateHandle 用户名已经被别人用了
+	ErrDuplicateHandle = repository.ErrDuplicateHandle
+	// ErrHandleReserved 用户名是保留字，比如和 /users 下面的路由重名
+	ErrHandleReserved = errors.New("用户名是保留字")
+	// ErrHandleChangeTooFrequent 距离上次改用户名还没过冷却期
+	ErrHandleChangeTooFrequent = errors.New("用户名修改太频繁")
 )
 
+// deactivationGracePeriod 注销之后的冷静期，冷静期内可以恢复，过了就清除个人信息
+const deactivationGracePeriod = time.Hour * 24 * 15
+
+// handleChangeCooldown 改了用户名之后多久才能再改，防止有人频繁换名字抢别人的用户名、冒充别人
+const handleChangeCooldown = time.Hour * 24 * 30
+
+// reservedHandles 不能用作用户名的词，全部小写。
+// note 公开主页是 GET /users/{handle}，/users 下面新加了 GET 路由要把路径加进来，不然和这个名字的用户的主页冲突
+var reservedHandles = map[string]struct{}{
+	"profile": {}, "refresh_token": {}, "security": {}, "exports": {}, "edit": {},
+	"signup": {}, "login": {}, "login_sms": {}, "logout": {}, "settings": {}, "avatar": {},
+	"deactivate": {}, "reactivate": {}, "reactivate_sms": {}, "handle": {},
+	"admin": {}, "administrator": {}, "root": {}, "system": {}, "webook": {}, "official": {},
+	"support": {}, "help": {}, "api": {}, "oauth2": {}, "wechat": {}, "me": {}, "null": {}, "undefined": {},
+}
+
 type UserService interface {
+	// Login 密码不对或者账号已注销的时候，返回的 User 里只有 Id，用来记审计日志，不能当成登录成功
 	Login(ctx context.Context, email string, password string) (domain.User, error)
 	SignUp(ctx context.Context, u domain.User) error
 	UpdateNonSensitiveInfo(ctx context.Context, user domain.User) error
+	// UpdateProfile 部分更新资料，返回新的更新时间，下一次更新要带上它
+	UpdateProfile(ctx context.Context, uid int64, patch domain.UserProfilePatch) (time.Time, error)
+	// UpdateHandle 设置或者修改用户名，不区分大小写，统一存小写。和现在的一样就什么都不做
+	UpdateHandle(ctx context.Context, uid int64, handle string) error
+	UpdatePrivacy(ctx context.Context, uid int64, privacy domain.UserPrivacy) error
+	// UpdateLocale 保存用户选的界面语言，locale 是否支持由调用方校验
+	UpdateLocale(ctx context.Context, uid int64, locale string) error
 	FindById(ctx context.Context, id int64) (domain.User, error)
+	// PublicProfile 按用户名查别人的公开主页，按隐私设置去掉了不公开的字段。注销了的账号当成不存在
+	PublicProfile(ctx context.Context, handle string) (domain.User, error)
 	FindOrCreate(ctx context.Context, phone string) (domain.User, error)
 	FindOrCreateByWechat(ctx context.Context, info domain.WechatInfo) (domain.User, error)
+	// Deactivate 注销账号，所有设备上的登录都会失效
+	Deactivate(ctx context.Context, uid int64) error
+	// ReactivateByEmail 冷静期内用邮箱和密码恢复账号，恢复之后就是登录成功。
+	// 和 Login 一样，找到了账号但是恢复失败的，返回的 User 里也有 Id，用来记审计日志
+	ReactivateByEmail(ctx context.Context, email string, password string) (domain.User, error)
+	// ReactivateByPhone 冷静期内用手机号恢复账号，验证码由调用方校验
+	ReactivateByPhone(ctx context.Context, phone string) (domain.User, error)
+	// ReactivateByWechat 冷静期内用微信恢复账号，授权码由调用方校验。只绑了微信的账号只能这样恢复
+	ReactivateByWechat(ctx context.Context, openId string) (domain.User, error)
+	// AnonymizeExpired 清除过了冷静期的账号的个人信息，上传过的头像也一起删掉，返回清除了几个
+	AnonymizeExpired(ctx context.Context) (int, error)
 }
 type userService struct {
-	repo repository.UserRepository
+	repo     repository.UserRepository
+	sessions repository.SessionRepository
+	// 上传的头像存在这里，清除个人信息的时候要删掉
+	store storage.Storage
+	// 注销之后多久清除个人信息
+	gracePeriod time.Duration
+	// 两次改用户名至少隔多久
+	handleCooldown time.Duration
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
+func NewUserService(repo repository.UserRepository, sessions repository.SessionRepository,
+	store storage.Storage) UserService {
 	return &userService{
-		repo: repo,
+		repo:           repo,
+		sessions:       sessions,
+		store:          store,
+		gracePeriod:    deactivationGracePeriod,
+		handleCooldown: handleChangeCooldown,
 	}
 }
 
@@ -56,9 +124,12 @@ func (svc *userService) Login(ctx context.Context, email string, password string
 	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 
 	if err != nil {
-		return domain.User{}, ErrInvalidUserOrPassword
+		return domain.User{Id: u.Id}, ErrInvalidUserOrPassword
+	}
+	// 密码对了才告诉对方账号注销了，不然别人可以拿邮箱来试
+	if u.Status != domain.UserStatusActive {
+		return domain.User{Id: u.Id}, ErrUserDeactivated
 	}
-
 	return u, nil
 
 }
@@ -67,6 +138,60 @@ func (svc *userService) UpdateNonSensitiveInfo(ctx context.Context, user domain.
 	return svc.repo.UpdateNonZeroFields(ctx, user)
 }
 
+func (svc *userService) UpdateProfile(ctx context.Context, uid int64, patch domain.UserProfilePatch) (time.Time, error) {
+	return svc.repo.UpdateProfile(ctx, uid, patch)
+}
+
+func (svc *userService) UpdateHandle(ctx context.Context, uid int64, handle string) error {
+	handle = strings.ToLower(handle)
+	if _, ok := reservedHandles[handle]; ok {
+		return ErrHandleReserved
+	}
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if u.Handle == handle {
+		return nil
+	}
+	// 第一次设置不受冷却期限制
+	before := time.Now().Add(-svc.handleCooldown)
+	if u.HandleUtime.After(before) {
+		return ErrHandleChangeTooFrequent
+	}
+	err = svc.repo.UpdateHandle(ctx, uid, handle, before)
+	if err == repository.ErrUserNotFound {
+		// 查出来之后到更新之间，另一个请求刚改过
+		return ErrHandleChangeTooFrequent
+	}
+	return err
+}
+
+func (svc *userService) UpdatePrivacy(ctx context.Context, uid int64, privacy domain.UserPrivacy) error {
+	return svc.repo.UpdatePrivacy(ctx, uid, privacy)
+}
+
+func (svc *userService) PublicProfile(ctx context.Context, handle string) (domain.User, error) {
+	u, err := svc.repo.FindByHandle(ctx, strings.ToLower(handle))
+	if err != nil {
+		return domain.User{}, err
+	}
+	if u.Status != domain.UserStatusActive {
+		return domain.User{}, ErrUserNotFound
+	}
+	if u.Privacy.HideBirthday {
+		u.Birthday = time.UnixMilli(0)
+	}
+	if u.Privacy.HideResume {
+		u.Resume = ""
+	}
+	return u, nil
+}
+
+func (svc *userService) UpdateLocale(ctx context.Context, uid int64, locale string) error {
+	return svc.repo.UpdateLocale(ctx, uid, locale)
+}
+
 func (svc *userService) FindById(ctx context.Context, id int64) (domain.User, error) {
 	return svc.repo.FindById(ctx, id)
 }
@@ -77,7 +202,7 @@ func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.
 		// 两种情况
 		// 1. err != nil ==> 系统错误
 		// 2. err == nil ==> u可用
-		return u, err
+		return svc.checkActive(u, err)
 	}
 	// Find失败就Create
 	err = svc.repo.Create(ctx, domain.User{
@@ -90,8 +215,8 @@ func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.
 	// 两种情况
 	// 1. err == ErrDuplicatePhone ==> 手机号冲突
 	// 2. err == nil ==> 创建成功
-	// TODO 主从延迟 ==>插入进的是主库，查询查的是从库，所以可能刚插进去就查的话查不到，因为主从库还没同步完成【解决方式是强制查主库，但还没做】
-	return svc.repo.FindByPhone(ctx, phone)
+	// note 主从延迟 ==>插入进的是主库，查询查的是从库，所以可能刚插进去就查的话查不到，因为主从库还没同步完成，所以强制查主库
+	return svc.repo.FindByPhone(dbx.WithPrimary(ctx), phone)
 }
 
 func (svc *userService) FindOrCreateByWechat(ctx context.Context, info domain.WechatInfo) (domain.User, error) {
@@ -100,19 +225,123 @@ func (svc *userService) FindOrCreateByWechat(ctx context.Context, info domain.We
 		// 两种情况
 		// 1. err != nil ==> 系统错误
 		// 2. err == nil ==> u可用
-		return u, err
+		return svc.checkActive(u, err)
 	}
 	// Find失败就Create
 	err = svc.repo.Create(ctx, domain.User{
 		WechatInfo: info,
 	})
-	if err != nil && err != repository.ErrDuplicatePhone {
+	if err != nil && err != repository.ErrDuplicateWechat {
 		// 系统错误
 		return domain.User{}, err
 	}
 	// 两种情况
-	// 1. err == ErrDuplicatePhone ==> 手机号冲突
+	// 1. err == ErrDuplicateWechat ==> 并发的另一个请求已经用这个微信创建了用户
 	// 2. err == nil ==> 创建成功
-	// TODO 主从延迟 ==>插入进的是主库，查询查的是从库，所以可能刚插进去就查的话查不到，因为主从库还没同步完成【解决方式是强制查主库，但还没做】
-	return svc.repo.FindByWechat(ctx, info.OpenId)
+	// note 主从延迟，同上，强制查主库
+	return svc.repo.FindByWechat(dbx.WithPrimary(ctx), info.OpenId)
+}
+
+// checkActive 注销了的账号不能登录，也不能用同一个手机号、微信再建一个，要先恢复或者等冷静期过了
+func (svc *userService) checkActive(u domain.User, err error) (domain.User, error) {
+	if err != nil {
+		return domain.User{}, err
+	}
+	if u.Status != domain.UserStatusActive {
+		// 和 Login 一样，带上 Id 给审计日志用
+		return domain.User{Id: u.Id}, ErrUserDeactivated
+	}
+	return u, nil
+}
+
+func (svc *userService) Deactivate(ctx context.Context, uid int64) error {
+	err := svc.repo.Deactivate(ctx, uid, time.Now())
+	if err != nil {
+		return err
+	}
+	// 重复注销的时候这里还会再作废一次，所以作废失败了让用户重试就行
+	return svc.sessions.RevokeAll(ctx, uid)
+}
+
+func (svc *userService) ReactivateByEmail(ctx context.Context, email string, password string) (domain.User, error) {
+	u, err := svc.repo.FindByEmail(ctx, email)
+	if err == repository.ErrUserNotFound {
+		return domain.User{}, ErrInvalidUserOrPassword
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+	if err != nil {
+		return domain.User{Id: u.Id}, ErrInvalidUserOrPassword
+	}
+	return svc.reactivate(ctx, u)
+}
+
+func (svc *userService) ReactivateByPhone(ctx context.Context, phone string) (domain.User, error) {
+	u, err := svc.repo.FindByPhone(ctx, phone)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return svc.reactivate(ctx, u)
+}
+
+func (svc *userService) ReactivateByWechat(ctx context.Context, openId string) (domain.User, error) {
+	u, err := svc.repo.FindByWechat(ctx, openId)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return svc.reactivate(ctx, u)
+}
+
+// reactivate 没注销的账号直接返回，相当于登录
+func (svc *userService) reactivate(ctx context.Context, u domain.User) (domain.User, error) {
+	if u.Status == domain.UserStatusActive {
+		return u, nil
+	}
+	since := time.Now().Add(-svc.gracePeriod)
+	if u.Status != domain.UserStatusDeactivated || u.DeactivatedAt.Before(since) {
+		return domain.User{Id: u.Id}, ErrReactivateExpired
+	}
+	err := svc.repo.Reactivate(ctx, u.Id, since)
+	if err == repository.ErrUserNotFound {
+		// 查出来之后到更新之间，刚好过了冷静期
+		return domain.User{Id: u.Id}, ErrReactivateExpired
+	}
+	if err != nil {
+		return domain.User{Id: u.Id}, err
+	}
+	u.Status = domain.UserStatusActive
+	u.DeactivatedAt = time.Time{}
+	return u, nil
+}
+
+func (svc *userService) AnonymizeExpired(ctx context.Context) (int, error) {
+	const batchSize = 100
+	before := time.Now().Add(-svc.gracePeriod)
+	cnt := 0
+	var startId int64
+	for {
+		us, err := svc.repo.FindDeactivated(ctx, before, startId, batchSize)
+		if err != nil {
+			return cnt, err
+		}
+		for _, u := range us {
+			err = svc.repo.Anonymize(ctx, u.Id)
+			if err == repository.ErrUserNotFound {
+				// 查出来之后刚好恢复了，头像还在用
+				continue
+			}
+			if err != nil {
+				return cnt, err
+			}
+			// 头像的地址已经清掉了，文件还在，而且还能公开访问
+			deleteAvatar(ctx, svc.store, u.Id, u.Avatar)
+			cnt++
+		}
+		if len(us) < batchSize {
+			return cnt, nil
+		}
+		startId = us[len(us)-1].Id
+	}
 }
@@ -1,9 +1,17 @@
 package service
 
 import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository"
+	"basic-go/week2/webook/pkg/storage"
+	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestPasswordEncrypt(t *testing.T) {
@@ -15,3 +23,189 @@ func TestPasswordEncrypt(t *testing.T) {
 	println(string(encrypted))
 
 }
+
+// handleUserRepository 只实现了改用户名用到的方法
+type handleUserRepository struct {
+	repository.UserRepository
+	user    domain.User
+	updated string
+}
+
+func (r *handleUserRepository) FindById(ctx context.Context, uid int64) (domain.User, error) {
+	return r.user, nil
+}
+
+func (r *handleUserRepository) UpdateHandle(ctx context.Context, uid int64, handle string, before time.Time) error {
+	r.updated = handle
+	return nil
+}
+
+func TestUserService_UpdateHandle(t *testing.T) {
+	testCases := []struct {
+		name        string
+		user        domain.User
+		handle      string
+		wantErr     error
+		wantUpdated string
+	}{
+		{
+			name:        "第一次设置，统一存小写",
+			user:        domain.User{Id: 1},
+			handle:      "DaMing",
+			wantUpdated: "daming",
+		},
+		{
+			name:    "保留字",
+			user:    domain.User{Id: 1},
+			handle:  "Profile",
+			wantErr: ErrHandleReserved,
+		},
+		{
+			name:    "冷却期内",
+			user:    domain.User{Id: 1, Handle: "daming", HandleUtime: time.Now().Add(-time.Hour)},
+			handle:  "xiaoming",
+			wantErr: ErrHandleChangeTooFrequent,
+		},
+		{
+			name:   "和现在的一样",
+			user:   domain.User{Id: 1, Handle: "daming", HandleUtime: time.Now().Add(-time.Hour)},
+			handle: "DAMING",
+		},
+		{
+			name:        "过了冷却期",
+			user:        domain.User{Id: 1, Handle: "daming", HandleUtime: time.Now().Add(-handleChangeCooldown - time.Hour)},
+			handle:      "xiaoming",
+			wantUpdated: "xiaoming",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &handleUserRepository{user: tc.user}
+			svc := NewUserService(repo, nil, nil)
+			err := svc.UpdateHandle(context.Background(), 1, tc.handle)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUpdated, repo.updated)
+		})
+	}
+}
+
+// reactivateUserRepository 只实现了用微信恢复账号用到的方法
+type reactivateUserRepository struct {
+	repository.UserRepository
+	user        domain.User
+	reactivated bool
+}
+
+func (r *reactivateUserRepository) FindByWechat(ctx context.Context, openId string) (domain.User, error) {
+	if openId != r.user.WechatInfo.OpenId {
+		return domain.User{}, repository.ErrUserNotFound
+	}
+	return r.user, nil
+}
+
+func (r *reactivateUserRepository) Reactivate(ctx context.Context, uid int64, since time.Time) error {
+	r.reactivated = true
+	return nil
+}
+
+func TestUserService_ReactivateByWechat(t *testing.T) {
+	testCases := []struct {
+		name   string
+		user   domain.User
+		openId string
+
+		wantUser        domain.User
+		wantErr         error
+		wantReactivated bool
+	}{
+		{
+			name: "冷静期内恢复",
+			user: domain.User{Id: 1, WechatInfo: domain.WechatInfo{OpenId: "open-1"},
+				Status: domain.UserStatusDeactivated, DeactivatedAt: time.Now().Add(-time.Hour)},
+			openId:          "open-1",
+			wantUser:        domain.User{Id: 1, WechatInfo: domain.WechatInfo{OpenId: "open-1"}, Status: domain.UserStatusActive},
+			wantReactivated: true,
+		},
+		{
+			name:     "没注销的直接登录",
+			user:     domain.User{Id: 1, WechatInfo: domain.WechatInfo{OpenId: "open-1"}, Status: domain.UserStatusActive},
+			openId:   "open-1",
+			wantUser: domain.User{Id: 1, WechatInfo: domain.WechatInfo{OpenId: "open-1"}, Status: domain.UserStatusActive},
+		},
+		{
+			name: "过了冷静期",
+			user: domain.User{Id: 1, WechatInfo: domain.WechatInfo{OpenId: "open-1"},
+				Status: domain.UserStatusDeactivated, DeactivatedAt: time.Now().Add(-deactivationGracePeriod - time.Hour)},
+			openId:   "open-1",
+			wantUser: domain.User{Id: 1},
+			wantErr:  ErrReactivateExpired,
+		},
+		{
+			name:    "没有这个微信的账号",
+			user:    domain.User{Id: 1, WechatInfo: domain.WechatInfo{OpenId: "open-1"}},
+			openId:  "open-2",
+			wantErr: repository.ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &reactivateUserRepository{user: tc.user}
+			svc := NewUserService(repo, nil, nil)
+			u, err := svc.ReactivateByWechat(context.Background(), tc.openId)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUser, u)
+			assert.Equal(t, tc.wantReactivated, repo.reactivated)
+		})
+	}
+}
+
+// anonymizeUserRepository 只实现了清除注销账号用到的方法
+type anonymizeUserRepository struct {
+	repository.UserRepository
+	users []domain.User
+	// 查出来之后刚好恢复了的
+	reactivated map[int64]bool
+}
+
+func (r *anonymizeUserRepository) FindDeactivated(ctx context.Context, before time.Time, startId int64, limit int) ([]domain.User, error) {
+	return r.users, nil
+}
+
+func (r *anonymizeUserRepository) Anonymize(ctx context.Context, uid int64) error {
+	if r.reactivated[uid] {
+		return repository.ErrUserNotFound
+	}
+	return nil
+}
+
+func TestUserService_AnonymizeExpired(t *testing.T) {
+	root := t.TempDir()
+	store := storage.NewLocalStorage(root, "/uploads")
+	ctx := context.Background()
+	exists := func(key string) bool {
+		_, err := os.Stat(filepath.Join(root, filepath.FromSlash(key)))
+		return err == nil
+	}
+	for _, key := range []string{"avatars/1/a.jpg", "avatars/1/a_thumb.jpg", "avatars/3/c.jpg", "avatars/3/c_thumb.jpg"} {
+		require.NoError(t, store.Put(ctx, key, []byte("jpg"), "image/jpeg"))
+	}
+	repo := &anonymizeUserRepository{
+		users: []domain.User{
+			{Id: 1, Avatar: "/uploads/avatars/1/a.jpg"},
+			// 微信的头像不是我们存的
+			{Id: 2, Avatar: "https://thirdwx.qlogo.cn/avatar.png"},
+			{Id: 3, Avatar: "/uploads/avatars/3/c.jpg"},
+		},
+		reactivated: map[int64]bool{3: true},
+	}
+	svc := NewUserService(repo, nil, store)
+
+	cnt, err := svc.AnonymizeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+	assert.False(t, exists("avatars/1/a.jpg"))
+	assert.False(t, exists("avatars/1/a_thumb.jpg"))
+	// 恢复了的头像还在用
+	assert.True(t, exists("avatars/3/c.jpg"))
+	assert.True(t, exists("avatars/3/c_thumb.jpg"))
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/repository"
+	"basic-go/week2/webook/internal/service/oauth2/wechat"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// WechatUserService 微信登录之后的事情：保存凭证、补全用户资料、刷新快过期的凭证
+type WechatUserService interface {
+	// SyncProfile 保存凭证，首次登录（没有昵称和头像）时用微信的昵称和头像补全用户资料
+	SyncProfile(ctx context.Context, u domain.User, token domain.WechatToken) (domain.User, error)
+	// RefreshExpiring 刷新在 window 之内就要过期的 access_token，返回刷新成功的个数
+	RefreshExpiring(ctx context.Context, window time.Duration) (int, error)
+}
+
+type wechatUserService struct {
+	apps      *wechat.Apps
+	userRepo  repository.UserRepository
+	tokenRepo repository.WechatTokenRepository
+	// 每一批刷新多少个凭证
+	batchSize int
+}
+
+func NewWechatUserService(apps *wechat.Apps, userRepo repository.UserRepository,
+	tokenRepo repository.WechatTokenRepository) WechatUserService {
+	return &wechatUserService{
+		apps:      apps,
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		batchSize: 100,
+	}
+}
+
+func (s *wechatUserService) SyncProfile(ctx context.Context, u domain.User, token domain.WechatToken) (domain.User, error) {
+	token.Uid = u.Id
+	err := s.tokenRepo.Save(ctx, token)
+	if err != nil {
+		return u, err
+	}
+	if u.Nickname != "" || u.Avatar != "" {
+		// 用户已经有自己的资料了，不能用微信的覆盖掉
+		return u, nil
+	}
+	svc, ok := s.apps.Get(token.App)
+	if !ok {
+		return u, fmt.Errorf("未知的微信应用 %s", token.App)
+	}
+	info, err := svc.UserInfo(ctx, token)
+	if err != nil {
+		return u, err
+	}
+	u.Nickname = info.Nickname
+	u.Avatar = info.Avatar
+	return u, s.userRepo.UpdateNonZeroFields(ctx, u)
+}
+
+func (s *wechatUserService) RefreshExpiring(ctx context.Context, window time.Duration) (int, error) {
+	deadline := time.Now().Add(window)
+	cnt := 0
+	var startUid int64
+	for {
+		tokens, err := s.tokenRepo.FindExpiring(ctx, deadline, startUid, s.batchSize)
+		if err != nil {
+			return cnt, err
+		}
+		for _, t := range tokens {
+			startUid = t.Uid
+			svc, ok := s.apps.Get(t.App)
+			if t.RefreshToken == "" || !ok {
+				// 解密失败的凭证，或者这个微信应用已经下线了，没法刷新
+				continue
+			}
+			nt, err := svc.RefreshToken(ctx, t.RefreshToken)
+			if err != nil {
+				// refresh_token 也过期了（30 天），只能等用户下次扫码登录
+				log.Println("刷新微信凭证失败", t.Uid, err)
+				continue
+			}
+			nt.Uid = t.Uid
+			if err = s.tokenRepo.Save(ctx, nt); err != nil {
+				log.Println("保存微信凭证失败", t.Uid, err)
+				continue
+			}
+			cnt++
+		}
+		if len(tokens) < s.batchSize {
+			return cnt, nil
+		}
+	}
+}
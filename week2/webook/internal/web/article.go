@@ -0,0 +1,421 @@
+package web
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/service"
+	"basic-go/week2/webook/internal/web/errs"
+	ijwt "basic-go/week2/webook/internal/web/jwt"
+	"basic-go/week2/webook/internal/web/validation"
+	"context"
+	"github.com/gin-gonic/gin"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArticleHandler 作者写文章，只能操作自己的文章；/articles/pub 下面是给读者看的，只有已经发表的文章
+type ArticleHandler struct {
+	svc       service.ArticleService
+	interSvc  service.InteractiveService
+	validator *validation.Validator
+}
+
+// articleBiz 文章在阅读、点赞、收藏里的业务名，已经存进数据库了，不能改
+const articleBiz = "article"
+
+func NewArticleHandler(svc service.ArticleService, interSvc service.InteractiveService) *ArticleHandler {
+	return &ArticleHandler{
+		svc:       svc,
+		interSvc:  interSvc,
+		validator: validation.NewValidator(),
+	}
+}
+
+func (h *ArticleHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/articles")
+	g.POST("/edit", h.Edit)
+	g.POST("/publish", h.Publish)
+	g.POST("/withdraw", h.Withdraw)
+	g.GET("/list", h.List)
+	g.GET("/detail/:id", h.Detail)
+	// 读者看的，只有已经发表的
+	g.GET("/pub/list", h.PubList)
+	g.GET("/pub/:id", h.PubDetail)
+	g.POST("/pub/like", h.Like)
+	g.POST("/pub/collect", h.Collect)
+}
+
+// Edit 保存草稿，不带 id 就是新建，返回文章的 id
+func (h *ArticleHandler) Edit(ctx *gin.Context) {
+	type Req struct {
+		Id      int64  `json:"id"`
+		Title   string `json:"title" validate:"max=256"`
+		Content string `json:"content"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	id, err := h.svc.Save(ctx, domain.Article{
+		Id:      req.Id,
+		Title:   req.Title,
+		Content: req.Content,
+		Author:  domain.Author{Id: uc.Uid},
+	})
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeData(ctx, id)
+}
+
+// Publish 保存并发表，草稿可以没有标题和内容，发表的时候必须有
+func (h *ArticleHandler) Publish(ctx *gin.Context) {
+	type Req struct {
+		Id      int64  `json:"id"`
+		Title   string `json:"title" validate:"required,max=256"`
+		Content string `json:"content" validate:"required"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	id, err := h.svc.Publish(ctx, domain.Article{
+		Id:      req.Id,
+		Title:   req.Title,
+		Content: req.Content,
+		Author:  domain.Author{Id: uc.Uid},
+	})
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeData(ctx, id)
+}
+
+func (h *ArticleHandler) Withdraw(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id" validate:"required"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	if err := h.svc.Withdraw(ctx, uc.Uid, req.Id); err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeOK(ctx, "article.withdraw_ok")
+}
+
+// List 作者自己的文章，只返回摘要，?offset=0&limit=20
+func (h *ArticleHandler) List(ctx *gin.Context) {
+	offset, err := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	arts, err := h.svc.List(ctx, uc.Uid, offset, limit)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	res := make([]articleVO, 0, len(arts))
+	for _, art := range arts {
+		vo := newArticleVO(art)
+		// 列表不返回全文
+		vo.Content = ""
+		vo.Abstract = art.Abstract()
+		res = append(res, vo)
+	}
+	writeData(ctx, res)
+}
+
+func (h *ArticleHandler) Detail(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		writeErr(ctx, service.ErrArticleNotFound)
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	art, err := h.svc.Detail(ctx, uc.Uid, id)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeData(ctx, newArticleVO(art))
+}
+
+// PubDetail 读者看文章，阅读数加一，带上阅读、点赞、收藏数和自己有没有点赞、收藏
+func (h *ArticleHandler) PubDetail(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		writeErr(ctx, service.ErrArticleNotFound)
+		return
+	}
+	art, err := h.svc.PublishedDetail(ctx, id)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	// 阅读数不用等，失败了也不影响看文章。请求结束之后 ctx 会被取消，要用新的
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := h.interSvc.IncrReadCnt(ctx, articleBiz, id); err != nil {
+			log.Println("增加阅读数失败", id, err)
+		}
+	}()
+	vo := newPubArticleVO(art)
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	// 计数查不到也不影响看文章，不返回 interactive 就行
+	intr, err := h.interSvc.Get(ctx, articleBiz, id, uc.Uid)
+	if err != nil {
+		log.Println("查询文章计数失败", id, err)
+	} else {
+		vo.Interactive = newInteractiveVO(intr)
+	}
+	writeData(ctx, vo)
+}
+
+// Like 点赞、取消点赞，重复点没关系
+func (h *ArticleHandler) Like(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id" validate:"required"`
+		// Like 是 false 就是取消点赞
+		Like bool `json:"like"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	var err error
+	if req.Like {
+		err = h.toggle(ctx, req.Id, uc.Uid, h.interSvc.Like)
+	} else {
+		// 取消不用管文章还在不在，撤回了的文章也要能取消
+		err = h.interSvc.CancelLike(ctx, articleBiz, req.Id, uc.Uid)
+	}
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeOK(ctx, "article.like_ok")
+}
+
+// Collect 收藏、取消收藏，重复点没关系
+func (h *ArticleHandler) Collect(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id" validate:"required"`
+		// Collect 是 false 就是取消收藏
+		Collect bool `json:"collect"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	var err error
+	if req.Collect {
+		err = h.toggle(ctx, req.Id, uc.Uid, h.interSvc.Collect)
+	} else {
+		err = h.interSvc.CancelCollect(ctx, articleBiz, req.Id, uc.Uid)
+	}
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeOK(ctx, "article.collect_ok")
+}
+
+// toggle 只能给读者看得到的文章点赞、收藏，不然随便一个 id 都能插进数据库
+func (h *ArticleHandler) toggle(ctx *gin.Context, id int64, uid int64,
+	fn func(ctx context.Context, biz string, bizId int64, uid int64) error) error {
+	if _, err := h.svc.PublishedDetail(ctx, id); err != nil {
+		return err
+	}
+	return fn(ctx, articleBiz, id, uid)
+}
+
+// PubList 作者发表的文章，?author=作者id&cursor=&limit=20。
+// 第一页不带 cursor，下一页带上一页返回的 nextCursor，nextCursor 是空的说明没有了
+func (h *ArticleHandler) PubList(ctx *gin.Context) {
+	author, err := strconv.ParseInt(ctx.Query("author"), 10, 64)
+	if err != nil || author <= 0 {
+		writeCode(ctx, errs.InvalidParam)
+		return
+	}
+	cursor, ok := parseArticleCursor(ctx.Query("cursor"))
+	if !ok {
+		writeCode(ctx, errs.InvalidParam)
+		return
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	arts, next, err := h.svc.PublishedList(ctx, author, cursor, limit)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	type Resp struct {
+		Articles   []pubArticleVO `json:"articles"`
+		NextCursor string         `json:"nextCursor"`
+	}
+	res := Resp{
+		Articles:   make([]pubArticleVO, 0, len(arts)),
+		NextCursor: formatArticleCursor(next),
+	}
+	ids := make([]int64, 0, len(arts))
+	for _, art := range arts {
+		ids = append(ids, art.Id)
+	}
+	// 计数查不到也不影响看列表，不返回 interactive 就行
+	intrs, err := h.interSvc.GetByIds(ctx, articleBiz, ids)
+	if err != nil {
+		log.Println("批量查询文章计数失败", author, err)
+	}
+	for _, art := range arts {
+		vo := newPubArticleVO(art)
+		// Content 只有摘要
+		vo.Content = ""
+		vo.Abstract = art.Content
+		if intr, ok := intrs[art.Id]; ok {
+			vo.Interactive = newInteractiveVO(intr)
+		}
+		res.Articles = append(res.Articles, vo)
+	}
+	writeData(ctx, res)
+}
+
+// cursor 在前端看来是个不透明的字符串，实际是 "utime毫秒_id"
+func formatArticleCursor(c domain.ArticleCursor) string {
+	if c.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(c.Utime.UnixMilli(), 10) + "_" + strconv.FormatInt(c.Id, 10)
+}
+
+func parseArticleCursor(s string) (domain.ArticleCursor, bool) {
+	if s == "" {
+		return domain.ArticleCursor{}, true
+	}
+	utimeStr, idStr, found := strings.Cut(s, "_")
+	if !found {
+		return domain.ArticleCursor{}, false
+	}
+	utime, err1 := strconv.ParseInt(utimeStr, 10, 64)
+	id, err2 := strconv.ParseInt(idStr, 10, 64)
+	if err1 != nil || err2 != nil || id <= 0 {
+		return domain.ArticleCursor{}, false
+	}
+	return domain.ArticleCursor{Utime: time.UnixMilli(utime), Id: id}, true
+}
+
+// pubArticleVO 读者看到的文章，不返回状态
+type pubArticleVO struct {
+	Id       int64  `json:"id"`
+	Title    string `json:"title"`
+	Abstract string `json:"abstract,omitempty"`
+	Content  string `json:"content,omitempty"`
+	Author   struct {
+		Id   int64  `json:"id"`
+		Name string `json:"name"`
+	} `json:"author"`
+	// 毫秒
+	Ctime int64 `json:"ctime"`
+	Utime int64 `json:"utime"`
+	// 列表里没有 liked、collected
+	Interactive *interactiveVO `json:"interactive,omitempty"`
+}
+
+type interactiveVO struct {
+	ReadCnt    int64 `json:"readCnt"`
+	LikeCnt    int64 `json:"likeCnt"`
+	CollectCnt int64 `json:"collectCnt"`
+	Liked      bool  `json:"liked"`
+	Collected  bool  `json:"collected"`
+}
+
+func newInteractiveVO(intr domain.Interactive) *interactiveVO {
+	return &interactiveVO{
+		ReadCnt:    intr.ReadCnt,
+		LikeCnt:    intr.LikeCnt,
+		CollectCnt: intr.CollectCnt,
+		Liked:      intr.Liked,
+		Collected:  intr.Collected,
+	}
+}
+
+func newPubArticleVO(art domain.Article) pubArticleVO {
+	vo := pubArticleVO{
+		Id:      art.Id,
+		Title:   art.Title,
+		Content: art.Content,
+		Ctime:   art.Ctime.UnixMilli(),
+		Utime:   art.Utime.UnixMilli(),
+	}
+	vo.Author.Id = art.Author.Id
+	vo.Author.Name = art.Author.Name
+	return vo
+}
+
+type articleVO struct {
+	Id       int64  `json:"id"`
+	Title    string `json:"title"`
+	Abstract string `json:"abstract,omitempty"`
+	Content  string `json:"content,omitempty"`
+	Status   string `json:"status"`
+	// 毫秒
+	Ctime int64 `json:"ctime"`
+	Utime int64 `json:"utime"`
+}
+
+func newArticleVO(art domain.Article) articleVO {
+	return articleVO{
+		Id:      art.Id,
+		Title:   art.Title,
+		Content: art.Content,
+		Status:  articleStatusNames[art.Status],
+		Ctime:   art.Ctime.UnixMilli(),
+		Utime:   art.Utime.UnixMilli(),
+	}
+}
+
+// 前端看到的文章状态
+var articleStatusNames = map[domain.ArticleStatus]string{
+	domain.ArticleStatusUnknown:     "unknown",
+	domain.ArticleStatusUnpublished: "unpublished",
+	domain.ArticleStatusPublished:   "published",
+	domain.ArticleStatusPrivate:     "private",
+}
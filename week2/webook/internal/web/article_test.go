@@ -0,0 +1,163 @@
+package web
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/service"
+	svcmocks "basic-go/week2/webook/internal/service/mocks"
+	"basic-go/week2/webook/internal/web/errs"
+	ijwt "basic-go/week2/webook/internal/web/jwt"
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestArticleHandler_Publish(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) service.ArticleService
+		body     string
+		wantCode int
+		wantBody Result
+	}{
+		{
+			name: "新建并发表",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().Publish(gomock.Any(), domain.Article{
+					Title:   "标题",
+					Content: "内容",
+					Author:  domain.Author{Id: 1},
+				}).Return(int64(10), nil)
+				return svc
+			},
+			body:     `{"title":"标题","content":"内容"}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Msg: "OK", Data: float64(10)},
+		},
+		{
+			name: "发表别人的文章",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().Publish(gomock.Any(), domain.Article{
+					Id:      2,
+					Title:   "标题",
+					Content: "内容",
+					Author:  domain.Author{Id: 1},
+				}).Return(int64(0), service.ErrArticleNotFound)
+				return svc
+			},
+			body:     `{"id":2,"title":"标题","content":"内容"}`,
+			wantCode: http.StatusNotFound,
+			wantBody: Result{Code: errs.ArticleNotFound.Code, Msg: errs.ArticleNotFound.Msg},
+		},
+		{
+			name: "没有标题",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				return svcmocks.NewMockArticleService(ctrl)
+			},
+			body:     `{"content":"内容"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: Result{Code: errs.InvalidParam.Code, Msg: errs.InvalidParam.Msg, Data: []any{
+				map[string]any{"field": "title", "rule": "required", "msg": "不能为空"},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			h := NewArticleHandler(tc.mock(ctrl), svcmocks.NewMockInteractiveService(ctrl))
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 1})
+			})
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/articles/publish", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.wantCode, resp.Code)
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+func TestArticleHandler_Like(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (service.ArticleService, service.InteractiveService)
+		body     string
+		wantCode int
+		wantBody Result
+	}{
+		{
+			name: "点赞",
+			mock: func(ctrl *gomock.Controller) (service.ArticleService, service.InteractiveService) {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				interSvc := svcmocks.NewMockInteractiveService(ctrl)
+				svc.EXPECT().PublishedDetail(gomock.Any(), int64(2)).Return(domain.Article{Id: 2}, nil)
+				interSvc.EXPECT().Like(gomock.Any(), "article", int64(2), int64(1)).Return(nil)
+				return svc, interSvc
+			},
+			body:     `{"id":2,"like":true}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Msg: "article.like_ok"},
+		},
+		{
+			name: "文章不存在或者撤回了，不能点赞",
+			mock: func(ctrl *gomock.Controller) (service.ArticleService, service.InteractiveService) {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().PublishedDetail(gomock.Any(), int64(2)).Return(domain.Article{}, service.ErrArticleNotFound)
+				return svc, svcmocks.NewMockInteractiveService(ctrl)
+			},
+			body:     `{"id":2,"like":true}`,
+			wantCode: http.StatusNotFound,
+			wantBody: Result{Code: errs.ArticleNotFound.Code, Msg: errs.ArticleNotFound.Msg},
+		},
+		{
+			name: "取消点赞，不用查文章",
+			mock: func(ctrl *gomock.Controller) (service.ArticleService, service.InteractiveService) {
+				interSvc := svcmocks.NewMockInteractiveService(ctrl)
+				interSvc.EXPECT().CancelLike(gomock.Any(), "article", int64(2), int64(1)).Return(nil)
+				return svcmocks.NewMockArticleService(ctrl), interSvc
+			},
+			body:     `{"id":2,"like":false}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Msg: "article.like_ok"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			h := NewArticleHandler(tc.mock(ctrl))
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 1})
+			})
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/articles/pub/like", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.wantCode, resp.Code)
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package web
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/service"
+	"basic-go/week2/webook/internal/web/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// recordAuthEvent 记一条认证的审计日志，code 是返回给前端的错误码，errs.OK 就是成功。
+// uid 不知道就传 0，比如账号不存在、验证码不对
+func recordAuthEvent(ctx *gin.Context, svc service.AuthAuditService, method domain.AuthMethod, uid int64, code errs.Code) {
+	e := domain.AuthEvent{
+		Uid:       uid,
+		Method:    method,
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.GetHeader("User-Agent"),
+		Success:   code == errs.OK,
+	}
+	if !e.Success {
+		e.Reason = code.MsgKey
+	}
+	svc.Record(ctx, e)
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"basic-go/week2/webook/internal/service"
+	"basic-go/week2/webook/internal/web/errs"
+	ijwt "basic-go/week2/webook/internal/web/jwt"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+)
+
+// UserAvatarHandler 上传头像，上传成功之后 /users/profile 返回的 avatar 就是新头像
+type UserAvatarHandler struct {
+	svc service.AvatarService
+	// 上传的文件最大多少字节
+	maxSize int64
+}
+
+func NewUserAvatarHandler(svc service.AvatarService) *UserAvatarHandler {
+	return &UserAvatarHandler{
+		svc:     svc,
+		maxSize: 5 << 20,
+	}
+}
+
+func (h *UserAvatarHandler) RegisterRoutes(server *gin.Engine) {
+	server.POST("/users/avatar", h.Upload)
+}
+
+// Upload multipart/form-data，文件放在 avatar 字段里
+func (h *UserAvatarHandler) Upload(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	// 留 1KB 给 multipart 的边界和 header，不然刚好 maxSize 的文件也传不上来
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.maxSize+1<<10)
+	fh, err := ctx.FormFile("avatar")
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			writeCode(ctx, errs.UserAvatarTooLarge)
+			return
+		}
+		writeCode(ctx, errs.InvalidParam)
+		return
+	}
+	if fh.Size > h.maxSize {
+		writeCode(ctx, errs.UserAvatarTooLarge)
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	avatar, err := h.svc.Upload(ctx, uc.Uid, data)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	type Avatar struct {
+		URL          string `json:"url"`
+		ThumbnailURL string `json:"thumbnailUrl"`
+	}
+	writeData(ctx, Avatar{
+		URL:          avatar.URL,
+		ThumbnailURL: avatar.ThumbnailURL,
+	})
+}
@@ -0,0 +1,80 @@
+package errs
+
+import "net/http"
+
+// Code 业务错误码，前端按 Code 判断，不要按 Msg 判断
+// 错误码一共 6 位：第 1 位 4 表示是用户（调用方）的问题，5 表示是系统的问题；
+// 第 2、3 位是模块：00 通用，01 用户，02 验证码，03 微信登录，04 数据导出，05 文章；最后 3 位是模块内的序号。
+// 已经发出去的错误码不能改，只能新增
+type Code struct {
+	Code int
+	// HTTPStatus 这个错误对应的 HTTP 状态码
+	HTTPStatus int
+	// MsgKey 国际化的消息 key
+	MsgKey string
+	// Msg 默认的中文消息
+	Msg string
+}
+
+// Error 让 Code 可以直接当 error 返回
+func (c Code) Error() string {
+	return c.MsgKey
+}
+
+var (
+	OK = Code{0, http.StatusOK, "common.ok", "OK"}
+
+	// 通用
+	InvalidParam = Code{400001, http.StatusBadRequest, "common.invalid_param", "参数错误"}
+	// Unauthorized 前端收到 401 会跳去登录页，所以只有登录态的问题才能用
+	Unauthorized = Code{400002, http.StatusUnauthorized, "common.unauthorized", "未登录或登录已过期"}
+	SystemError  = Code{500001, http.StatusInternalServerError, "common.system_error", "系统错误"}
+	// ServiceBusy 系统降级了，扛不住更多的请求，前端可以稍后重试
+	ServiceBusy = Code{500002, http.StatusServiceUnavailable, "common.service_busy", "系统繁忙，请稍后再试"}
+
+	// 用户模块
+	// 401001 到 401006 是以前逐个字段校验时用的，现在参数校验统一返回 InvalidParam，具体的字段放在 Data 里
+	UserPasswordMismatch = Code{401001, http.StatusBadRequest, "user.password_mismatch", "两次输入密码不一致"}
+	UserInvalidEmail     = Code{401002, http.StatusBadRequest, "user.invalid_email", "非法邮箱格式"}
+	UserInvalidPassword  = Code{401003, http.StatusBadRequest, "user.invalid_password",
+		"密码必须包含至少8个字符，至少1个字母，1个数字和1个特殊字符"}
+	UserInvalidNickname = Code{401004, http.StatusBadRequest, "user.invalid_nickname", "昵称不合法"}
+	UserInvalidBirthday = Code{401005, http.StatusBadRequest, "user.invalid_birthday", "生日格式不合法"}
+	UserInvalidResume   = Code{401006, http.StatusBadRequest, "user.invalid_resume", "个人简介不合法"}
+	UserDuplicateEmail  = Code{401007, http.StatusConflict, "user.duplicate_email", "邮箱已被注册"}
+	// UserInvalidCredential 不能用 401，不然前端会直接跳回登录页
+	UserInvalidCredential = Code{401008, http.StatusBadRequest, "user.invalid_credential", "账号或密码错误"}
+	UserNotFound          = Code{401009, http.StatusNotFound, "user.not_found", "用户不存在"}
+	UserUnsupportedLocale = Code{401010, http.StatusBadRequest, "user.unsupported_locale", "不支持的语言"}
+	// UserDeactivated 前端收到这个要引导用户去恢复账号
+	UserDeactivated       = Code{401011, http.StatusForbidden, "user.deactivated", "账号已注销，可以在冷静期内恢复"}
+	UserReactivateExpired = Code{401012, http.StatusForbidden, "user.reactivate_expired", "账号注销已超过冷静期，不能恢复"}
+	// UserAvatarTooLarge 文件太大或者图片的宽高太大
+	UserAvatarTooLarge = Code{401013, http.StatusRequestEntityTooLarge, "user.avatar_too_large", "头像图片太大"}
+	UserAvatarInvalid  = Code{401014, http.StatusBadRequest, "user.avatar_invalid", "头像只支持 JPEG、PNG 和 GIF 格式的图片"}
+	// UserProfileConflict 前端收到这个要重新拉一次资料，让用户在最新的资料上再改
+	UserProfileConflict = Code{401015, http.StatusConflict, "user.profile_conflict", "资料已经在别处修改过了，请刷新后再改"}
+	UserDuplicateHandle = Code{401016, http.StatusConflict, "user.duplicate_handle", "用户名已被占用"}
+	UserHandleReserved  = Code{401017, http.StatusBadRequest, "user.handle_reserved", "这个用户名不能使用"}
+	// UserHandleChangeTooFrequent 用户名 30 天内只能改一次
+	UserHandleChangeTooFrequent = Code{401018, http.StatusTooManyRequests, "user.handle_change_too_frequent", "用户名修改太频繁，30 天内只能改一次"}
+
+	// 验证码模块
+	CodePhoneRequired = Code{402001, http.StatusBadRequest, "code.phone_required", "请输入手机号码"}
+	CodeSendTooMany   = Code{402002, http.StatusTooManyRequests, "code.send_too_many", "短信发送太频繁，稍后再试"}
+	CodeInvalid       = Code{402003, http.StatusBadRequest, "code.invalid", "验证码错误"}
+
+	// 微信登录模块
+	WechatInvalidState = Code{403001, http.StatusBadRequest, "wechat.invalid_state", "非法请求"}
+	WechatInvalidCode  = Code{403002, http.StatusBadRequest, "wechat.invalid_code", "授权码有误"}
+	WechatUnknownApp   = Code{403003, http.StatusBadRequest, "wechat.unknown_app", "不支持的微信应用"}
+
+	// 数据导出模块
+	ExportNotFound = Code{404001, http.StatusNotFound, "export.not_found", "导出不存在或已过期"}
+	// ExportInvalidLink 下载链接被改过或者过期了，前端重新查一次导出进度就能拿到新的链接
+	ExportInvalidLink = Code{404002, http.StatusForbidden, "export.invalid_link", "下载链接无效或已过期"}
+
+	// 文章模块
+	// ArticleNotFound 别人的文章也返回这个，不区分
+	ArticleNotFound = Code{405001, http.StatusNotFound, "article.not_found", "文章不存在"}
+)
@@ -0,0 +1,2 @@
+// Package errs web 层的业务错误码
+package errs
@@ -0,0 +1,45 @@
+package errs
+
+import (
+	"basic-go/week2/webook/internal/service"
+	"errors"
+)
+
+// 下层的哨兵错误和错误码的对应关系，service 新增了要让前端感知的错误就在这里加一行
+var errCodes = []struct {
+	err  error
+	code Code
+}{
+	{service.ErrDuplicateEmail, UserDuplicateEmail},
+	{service.ErrInvalidUserOrPassword, UserInvalidCredential},
+	{service.ErrUserNotFound, UserNotFound},
+	{service.ErrCodeSendTooMany, CodeSendTooMany},
+	{service.ErrSystemBusy, ServiceBusy},
+	{service.ErrUserDeactivated, UserDeactivated},
+	{service.ErrReactivateExpired, UserReactivateExpired},
+	{service.ErrAvatarTooLarge, UserAvatarTooLarge},
+	{service.ErrAvatarInvalid, UserAvatarInvalid},
+	{service.ErrUserProfileConflict, UserProfileConflict},
+	{service.ErrDuplicateHandle, UserDuplicateHandle},
+	{service.ErrHandleReserved, UserHandleReserved},
+	{service.ErrHandleChangeTooFrequent, UserHandleChangeTooFrequent},
+	{service.ErrUserExportNotFound, ExportNotFound},
+	{service.ErrArticleNotFound, ArticleNotFound},
+}
+
+// FromError 把 service 返回的 error 翻译成错误码，认不出来的都是系统错误
+func FromError(err error) Code {
+	if err == nil {
+		return OK
+	}
+	var code Code
+	if errors.As(err, &code) {
+		return code
+	}
+	for _, ec := range errCodes {
+		if errors.Is(err, ec.err) {
+			return ec.code
+		}
+	}
+	return SystemError
+}
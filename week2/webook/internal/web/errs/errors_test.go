@@ -0,0 +1,28 @@
+package errs
+
+import (
+	"basic-go/week2/webook/internal/service"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFromError(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{name: "没有错误", err: nil, want: OK},
+		{name: "邮箱冲突", err: service.ErrDuplicateEmail, want: UserDuplicateEmail},
+		{name: "包装过的错误", err: fmt.Errorf("登录失败: %w", service.ErrInvalidUserOrPassword), want: UserInvalidCredential},
+		{name: "直接返回错误码", err: fmt.Errorf("校验失败: %w", CodeInvalid), want: CodeInvalid},
+		{name: "不认识的错误", err: errors.New("db down"), want: SystemError},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, FromError(tc.err))
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/service"
+	"basic-go/week2/webook/internal/web/errs"
+	ijwt "basic-go/week2/webook/internal/web/jwt"
+	"basic-go/week2/webook/pkg/signurl"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const exportDownloadPath = "/users/exports/download"
+
+// UserExportHandler 用户导出自己的数据。先发起导出，轮询进度，打包好了拿到一个签过名的下载链接
+type UserExportHandler struct {
+	svc    service.UserExportService
+	signer *signurl.Signer
+	// 下载链接多久过期
+	linkExpiration time.Duration
+}
+
+func NewUserExportHandler(svc service.UserExportService, signer *signurl.Signer) *UserExportHandler {
+	return &UserExportHandler{
+		svc:            svc,
+		signer:         signer,
+		linkExpiration: time.Minute * 15,
+	}
+}
+
+func (h *UserExportHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/users/exports")
+	g.POST("", h.Request)
+	// 下载链接本身就是凭证，不用登录，所以要在登录校验里放行
+	g.GET("/download", h.Download)
+	g.GET("/:id", h.Find)
+}
+
+type exportVO struct {
+	Id     string `json:"id"`
+	Status string `json:"status"`
+	// DownloadURL 打包好了才有
+	DownloadURL string `json:"downloadUrl,omitempty"`
+}
+
+func (h *UserExportHandler) Request(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	t, err := h.svc.Request(ctx, uc.Uid)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeData(ctx, h.toVO(t))
+}
+
+func (h *UserExportHandler) Find(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	t, err := h.svc.Find(ctx, uc.Uid, ctx.Param("id"))
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeData(ctx, h.toVO(t))
+}
+
+func (h *UserExportHandler) Download(ctx *gin.Context) {
+	params := ctx.Request.URL.Query()
+	if err := h.signer.Verify(params, time.Now()); err != nil {
+		writeCode(ctx, errs.ExportInvalidLink)
+		return
+	}
+	id := params.Get("id")
+	data, err := h.svc.Archive(ctx, id)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="webook-export-%s.zip"`, id))
+	ctx.Data(http.StatusOK, "application/zip", data)
+}
+
+func (h *UserExportHandler) toVO(t domain.UserExport) exportVO {
+	res := exportVO{
+		Id: t.Id,
+	}
+	switch t.Status {
+	case domain.UserExportPending:
+		res.Status = "pending"
+	case domain.UserExportReady:
+		res.Status = "ready"
+		// 每次查都重新签一个，链接过期了前端再查一次就行
+		params := h.signer.Sign(url.Values{"id": {t.Id}}, time.Now().Add(h.linkExpiration))
+		res.DownloadURL = exportDownloadPath + "?" + params.Encode()
+	default:
+		res.Status = "failed"
+	}
+	return res
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"basic-go/week2/webook/internal/domain"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -17,6 +19,12 @@ import (
 //			rcExpire:   time.Hour * 24 * 7,
 //		}
 //	}
+const (
+	loginHistorySize = 100
+	// 太久以前的登录历史没什么用，也不应该一直留着
+	loginHistoryExpiration = time.Hour * 24 * 90
+)
+
 type RedisJWTHandler struct {
 	client        redis.Cmdable
 	signingMethod jwt.SigningMethod
@@ -44,13 +52,44 @@ func NewRedisJWTHandler(client redis.Cmdable) Handler {
 }
 func (c *RedisJWTHandler) SetLoginToken(ctx *gin.Context, uid int64) error {
 	ssid := uuid.New().String()
-	err := c.SetRefreshToken(ctx, uid, ssid)
+	// 记下这个用户有哪些 ssid，注销账号的时候要全部作废（见 cache.RedisSessionCache）
+	err := c.addSession(ctx, uid, ssid)
+	if err != nil {
+		return err
+	}
+	err = c.SetRefreshToken(ctx, uid, ssid)
 	if err != nil {
 		return err
 	}
 	return c.SetJWTToken(ctx, uid, ssid)
 }
 
+// addSession 记下这次登录：
+// 1. users:sessions:{uid} 是这个用户还没过期的登录，ssid -> domain.Session，注销账号的时候要全部作废（见 cache.RedisSessionCache）。
+// 过期时间每次登录都往后推，最后一个 refresh_token 过期之后它也就没了
+// 2. users:logins:{uid} 是登录历史，只保留最近的 loginHistorySize 次
+func (c *RedisJWTHandler) addSession(ctx *gin.Context, uid int64, ssid string) error {
+	val, err := json.Marshal(domain.Session{
+		Ssid:      ssid,
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.GetHeader("User-Agent"),
+		LoginAt:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	sessionsKey := fmt.Sprintf("users:sessions:%d", uid)
+	loginsKey := fmt.Sprintf("users:logins:%d", uid)
+	pipe := c.client.Pipeline()
+	pipe.HSet(ctx, sessionsKey, ssid, val)
+	pipe.Expire(ctx, sessionsKey, c.rcExpiration)
+	pipe.LPush(ctx, loginsKey, val)
+	pipe.LTrim(ctx, loginsKey, 0, loginHistorySize-1)
+	pipe.Expire(ctx, loginsKey, loginHistoryExpiration)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
 func (c *RedisJWTHandler) ClearToken(ctx *gin.Context) error {
 	ctx.Header("x-jwt-token", "")
 	ctx.Header("x-refresh-token", "")
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"basic-go/week2/webook/internal/service"
+	ijwt "basic-go/week2/webook/internal/web/jwt"
+	"basic-go/week2/webook/pkg/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// I18nMiddlewareBuilder 确定这次请求用哪种语言，把 Localizer 放进 gin.Context
+// 优先用登录用户自己设置的语言，其次是 Accept-Language，都没有就用默认语言
+type I18nMiddlewareBuilder struct {
+	catalog *i18n.Catalog
+	userSvc service.UserService
+}
+
+func NewI18nMiddlewareBuilder(catalog *i18n.Catalog, userSvc service.UserService) *I18nMiddlewareBuilder {
+	return &I18nMiddlewareBuilder{
+		catalog: catalog,
+		userSvc: userSvc,
+	}
+}
+
+// Build 要放在登录校验的后面，才能拿到 uid
+func (m *I18nMiddlewareBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		locale := m.userLocale(ctx)
+		if locale == "" {
+			locale = m.catalog.Match(ctx.GetHeader("Accept-Language"))
+		}
+		l := m.catalog.Localizer(locale)
+		ctx.Set(i18n.ContextKey, l)
+		ctx.Header("Content-Language", l.Locale())
+	}
+}
+
+// userLocale 登录用户设置过的语言，没登录、没设置过或者查不到都返回空
+func (m *I18nMiddlewareBuilder) userLocale(ctx *gin.Context) string {
+	val, ok := ctx.Get("user")
+	if !ok {
+		return ""
+	}
+	uc, ok := val.(ijwt.UserClaims)
+	if !ok {
+		return ""
+	}
+	// FindById 会先查缓存，不会每个请求都打到数据库
+	u, err := m.userSvc.FindById(ctx, uc.Uid)
+	if err != nil || !m.catalog.Supports(u.Locale) {
+		return ""
+	}
+	return u.Locale
+}
@@ -25,8 +25,13 @@ func (m *LoginJWTMiddlewareBuilder) CheckLoginJWT() gin.HandlerFunc {
 			path == "/users/login" ||
 			path == "/users/login_sms/code/send" ||
 			path == "/users/login_sms" ||
+			path == "/users/reactivate" ||
+			path == "/users/reactivate_sms" ||
+			path == "/users/exports/download" ||
 			path == "/oauth2/wechat/authurl" ||
-			path == "/oauth2/wechat/callback" {
+			path == "/oauth2/wechat/callback" ||
+			// 公开主页，路径里有用户名，只能按路由判断
+			ctx.Request.Method == http.MethodGet && ctx.FullPath() == "/users/:handle" {
 			// 不需要校验是否登录
 			return
 		}
@@ -1,7 +1,65 @@
 package web
 
+import (
+	"basic-go/week2/webook/internal/web/errs"
+	"basic-go/week2/webook/internal/web/validation"
+	"basic-go/week2/webook/pkg/i18n"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+)
+
 type Result struct {
 	Code int    `json:"code"`
 	Data any    `json:"data"`
 	Msg  string `json:"msg"`
 }
+
+// note 所有 handler 的响应都通过下面这几个方法输出，保证前端拿到的永远是 Result
+// note Msg 都是按消息目录的 key 翻译的，用哪种语言由 i18n 中间件决定，消息文件在 config/i18n 下面
+
+// writeOK 成功，key 是给用户看的提示在消息目录里的 key，比如 user.signup_ok
+func writeOK(ctx *gin.Context, key string) {
+	ctx.JSON(http.StatusOK, Result{
+		Code: errs.OK.Code,
+		Msg:  i18n.T(ctx, key, key, nil),
+	})
+}
+
+// writeData 成功，并且带上数据
+func writeData(ctx *gin.Context, data any) {
+	ctx.JSON(http.StatusOK, Result{
+		Code: errs.OK.Code,
+		Msg:  i18n.T(ctx, errs.OK.MsgKey, errs.OK.Msg, nil),
+		Data: data,
+	})
+}
+
+// writeCode 按错误码输出，HTTP 状态码跟着错误码走，消息目录里没有的就用 code.Msg
+func writeCode(ctx *gin.Context, code errs.Code) {
+	ctx.JSON(code.HTTPStatus, Result{
+		Code: code.Code,
+		Msg:  i18n.T(ctx, code.MsgKey, code.Msg, nil),
+	})
+}
+
+// writeFieldErrors 参数校验没通过，把所有不合法的字段放在 Data 里，每个字段的提示按 validation.{规则} 翻译
+func writeFieldErrors(ctx *gin.Context, fes []validation.FieldError) {
+	for i, fe := range fes {
+		fes[i].Msg = i18n.T(ctx, "validation."+fe.Rule, fe.Msg, map[string]string{"param": fe.Param})
+	}
+	ctx.JSON(errs.InvalidParam.HTTPStatus, Result{
+		Code: errs.InvalidParam.Code,
+		Msg:  i18n.T(ctx, errs.InvalidParam.MsgKey, errs.InvalidParam.Msg, nil),
+		Data: fes,
+	})
+}
+
+// writeErr 把下层返回的 error 翻译成错误码再输出，系统错误要打日志，不能把 err 的内容暴露给前端
+func writeErr(ctx *gin.Context, err error) {
+	code := errs.FromError(err)
+	if code == errs.SystemError {
+		log.Println(ctx.Request.Method, ctx.FullPath(), err)
+	}
+	writeCode(ctx, code)
+}
@@ -3,52 +3,41 @@ package web
 import (
 	"basic-go/week2/webook/internal/domain"
 	"basic-go/week2/webook/internal/service"
+	"basic-go/week2/webook/internal/web/errs"
 	ijwt "basic-go/week2/webook/internal/web/jwt"
-	regexp "github.com/dlclark/regexp2"
+	"basic-go/week2/webook/internal/web/validation"
+	"basic-go/week2/webook/pkg/i18n"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"net/http"
+	"strconv"
 	"time"
 )
 
-const (
-	emailRegexPattern    = `^\w+(-+.\w+)*@\w+(-.\w+)*.\w+(-.\w+)*$`
-	passwordRegexPattern = `^(?=.*[A-Za-z])(?=.*\d)(?=.*[$@$!%*#?&])[A-Za-z\d$@$!%*#?&]{8,}$`
-	// 要求昵称长度在1到20个字符之间，禁止昵称为纯数字，禁止昵称为纯特殊符号或下划线
-	nicknameRegexPattern = `^(?=.{1,20}$)(?!^[0-9]*$)(?!^[\\W_]*$)[a-zA-Z0-9\u4e00-\u9fa5\\._-]+$`
-	birthdayRegexPattern = `^(19|20)\d\d-(0[1-9]|1[0-2])-(0[1-9]|[12][0-9]|3[01])$`
-	resumeRegexPattern   = `^.{1,200}$`
-
-	bizLogin = "login"
-)
+const bizLogin = "login"
 
 // UserHandler 定义一个专门处理有关User的路由的Handler
 type UserHandler struct {
-	svc     service.UserService
-	codeSvc service.CodeService
+	svc      service.UserService
+	codeSvc  service.CodeService
+	auditSvc service.AuthAuditService
 	ijwt.Handler
 
-	emailRegexExp    *regexp.Regexp
-	passwordRegexExp *regexp.Regexp
-	nicknameRegexExp *regexp.Regexp
-	birthdayRegexExp *regexp.Regexp
-	resumeRegexExp   *regexp.Regexp
+	// 参数校验，规则写在各个 Req 的 validate tag 上
+	validator *validation.Validator
+	// 用来判断用户选的语言是否支持
+	catalog *i18n.Catalog
 }
 
-func NewUserHandler(svc service.UserService, codeSvc service.CodeService,
-	hdl ijwt.Handler) *UserHandler {
+func NewUserHandler(svc service.UserService, codeSvc service.CodeService, auditSvc service.AuthAuditService,
+	hdl ijwt.Handler, catalog *i18n.Catalog) *UserHandler {
 	return &UserHandler{
-		svc:     svc,
-		codeSvc: codeSvc,
-		Handler: hdl,
-
-		// 预编译正则表达式提升性能
-		emailRegexExp:    regexp.MustCompile(emailRegexPattern, regexp.None),
-		passwordRegexExp: regexp.MustCompile(passwordRegexPattern, regexp.None),
-		nicknameRegexExp: regexp.MustCompile(nicknameRegexPattern, regexp.None),
-		birthdayRegexExp: regexp.MustCompile(birthdayRegexPattern, regexp.None),
-		resumeRegexExp:   regexp.MustCompile(resumeRegexPattern, regexp.None),
+		svc:       svc,
+		codeSvc:   codeSvc,
+		auditSvc:  auditSvc,
+		Handler:   hdl,
+		validator: validation.NewValidator(),
+		catalog:   catalog,
 	}
 }
 
@@ -62,99 +51,94 @@ func (h *UserHandler) RegisterRoutes(server *gin.Engine) {
 	ug.POST("/logout", h.LogoutJWT)
 	ug.POST("/edit", h.Edit)
 	ug.GET("/profile", h.Profile)
+	ug.PATCH("/profile", h.UpdateProfile)
+	ug.POST("/settings/locale", h.SetLocale)
+	ug.POST("/settings/handle", h.SetHandle)
+	ug.POST("/settings/privacy", h.SetPrivacy)
 	ug.GET("/refresh_token", h.RefreshToken)
 	ug.POST("/login_sms/code/send", h.SendSMSLog)
 	ug.POST("/login_sms", h.LoginSMS)
+	ug.POST("/deactivate", h.Deactivate)
+	ug.POST("/reactivate", h.Reactivate)
+	ug.POST("/reactivate_sms", h.ReactivateSMS)
+	ug.GET("/security/events", h.SecurityEvents)
+	// note 静态路由优先，/users 下面新加 GET 路由要同时加进 service 里的保留用户名
+	ug.GET("/:handle", h.PublicProfile)
 }
 
 func (h *UserHandler) SendSMSLog(ctx *gin.Context) {
 	type Req struct {
-		Phone string `json:"phone"`
+		Phone string `json:"phone" validate:"required,phone"`
 	}
 	var req Req
 	if err := ctx.Bind(&req); err != nil {
 		return
 	}
 	// 校验req
-	if req.Phone == "" {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 4,
-			Msg:  "请输入手机号码",
-		})
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
 		return
 	}
 
 	// 调用service层的发送验证码
 	err := h.codeSvc.Send(ctx, bizLogin, req.Phone)
-	switch err {
-	case nil:
-		ctx.JSON(http.StatusOK, Result{
-			Msg: "发送成功",
-		})
-	case service.ErrCodeSendTooMany:
-		ctx.JSON(http.StatusOK, Result{
-			Code: 4,
-			Msg:  "短信发送太频繁，稍后再试",
-		})
-	default:
-		ctx.JSON(http.StatusOK, Result{
-			Code: 5,
-			Msg:  "发送失败",
-		})
+	if err != nil {
+		// 发送太频繁会被翻译成对应的错误码，其它的都是系统错误
+		writeErr(ctx, err)
+		return
 	}
+	writeOK(ctx, "code.send_ok")
 }
 func (h *UserHandler) LoginSMS(ctx *gin.Context) {
 	type Req struct {
-		Phone string `json:"phone"`
-		Code  string `json:"code"`
+		Phone string `json:"phone" validate:"required,phone"`
+		Code  string `json:"code" validate:"required,len=6"`
 	}
 	var req Req
 	if err := ctx.Bind(&req); err != nil {
 		return
 	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
 	ok, err := h.codeSvc.Verify(ctx, bizLogin, req.Phone, req.Code)
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 5,
-			Msg:  "系统错误",
-		})
+		// 验证太多次了也要记，多半是有人在试验证码
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodSMS, 0, errs.FromError(err))
+		writeErr(ctx, err)
 		return
 	}
 	if !ok {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 4,
-			Msg:  "验证码错误",
-		})
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodSMS, 0, errs.CodeInvalid)
+		writeCode(ctx, errs.CodeInvalid)
 		return
 	}
 	// 验证码正确，调用service层进行登录
 	// 因为用户可能未用手机号注册，所以需要调用FindOrCreate方法
 	u, err := h.svc.FindOrCreate(ctx, req.Phone)
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 5,
-			Msg:  "系统错误",
-		})
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodSMS, u.Id, errs.FromError(err))
+		writeErr(ctx, err)
 		return
 	}
 	// 登录成功后先设置refresh-token
 	err = h.SetLoginToken(ctx, u.Id)
+	recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodSMS, u.Id, errs.FromError(err))
 	if err != nil {
-		ctx.String(http.StatusOK, "系统错误（JWT的refresh-token）")
+		writeErr(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, Result{
-		Msg: "登录成功",
-	})
-
+	writeOK(ctx, "user.login_ok")
 }
 
 func (h *UserHandler) SignUp(ctx *gin.Context) {
 	// note 习惯：使用 方法内部类 接收body的参数
+	// note 校验规则直接写在 validate tag 上，不用一个字段一个字段地写 MatchString
 	type Req struct {
-		Email           string `json:"email"`
-		Password        string `json:"password"`
-		ConfirmPassword string `json:"confirmPassword"`
+		Email           string `json:"email" validate:"required,email"`
+		Password        string `json:"password" validate:"required,password"`
+		ConfirmPassword string `json:"confirmPassword" validate:"required,eqfield=Password"`
 	}
 
 	var req Req
@@ -164,45 +148,25 @@ func (h *UserHandler) SignUp(ctx *gin.Context) {
 		return
 	}
 
-	// 校验
-	if req.ConfirmPassword != req.Password {
-		ctx.String(http.StatusOK, "两次输入密码错误")
-		return
-	}
-	isEmail, err := h.emailRegexExp.MatchString(req.Email)
-	if err != nil {
-		ctx.String(http.StatusOK, "系统错误（正则Pattern错误）")
-		return
-	}
-	if !isEmail {
-		ctx.String(http.StatusOK, "非法邮箱格式")
-		return
-	}
-	isPassword, err := h.passwordRegexExp.MatchString(req.Password)
-	if err != nil {
-		ctx.String(http.StatusOK, "系统错误（正则Pattern错误）")
-		return
-	}
-	if !isPassword {
-		ctx.String(http.StatusOK, "密码必须包含至少8个字符，至少1个大写字母，1个小写字母，1个数字和1个特殊字符")
+	// 校验，所有不合法的字段一次性返回
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
 		return
 	}
 
 	// 调用 service层 【需要传入的对象是领域对象，而不是req】
-	err = h.svc.SignUp(ctx, domain.User{
+	err := h.svc.SignUp(ctx, domain.User{
 		Email:    req.Email,
 		Password: req.Password,
 	})
 	// note 处理邮箱相同的冲突err，即需要拿到 mysql 的唯一索引冲突
 	// note 不能直接 if err==dao.ErrDuplicateEmail，因为web层里不能直接调dao层的东西，所以得一层层传，使得Handler只保持对service的依赖，避免跨层依赖
-	switch err {
-	case nil:
-		ctx.String(http.StatusOK, "注册成功")
-	case service.ErrDuplicateEmail:
-		ctx.String(http.StatusOK, "邮箱已被注册")
-	default:
-		ctx.String(http.StatusOK, "系统错误（web层的SignUp）")
+	// note service.ErrDuplicateEmail 到错误码的对应关系在 errs 包里
+	if err != nil {
+		writeErr(ctx, err)
+		return
 	}
+	writeOK(ctx, "user.signup_ok")
 }
 
 func (h *UserHandler) Login(ctx *gin.Context) {
@@ -217,27 +181,24 @@ func (h *UserHandler) Login(ctx *gin.Context) {
 	}
 
 	u, err := h.svc.Login(ctx, req.Email, req.Password)
-	switch err {
-	case nil:
-		// 登录成功后获取session，存入域对象u的id，便于profile和edit方法获取
-		sess := sessions.Default(ctx)
-		sess.Set("userId", u.Id)
-		sess.Options(sessions.Options{
-			// 15min
-			MaxAge: 900,
-		})
-		err := sess.Save()
-		if err != nil {
-			ctx.String(http.StatusOK, "系统错误（保存登录状态的session）")
-		}
-
-		ctx.String(http.StatusOK, "登录成功")
-	case service.ErrInvalidUserOrPassword:
-		ctx.String(http.StatusOK, "账号或密码错误")
+	if err != nil {
+		// 账号或密码错误会被翻译成对应的错误码
+		writeErr(ctx, err)
 		return
-	default:
-		ctx.String(http.StatusOK, "系统错误（web层的Login）")
 	}
+	// 登录成功后获取session，存入域对象u的id，便于profile和edit方法获取
+	sess := sessions.Default(ctx)
+	sess.Set("userId", u.Id)
+	sess.Options(sessions.Options{
+		// 15min
+		MaxAge: 900,
+	})
+	err = sess.Save()
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeOK(ctx, "user.login_ok")
 }
 
 func (h *UserHandler) LoginJWT(ctx *gin.Context) {
@@ -252,94 +213,61 @@ func (h *UserHandler) LoginJWT(ctx *gin.Context) {
 	}
 
 	u, err := h.svc.Login(ctx, req.Email, req.Password)
-	switch err {
-	case nil:
-		// 登录成功后先设置refresh-token
-		err = h.SetLoginToken(ctx, u.Id)
-		if err != nil {
-			ctx.String(http.StatusOK, "系统错误（JWT的refresh-token）")
-			return
-		}
-		ctx.String(http.StatusOK, "登录成功")
-	case service.ErrInvalidUserOrPassword:
-		ctx.String(http.StatusOK, "账号或密码错误")
+	if err != nil {
+		// 密码不对的时候 u 里也有 Id，这样用户能看到别人在试他的密码
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodPassword, u.Id, errs.FromError(err))
+		writeErr(ctx, err)
 		return
-	default:
-		ctx.String(http.StatusOK, "系统错误（web层的Login）")
 	}
+	// 登录成功后先设置refresh-token
+	err = h.SetLoginToken(ctx, u.Id)
+	recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodPassword, u.Id, errs.FromError(err))
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeOK(ctx, "user.login_ok")
 }
 
 func (h *UserHandler) Edit(ctx *gin.Context) {
 	type Req struct {
-		Nickname string `json:"nickname"`
-		Birthday string `json:"birthday"`
-		Resume   string `json:"resume"`
+		Nickname string `json:"nickname" validate:"required,nickname"`
+		Birthday string `json:"birthday" validate:"required,birthday"`
+		Resume   string `json:"resume" validate:"required,max=200"`
 	}
 	var req Req
 	if err := ctx.Bind(&req); err != nil {
 		return
 	}
 
-	// 对昵称、生日和个人简介进行正则规范
-	isNickname, err := h.nicknameRegexExp.MatchString(req.Nickname)
-	if err != nil {
-		ctx.String(http.StatusOK, "系统错误（nickname的regex错误）")
-		return
-	}
-	if !isNickname {
-		ctx.String(http.StatusOK, "昵称不合法")
-		return
-	}
-	isBirthday, err := h.birthdayRegexExp.MatchString(req.Birthday)
-	if err != nil {
-		ctx.String(http.StatusOK, "系统错误（birthday的regex错误）")
-		return
-	}
-	if !isBirthday {
-		ctx.String(http.StatusOK, "生日格式不合法")
-		return
-	}
-	isResume, err := h.resumeRegexExp.MatchString(req.Resume)
-	if err != nil {
-		ctx.String(http.StatusOK, "系统错误（resume的regex错误）")
-		return
-	}
-	if !isResume {
-		ctx.String(http.StatusOK, "个人简介不合法")
+	// 对昵称、生日和个人简介进行校验
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
 		return
 	}
 
-	// 从session中取出userId【因为sess.get返回的interface类型，所以用类型断言认定为int64类型的值】
-	// TODO 可以用 jwt？
-	sess := sessions.Default(ctx)
-	var userId int64
-	if val, ok := sess.Get("userId").(int64); ok {
-		userId = val
-	} else {
-		panic("userId的session的值不是int64")
-	}
+	// 从uc中取出userId（登录用的是jwt，没有session）
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
 
-	// 除了用regex校验生日，还可以调用time.Parse方法【但返回的是time类型】
+	// birthday 规则已经校验过格式了，这里只是转成time类型
 	birthday, err := time.Parse(time.DateOnly, req.Birthday)
 	if err != nil {
-		ctx.String(http.StatusOK, "生日格式不合法")
+		writeCode(ctx, errs.UserInvalidBirthday)
 		return
 	}
 
 	// 在web层调用service()，要用domain往下传
 	err = h.svc.UpdateNonSensitiveInfo(ctx, domain.User{
-		Id:       userId,
+		Id:       uc.Uid,
 		Nickname: req.Nickname,
 		Birthday: birthday,
 		Resume:   req.Resume,
 	})
 	if err != nil {
-		ctx.String(http.StatusOK, "系统错误（web层的edit）")
+		writeErr(ctx, err)
 		return
 	}
-
-	ctx.String(http.StatusOK, "更新成功（Edit）")
-
+	writeOK(ctx, "user.edit_ok")
 }
 
 func (h *UserHandler) Profile(ctx *gin.Context) {
@@ -356,9 +284,9 @@ func (h *UserHandler) Profile(ctx *gin.Context) {
 	// 方式二：从uc中取
 	uc := ctx.MustGet("user").(ijwt.UserClaims)
 	u, err := h.svc.FindById(ctx, uc.Uid)
-
 	if err != nil {
-		ctx.String(http.StatusOK, "系统错误（web层的profile）")
+		writeErr(ctx, err)
+		return
 	}
 
 	// 不能将domain.user直接传给前端，从中挑出nickname、Email、birthday和resume
@@ -367,15 +295,225 @@ func (h *UserHandler) Profile(ctx *gin.Context) {
 		Email    string `json:"email"`
 		Birthday string `json:"birthday"`
 		Resume   string `json:"resume"`
-	}
+		Avatar   string `json:"avatar"`
+		Gender   string `json:"gender"`
+		Location string `json:"location"`
+		Website  string `json:"website"`
+		Locale   string `json:"locale"`
+		Handle   string `json:"handle"`
+		// 隐私设置，只影响别人看到的公开主页
+		HideBirthday bool `json:"hideBirthday"`
+		HideResume   bool `json:"hideResume"`
+		// Utime 资料的更新时间（毫秒），PATCH /users/profile 的时候原样带回来
+		Utime int64 `json:"utime"`
+	}
+
+	res := User{
+		Nickname:     u.Nickname,
+		Email:        u.Email,
+		Resume:       u.Resume,
+		Avatar:       u.Avatar,
+		Gender:       genderNames[u.Gender],
+		Location:     u.Location,
+		Website:      u.Website,
+		Locale:       u.Locale,
+		Handle:       u.Handle,
+		HideBirthday: u.Privacy.HideBirthday,
+		HideResume:   u.Privacy.HideResume,
+		Utime:        u.Utime.UnixMilli(),
+	}
+	// 没填生日存的是 0，不要返回 1970-01-01
+	if u.Birthday.UnixMilli() != 0 {
+		res.Birthday = u.Birthday.Format(time.DateOnly)
+	}
+	writeData(ctx, res)
+}
 
-	ctx.JSON(http.StatusOK, User{
+// PublicProfile 别人的公开主页，不用登录。只返回公开的字段，邮箱、手机号这些永远不返回，
+// 生日和个人简介按用户的隐私设置决定给不给
+func (h *UserHandler) PublicProfile(ctx *gin.Context) {
+	u, err := h.svc.PublicProfile(ctx, ctx.Param("handle"))
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	type User struct {
+		Handle   string `json:"handle"`
+		Nickname string `json:"nickname"`
+		Avatar   string `json:"avatar"`
+		Birthday string `json:"birthday,omitempty"`
+		Resume   string `json:"resume,omitempty"`
+		Gender   string `json:"gender"`
+		Location string `json:"location"`
+		Website  string `json:"website"`
+		// JoinedAt 注册时间（毫秒）
+		JoinedAt int64 `json:"joinedAt"`
+	}
+	res := User{
+		Handle:   u.Handle,
 		Nickname: u.Nickname,
-		Email:    u.Email,
-		Birthday: u.Birthday.Format(time.DateOnly),
+		Avatar:   u.Avatar,
 		Resume:   u.Resume,
-	})
+		Gender:   genderNames[u.Gender],
+		Location: u.Location,
+		Website:  u.Website,
+		JoinedAt: u.Ctime.UnixMilli(),
+	}
+	if u.Birthday.UnixMilli() != 0 {
+		res.Birthday = u.Birthday.Format(time.DateOnly)
+	}
+	writeData(ctx, res)
+}
 
+// 前端看到的性别
+var genderNames = map[domain.Gender]string{
+	domain.GenderUnknown: "unknown",
+	domain.GenderMale:    "male",
+	domain.GenderFemale:  "female",
+	domain.GenderOther:   "other",
+}
+
+// UpdateProfile 部分更新资料，只改请求里带了的字段，带了空字符串就是清空。
+// 必须带上 Profile 返回的 utime，资料在这之后被改过就返回 errs.UserProfileConflict，不会把别人的修改覆盖掉
+func (h *UserHandler) UpdateProfile(ctx *gin.Context) {
+	type Req struct {
+		Nickname *string `json:"nickname" validate:"omitempty,nickname"`
+		Birthday *string `json:"birthday" validate:"omitempty,birthday"`
+		Resume   *string `json:"resume" validate:"omitempty,max=200"`
+		Gender   *string `json:"gender" validate:"omitempty,oneof=unknown male female other"`
+		Location *string `json:"location" validate:"omitempty,max=64"`
+		Website  *string `json:"website" validate:"omitempty,max=256,http_url"`
+		Avatar   *string `json:"avatar" validate:"omitempty,max=1024,http_url"`
+		Utime    int64   `json:"utime" validate:"required"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	// 指针字段的 omitempty 只跳过 nil，空字符串（清空）还是会被校验，所以校验的时候把空字符串也当成没带
+	checked := req
+	for _, f := range []**string{&checked.Nickname, &checked.Birthday, &checked.Resume, &checked.Gender,
+		&checked.Location, &checked.Website, &checked.Avatar} {
+		if *f != nil && **f == "" {
+			*f = nil
+		}
+	}
+	if fes := h.validator.Struct(checked); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	patch := domain.UserProfilePatch{
+		Nickname: req.Nickname,
+		Resume:   req.Resume,
+		Location: req.Location,
+		Website:  req.Website,
+		Avatar:   req.Avatar,
+		Utime:    time.UnixMilli(req.Utime),
+	}
+	if req.Birthday != nil {
+		var birthday time.Time
+		if *req.Birthday != "" {
+			// birthday 规则已经校验过格式了
+			birthday, _ = time.Parse(time.DateOnly, *req.Birthday)
+		}
+		patch.Birthday = &birthday
+	}
+	if req.Gender != nil {
+		gender := domain.GenderUnknown
+		for g, name := range genderNames {
+			if name == *req.Gender {
+				gender = g
+			}
+		}
+		patch.Gender = &gender
+	}
+	if patch.IsEmpty() {
+		writeCode(ctx, errs.InvalidParam)
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	utime, err := h.svc.UpdateProfile(ctx, uc.Uid, patch)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	type Resp struct {
+		Utime int64 `json:"utime"`
+	}
+	writeData(ctx, Resp{Utime: utime.UnixMilli()})
+}
+
+// SetLocale 设置界面语言，之后这个用户的提示都用这个语言，不管 Accept-Language 是什么
+func (h *UserHandler) SetLocale(ctx *gin.Context) {
+	type Req struct {
+		Locale string `json:"locale" validate:"required"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	if !h.catalog.Supports(req.Locale) {
+		writeCode(ctx, errs.UserUnsupportedLocale)
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.UpdateLocale(ctx, uc.Uid, req.Locale)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	// 这次响应就用新的语言
+	ctx.Set(i18n.ContextKey, h.catalog.Localizer(req.Locale))
+	ctx.Header("Content-Language", req.Locale)
+	writeOK(ctx, "user.locale_ok")
+}
+
+// SetHandle 设置或者修改用户名，30 天内只能改一次
+func (h *UserHandler) SetHandle(ctx *gin.Context) {
+	type Req struct {
+		Handle string `json:"handle" validate:"required,handle"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.UpdateHandle(ctx, uc.Uid, req.Handle)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeOK(ctx, "user.handle_ok")
+}
+
+// SetPrivacy 隐私设置，每次都要带上所有的设置项
+func (h *UserHandler) SetPrivacy(ctx *gin.Context) {
+	type Req struct {
+		HideBirthday bool `json:"hideBirthday"`
+		HideResume   bool `json:"hideResume"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.UpdatePrivacy(ctx, uc.Uid, domain.UserPrivacy{
+		HideBirthday: req.HideBirthday,
+		HideResume:   req.HideResume,
+	})
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeOK(ctx, "user.privacy_ok")
 }
 
 func (h *UserHandler) RefreshToken(ctx *gin.Context) {
@@ -385,12 +523,10 @@ func (h *UserHandler) RefreshToken(ctx *gin.Context) {
 	token, err := jwt.ParseWithClaims(tokenStr, &rc, func(token *jwt.Token) (interface{}, error) {
 		return ijwt.RefreshKey, nil
 	})
-	if err != nil {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
-		return
-	}
-	if token == nil || !token.Valid {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+	if err != nil || token == nil || !token.Valid {
+		// token 不对就不知道是谁，也不能相信里面的 uid
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodRefresh, 0, errs.Unauthorized)
+		writeCode(ctx, errs.Unauthorized)
 		return
 	}
 
@@ -398,31 +534,152 @@ func (h *UserHandler) RefreshToken(ctx *gin.Context) {
 	err = h.CheckSession(ctx, rc.Ssid)
 	if err != nil {
 		// redis有问题或者ssid存在（表明用户已退出） ==> redis有问题或者 token无效
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodRefresh, rc.Uid, errs.Unauthorized)
+		writeCode(ctx, errs.Unauthorized)
 		return
 	}
 
 	err = h.SetJWTToken(ctx, rc.Uid, rc.Ssid)
+	recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodRefresh, rc.Uid, errs.FromError(err))
 	if err != nil {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		writeErr(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, Result{
-		Msg: "OK",
-	})
-
+	writeOK(ctx, "common.ok")
 }
 
 func (h *UserHandler) LogoutJWT(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
 	err := h.ClearToken(ctx)
+	recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodLogout, uc.Uid, errs.FromError(err))
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Msg:  "系统错误",
-			Code: 5,
-		})
+		writeErr(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, Result{
-		Msg: "退出成功",
-	})
+	writeOK(ctx, "user.logout_ok")
+}
+
+// Deactivate 注销账号，冷静期内可以用 Reactivate 或者 ReactivateSMS 恢复
+func (h *UserHandler) Deactivate(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.Deactivate(ctx, uc.Uid)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	// 所有的 ssid 在 service 里已经作废了，这里只是让前端把 token 清掉
+	ctx.Header("x-jwt-token", "")
+	ctx.Header("x-refresh-token", "")
+	writeOK(ctx, "user.deactivate_ok")
+}
+
+// Reactivate 登录的时候收到 errs.UserDeactivated，前端带着同样的邮箱和密码来恢复，恢复成功就是登录成功
+func (h *UserHandler) Reactivate(ctx *gin.Context) {
+	type Req struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	// 恢复成功就是登录了，和 LoginJWT 一样记审计日志，密码不对的也要记
+	u, err := h.svc.ReactivateByEmail(ctx, req.Email, req.Password)
+	if err != nil {
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodPassword, u.Id, errs.FromError(err))
+		writeErr(ctx, err)
+		return
+	}
+	err = h.SetLoginToken(ctx, u.Id)
+	recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodPassword, u.Id, errs.FromError(err))
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeOK(ctx, "user.reactivate_ok")
+}
+
+// ReactivateSMS 手机号登录的时候收到 errs.UserDeactivated，重新发一次验证码来恢复
+func (h *UserHandler) ReactivateSMS(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone" validate:"required,phone"`
+		Code  string `json:"code" validate:"required,len=6"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if fes := h.validator.Struct(req); fes != nil {
+		writeFieldErrors(ctx, fes)
+		return
+	}
+	ok, err := h.codeSvc.Verify(ctx, bizLogin, req.Phone, req.Code)
+	if err != nil {
+		// 验证太多次了也要记，多半是有人在试验证码
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodSMS, 0, errs.FromError(err))
+		writeErr(ctx, err)
+		return
+	}
+	if !ok {
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodSMS, 0, errs.CodeInvalid)
+		writeCode(ctx, errs.CodeInvalid)
+		return
+	}
+	u, err := h.svc.ReactivateByPhone(ctx, req.Phone)
+	if err != nil {
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodSMS, u.Id, errs.FromError(err))
+		writeErr(ctx, err)
+		return
+	}
+	err = h.SetLoginToken(ctx, u.Id)
+	recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodSMS, u.Id, errs.FromError(err))
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	writeOK(ctx, "user.reactivate_ok")
+}
+
+// SecurityEvents 最近的登录、刷新 token 和退出登录记录，用户用来检查账号有没有被别人登录
+func (h *UserHandler) SecurityEvents(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	events, err := h.auditSvc.Recent(ctx, uc.Uid, limit)
+	if err != nil {
+		writeErr(ctx, err)
+		return
+	}
+	type Event struct {
+		Method    string `json:"method"`
+		IP        string `json:"ip"`
+		UserAgent string `json:"userAgent"`
+		Success   bool   `json:"success"`
+		// Reason 失败原因，翻译成了用户的语言
+		Reason    string    `json:"reason,omitempty"`
+		NewDevice bool      `json:"newDevice"`
+		Time      time.Time `json:"time"`
+	}
+	res := make([]Event, 0, len(events))
+	for _, e := range events {
+		ev := Event{
+			Method:    string(e.Method),
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Success:   e.Success,
+			NewDevice: e.NewDevice,
+			Time:      e.Ctime,
+		}
+		if e.Reason != "" {
+			ev.Reason = i18n.T(ctx, e.Reason, e.Reason, nil)
+		}
+		res = append(res, ev)
+	}
+	writeData(ctx, res)
 }
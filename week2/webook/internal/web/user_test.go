@@ -1,6 +1,24 @@
 package web
 
-import "testing"
+import (
+	"basic-go/week2/webook/internal/domain"
+	"basic-go/week2/webook/internal/service"
+	svcmocks "basic-go/week2/webook/internal/service/mocks"
+	"basic-go/week2/webook/internal/web/errs"
+	ijwt "basic-go/week2/webook/internal/web/jwt"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
 
 func TestUserEmailPattern(t *testing.T) {
 	t.Log("hello 测试")
@@ -9,3 +27,293 @@ func TestUserEmailPattern(t *testing.T) {
 func TestUserHandler_SignUp(t *testing.T) {
 
 }
+
+func TestUserHandler_UpdateProfile(t *testing.T) {
+	utime := time.UnixMilli(1700000000000)
+	strPtr := func(s string) *string { return &s }
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) service.UserService
+		body     string
+		wantCode int
+		wantBody Result
+	}{
+		{
+			name: "只改一个字段",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				svc := svcmocks.NewMockUserService(ctrl)
+				svc.EXPECT().UpdateProfile(gomock.Any(), int64(1), domain.UserProfilePatch{
+					Location: strPtr("深圳"),
+					Utime:    utime,
+				}).Return(utime.Add(time.Second), nil)
+				return svc
+			},
+			body:     `{"location":"深圳","utime":1700000000000}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Msg: "OK", Data: map[string]any{"utime": float64(1700000001000)}},
+		},
+		{
+			name: "空字符串是清空，不校验格式",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				svc := svcmocks.NewMockUserService(ctrl)
+				birthday, gender := time.Time{}, domain.GenderUnknown
+				svc.EXPECT().UpdateProfile(gomock.Any(), int64(1), domain.UserProfilePatch{
+					Birthday: &birthday,
+					Gender:   &gender,
+					Website:  strPtr(""),
+					Utime:    utime,
+				}).Return(utime.Add(time.Second), nil)
+				return svc
+			},
+			body:     `{"birthday":"","gender":"","website":"","utime":1700000000000}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Msg: "OK", Data: map[string]any{"utime": float64(1700000001000)}},
+		},
+		{
+			name: "格式不对",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			body:     `{"website":"ftp://a.com","utime":1700000000000}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: Result{Code: errs.InvalidParam.Code, Msg: errs.InvalidParam.Msg, Data: []any{
+				map[string]any{"field": "website", "rule": "http_url", "msg": "必须是 http 或 https 开头的网址"},
+			}},
+		},
+		{
+			name: "什么都没改",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			body:     `{"utime":1700000000000}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: Result{Code: errs.InvalidParam.Code, Msg: errs.InvalidParam.Msg},
+		},
+		{
+			name: "资料被别人改过了",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				svc := svcmocks.NewMockUserService(ctrl)
+				svc.EXPECT().UpdateProfile(gomock.Any(), int64(1), gomock.Any()).
+					Return(time.Time{}, service.ErrUserProfileConflict)
+				return svc
+			},
+			body:     `{"nickname":"小明","utime":1700000000000}`,
+			wantCode: http.StatusConflict,
+			wantBody: Result{Code: errs.UserProfileConflict.Code, Msg: errs.UserProfileConflict.Msg},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			h := NewUserHandler(tc.mock(ctrl), nil, nil, nil, nil)
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 1})
+			})
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPatch, "/users/profile", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.wantCode, resp.Code)
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+func TestUserHandler_PublicProfile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) service.UserService
+		path     string
+		wantCode int
+		wantBody Result
+	}{
+		{
+			name: "公开主页",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				svc := svcmocks.NewMockUserService(ctrl)
+				// 隐藏的字段 service 已经去掉了
+				svc.EXPECT().PublicProfile(gomock.Any(), "DaMing").Return(domain.User{
+					Id:       1,
+					Email:    "daming@qq.com",
+					Handle:   "daming",
+					Nickname: "大明",
+					Birthday: time.UnixMilli(0),
+					Gender:   domain.GenderMale,
+					Ctime:    time.UnixMilli(1700000000000),
+				}, nil)
+				return svc
+			},
+			path:     "/users/DaMing",
+			wantCode: http.StatusOK,
+			wantBody: Result{Msg: "OK", Data: map[string]any{
+				"handle":   "daming",
+				"nickname": "大明",
+				"avatar":   "",
+				"gender":   "male",
+				"location": "",
+				"website":  "",
+				"joinedAt": float64(1700000000000),
+			}},
+		},
+		{
+			name: "用户不存在",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				svc := svcmocks.NewMockUserService(ctrl)
+				svc.EXPECT().PublicProfile(gomock.Any(), "nobody").Return(domain.User{}, service.ErrUserNotFound)
+				return svc
+			},
+			path:     "/users/nobody",
+			wantCode: http.StatusNotFound,
+			wantBody: Result{Code: errs.UserNotFound.Code, Msg: errs.UserNotFound.Msg},
+		},
+		{
+			name: "静态路由优先",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				svc := svcmocks.NewMockUserService(ctrl)
+				svc.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{}, service.ErrUserNotFound)
+				return svc
+			},
+			path:     "/users/profile",
+			wantCode: http.StatusNotFound,
+			wantBody: Result{Code: errs.UserNotFound.Code, Msg: errs.UserNotFound.Msg},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			h := NewUserHandler(tc.mock(ctrl), nil, nil, nil, nil)
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 1})
+			})
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+
+			assert.Equal(t, tc.wantCode, resp.Code)
+			var res Result
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+// memAuditService 记下来的事件存在内存里
+type memAuditService struct {
+	service.AuthAuditService
+	events []domain.AuthEvent
+}
+
+func (s *memAuditService) Record(ctx context.Context, e domain.AuthEvent) {
+	s.events = append(s.events, e)
+}
+
+// okJWTHandler 登录的时候什么也不做
+type okJWTHandler struct {
+	ijwt.Handler
+}
+
+func (okJWTHandler) SetLoginToken(ctx *gin.Context, uid int64) error {
+	return nil
+}
+
+func TestUserHandler_Reactivate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) service.UserService
+		wantEvent domain.AuthEvent
+	}{
+		{
+			name: "恢复成功",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				svc := svcmocks.NewMockUserService(ctrl)
+				svc.EXPECT().ReactivateByEmail(gomock.Any(), "123@qq.com", "hello#world123").
+					Return(domain.User{Id: 1}, nil)
+				return svc
+			},
+			wantEvent: domain.AuthEvent{Uid: 1, Method: domain.AuthMethodPassword, Success: true},
+		},
+		{
+			name: "密码不对",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				svc := svcmocks.NewMockUserService(ctrl)
+				svc.EXPECT().ReactivateByEmail(gomock.Any(), "123@qq.com", "hello#world123").
+					Return(domain.User{Id: 1}, service.ErrInvalidUserOrPassword)
+				return svc
+			},
+			wantEvent: domain.AuthEvent{Uid: 1, Method: domain.AuthMethodPassword, Reason: errs.UserInvalidCredential.MsgKey},
+		},
+		{
+			name: "过了冷静期",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				svc := svcmocks.NewMockUserService(ctrl)
+				svc.EXPECT().ReactivateByEmail(gomock.Any(), "123@qq.com", "hello#world123").
+					Return(domain.User{Id: 1}, service.ErrReactivateExpired)
+				return svc
+			},
+			wantEvent: domain.AuthEvent{Uid: 1, Method: domain.AuthMethodPassword, Reason: errs.UserReactivateExpired.MsgKey},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			audit := &memAuditService{}
+			h := NewUserHandler(tc.mock(ctrl), nil, audit, okJWTHandler{}, nil)
+			server := gin.New()
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/reactivate",
+				bytes.NewBufferString(`{"email":"123@qq.com","password":"hello#world123"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "Chrome")
+			req.RemoteAddr = "127.0.0.1:1234"
+			server.ServeHTTP(httptest.NewRecorder(), req)
+
+			tc.wantEvent.IP = "127.0.0.1"
+			tc.wantEvent.UserAgent = "Chrome"
+			assert.Equal(t, []domain.AuthEvent{tc.wantEvent}, audit.events)
+		})
+	}
+}
+
+// 校验验证码出错了也要记审计日志
+func TestUserHandler_SMSVerifyErr(t *testing.T) {
+	for _, path := range []string{"/users/login_sms", "/users/reactivate_sms"} {
+		t.Run(path, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			codeSvc := svcmocks.NewMockCodeService(ctrl)
+			codeSvc.EXPECT().Verify(gomock.Any(), "login", "15212345678", "123456").
+				Return(false, errors.New("redis 出错"))
+			audit := &memAuditService{}
+			h := NewUserHandler(svcmocks.NewMockUserService(ctrl), codeSvc, audit, okJWTHandler{}, nil)
+			server := gin.New()
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, path,
+				bytes.NewBufferString(`{"phone":"15212345678","code":"123456"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			server.ServeHTTP(httptest.NewRecorder(), req)
+
+			require.Len(t, audit.events, 1)
+			assert.Equal(t, domain.AuthMethodSMS, audit.events[0].Method)
+			assert.False(t, audit.events[0].Success)
+			assert.Equal(t, errs.SystemError.MsgKey, audit.events[0].Reason)
+		})
+	}
+}
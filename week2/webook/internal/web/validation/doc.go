@@ -0,0 +1,2 @@
+// Package validation web 层的请求参数校验
+package validation
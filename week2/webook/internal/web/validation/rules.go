@@ -0,0 +1,57 @@
+package validation
+
+import (
+	regexp "github.com/dlclark/regexp2"
+	"github.com/go-playground/validator/v10"
+	"time"
+)
+
+const (
+	// 至少 8 位，至少一个字母、一个数字、一个特殊字符
+	passwordRegexPattern = `^(?=.*[A-Za-z])(?=.*\d)(?=.*[$@$!%*#?&])[A-Za-z\d$@$!%*#?&]{8,}$`
+	// 要求昵称长度在1到20个字符之间，禁止昵称为纯数字，禁止昵称为纯特殊符号或下划线
+	nicknameRegexPattern = `^(?=.{1,20}$)(?!^[0-9]*$)(?!^[\W_]*$)[a-zA-Z0-9\u4e00-\u9fa5._-]+$`
+	// 中国大陆的手机号
+	phoneRegexPattern = `^1[3-9]\d{9}$`
+	// 用户名，公开主页的地址里用，不区分大小写
+	handleRegexPattern = `^[A-Za-z][A-Za-z0-9_]{2,19}$`
+
+	// 生日最早是哪天
+	minBirthday = "1900-01-01"
+)
+
+var (
+	// 预编译正则表达式提升性能（Go 自带的 regexp 不支持 (?=) 这种断言，所以用 regexp2）
+	passwordRegexExp = regexp.MustCompile(passwordRegexPattern, regexp.None)
+	nicknameRegexExp = regexp.MustCompile(nicknameRegexPattern, regexp.None)
+	phoneRegexExp    = regexp.MustCompile(phoneRegexPattern, regexp.None)
+	handleRegexExp   = regexp.MustCompile(handleRegexPattern, regexp.None)
+
+	minBirthdayTime, _ = time.Parse(time.DateOnly, minBirthday)
+)
+
+// 自定义的规则，key 就是 validate tag 里用的名字
+var rules = map[string]validator.Func{
+	"password": matchRegexp(passwordRegexExp),
+	"nickname": matchRegexp(nicknameRegexExp),
+	"phone":    matchRegexp(phoneRegexExp),
+	"handle":   matchRegexp(handleRegexExp),
+	"birthday": validBirthday,
+}
+
+func matchRegexp(exp *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		ok, err := exp.MatchString(fl.Field().String())
+		// regexp2 只有匹配超时才会返回 err，当成不通过
+		return err == nil && ok
+	}
+}
+
+// validBirthday YYYY-MM-DD 格式，不早于 minBirthday，也不能是未来的日期
+func validBirthday(fl validator.FieldLevel) bool {
+	birthday, err := time.Parse(time.DateOnly, fl.Field().String())
+	if err != nil {
+		return false
+	}
+	return !birthday.Before(minBirthdayTime) && !birthday.After(time.Now())
+}
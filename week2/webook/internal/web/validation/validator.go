@@ -0,0 +1,102 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
+)
+
+// FieldError 一个字段没通过校验，所有的 FieldError 会一次性放在 Result.Data 里返回给前端
+type FieldError struct {
+	// Field 前端传过来的字段名（json 名）
+	Field string `json:"field"`
+	// Rule 没通过的规则，比如 required、email、max
+	Rule string `json:"rule"`
+	// Param 规则的参数，比如 max=200 的 200
+	Param string `json:"param,omitempty"`
+	Msg   string `json:"msg"`
+}
+
+// Validator 声明式的参数校验：规则写在 Req 字段的 validate tag 上，比如
+//
+//	Email string `json:"email" validate:"required,email"`
+//
+// 除了 validator 自带的规则（required、email、max、eqfield 等），还注册了 password、nickname、birthday、phone
+type Validator struct {
+	v *validator.Validate
+}
+
+func NewValidator() *Validator {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// 报错的时候用 json 名，前端才知道是哪个输入框
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	for tag, fn := range rules {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			// 只有 tag 为空才会出错，说明代码写错了
+			panic(err)
+		}
+	}
+	return &Validator{v: v}
+}
+
+// Struct 校验 req，全部通过返回 nil，否则返回所有没通过的字段
+func (v *Validator) Struct(req any) []FieldError {
+	err := v.v.Struct(req)
+	if err == nil {
+		return nil
+	}
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		// 传进来的不是结构体，属于代码写错了
+		panic(err)
+	}
+	res := make([]FieldError, 0, len(ves))
+	for _, fe := range ves {
+		res = append(res, FieldError{
+			Field: fe.Field(),
+			Rule:  fe.Tag(),
+			Param: fe.Param(),
+			Msg:   message(fe),
+		})
+	}
+	return res
+}
+
+// 各个规则默认的中文提示
+var messages = map[string]string{
+	"required": "不能为空",
+	"email":    "邮箱格式不对",
+	"password": "密码必须包含至少8个字符，至少1个字母，1个数字和1个特殊字符",
+	"nickname": "昵称只能是1到20个字的中英文、数字、下划线、点和横线，且不能是纯数字或纯符号",
+	"birthday": "生日必须是 YYYY-MM-DD 格式，并且在 " + minBirthday + " 到今天之间",
+	"phone":    "手机号格式不对",
+	"eqfield":  "和 %s 不一致",
+	"max":      "长度不能超过 %s 个字",
+	"min":      "长度不能少于 %s 个字",
+	"len":      "长度必须是 %s 个字",
+	"oneof":    "只能是 %s 其中之一",
+	"http_url": "必须是 http 或 https 开头的网址",
+	"handle":   "用户名只能是3到20个英文字母、数字和下划线，并且以字母开头",
+}
+
+func message(fe validator.FieldError) string {
+	msg, ok := messages[fe.Tag()]
+	if !ok {
+		return "不合法"
+	}
+	if strings.Contains(msg, "%s") {
+		return fmt.Sprintf(msg, fe.Param())
+	}
+	return msg
+}
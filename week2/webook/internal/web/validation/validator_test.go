@@ -0,0 +1,95 @@
+package validation
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestValidator_Struct(t *testing.T) {
+	type SignUpReq struct {
+		Email           string `json:"email" validate:"required,email"`
+		Password        string `json:"password" validate:"required,password"`
+		ConfirmPassword string `json:"confirmPassword" validate:"required,eqfield=Password"`
+	}
+	type EditReq struct {
+		Nickname string `json:"nickname" validate:"required,nickname"`
+		Birthday string `json:"birthday" validate:"required,birthday"`
+		Resume   string `json:"resume" validate:"required,max=5"`
+	}
+	testCases := []struct {
+		name string
+		req  any
+		// 只比较字段和规则，提示语不比较
+		want []FieldError
+	}{
+		{
+			name: "注册通过",
+			req: SignUpReq{
+				Email:           "123@qq.com",
+				Password:        "hello#world123",
+				ConfirmPassword: "hello#world123",
+			},
+		},
+		{
+			name: "注册时所有字段一起报错",
+			req: SignUpReq{
+				Email:           "123qq.com",
+				Password:        "hello",
+				ConfirmPassword: "world",
+			},
+			want: []FieldError{
+				{Field: "email", Rule: "email"},
+				{Field: "password", Rule: "password"},
+				{Field: "confirmPassword", Rule: "eqfield", Param: "Password"},
+			},
+		},
+		{
+			name: "编辑通过",
+			req: EditReq{
+				Nickname: "小明_01",
+				Birthday: "2000-01-01",
+				Resume:   "你好世界",
+			},
+		},
+		{
+			name: "纯数字昵称、未来的生日、简介太长",
+			req: EditReq{
+				Nickname: "123456",
+				Birthday: time.Now().AddDate(1, 0, 0).Format(time.DateOnly),
+				Resume:   "你好，世界！",
+			},
+			want: []FieldError{
+				{Field: "nickname", Rule: "nickname"},
+				{Field: "birthday", Rule: "birthday"},
+				{Field: "resume", Rule: "max", Param: "5"},
+			},
+		},
+		{
+			name: "生日格式不对",
+			req: EditReq{
+				Nickname: "小明",
+				Birthday: "2000/01/01",
+				Resume:   "你好",
+			},
+			want: []FieldError{
+				{Field: "birthday", Rule: "birthday"},
+			},
+		},
+	}
+	v := NewValidator()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fes := v.Struct(tc.req)
+			for i := range fes {
+				assert.NotEmpty(t, fes[i].Msg)
+				fes[i].Msg = ""
+			}
+			if tc.want == nil {
+				assert.Nil(t, fes)
+				return
+			}
+			assert.Equal(t, tc.want, fes)
+		})
+	}
+}
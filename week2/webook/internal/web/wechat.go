@@ -1,30 +1,37 @@
 package web
 
 import (
+	"basic-go/week2/webook/internal/domain"
 	"basic-go/week2/webook/internal/service"
 	"basic-go/week2/webook/internal/service/oauth2/wechat"
+	"basic-go/week2/webook/internal/web/errs"
 	ijwt "basic-go/week2/webook/internal/web/jwt"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	uuid "github.com/lithammer/shortuuid/v4"
-	"net/http"
+	"log"
 )
 
 type OAuth2WechatHandler struct {
-	svc     wechat.Service
-	userSvc service.UserService
+	apps          *wechat.Apps
+	userSvc       service.UserService
+	wechatUserSvc service.WechatUserService
+	auditSvc      service.AuthAuditService
 	// 结构体就不用通过注入来构建，指针需要
 	ijwt.Handler
 	jWTKey          []byte
 	stateCookieName string
 }
 
-func NewOAuth2WechatHandler(svc wechat.Service, userSvc service.UserService, hdl ijwt.Handler) *OAuth2WechatHandler {
+func NewOAuth2WechatHandler(apps *wechat.Apps, userSvc service.UserService,
+	wechatUserSvc service.WechatUserService, auditSvc service.AuthAuditService, hdl ijwt.Handler) *OAuth2WechatHandler {
 	return &OAuth2WechatHandler{
-		svc:             svc,
+		apps:            apps,
 		userSvc:         userSvc,
+		wechatUserSvc:   wechatUserSvc,
+		auditSvc:        auditSvc,
 		jWTKey:          []byte("IKD20XkWAXJus2zS7R97SH51K7XgQrLB"),
 		stateCookieName: "jwt-state",
 		Handler:         hdl,
@@ -33,7 +40,8 @@ func NewOAuth2WechatHandler(svc wechat.Service, userSvc service.UserService, hdl
 
 func (h *OAuth2WechatHandler) RegisterRoutes(server *gin.Engine) {
 	g := server.Group("/oauth2/wechat")
-	// 跳到wx的url
+	// 跳到wx的url，用 ?app=h5 选择微信应用，不带就是默认的网站扫码登录。
+	// 登录的时候收到 errs.UserDeactivated，带上 ?reactivate=true 重新授权一次来恢复账号
 	g.GET("/authurl", h.OAuth2URL)
 	// 处理wx跳转回来的请求
 	g.Any("/callback", h.Callback)
@@ -41,76 +49,90 @@ func (h *OAuth2WechatHandler) RegisterRoutes(server *gin.Engine) {
 }
 
 func (h *OAuth2WechatHandler) OAuth2URL(ctx *gin.Context) {
+	app := ctx.DefaultQuery("app", h.apps.DefaultApp())
+	svc, ok := h.apps.Get(app)
+	if !ok {
+		writeCode(ctx, errs.WechatUnknownApp)
+		return
+	}
 	// 该state要放到jwt中
 	state := uuid.New()
 
-	val, err := h.svc.AuthURL(ctx, state)
+	val, err := svc.AuthURL(ctx, state)
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 5,
-			Msg:  "构造跳转wx登录的url失败",
-		})
+		writeErr(ctx, err)
 		return
 	}
-	err = h.setStateCookie(ctx, state)
+	err = h.setStateCookie(ctx, app, state, ctx.Query("reactivate") == "true")
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 5,
-			Msg:  "服务器异常",
-		})
+		writeErr(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, Result{
-		Data: val,
-	})
-
+	writeData(ctx, val)
 }
 
 func (h *OAuth2WechatHandler) Callback(ctx *gin.Context) {
 	// 校验state，防止csrf攻击
-	err := h.verifyState(ctx)
+	sc, err := h.verifyState(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 4,
-			Msg:  "非法请求",
-		})
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodWechat, 0, errs.WechatInvalidState)
+		writeCode(ctx, errs.WechatInvalidState)
+		return
+	}
+	// 用发起登录时的那个微信应用来校验授权码
+	svc, ok := h.apps.Get(sc.App)
+	if !ok {
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodWechat, 0, errs.WechatUnknownApp)
+		writeCode(ctx, errs.WechatUnknownApp)
+		return
 	}
 
 	code := ctx.Query("code")
 	// state := ctx.Query("state")
-	wechatInfo, err := h.svc.VerifyCode(ctx, code)
+	wechatInfo, err := svc.VerifyCode(ctx, code)
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 4,
-			Msg:  "授权码有误",
-		})
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodWechat, 0, errs.WechatInvalidCode)
+		writeCode(ctx, errs.WechatInvalidCode)
 		return
 	}
 
-	// 临时授权码code校验成功，即登录成功
-	u, err := h.userSvc.FindOrCreateByWechat(ctx, wechatInfo)
+	// 临时授权码code校验成功，即登录成功。恢复账号也是，恢复成功就是登录成功
+	var u domain.User
+	if sc.Reactivate {
+		u, err = h.userSvc.ReactivateByWechat(ctx, wechatInfo.OpenId)
+	} else {
+		u, err = h.userSvc.FindOrCreateByWechat(ctx, wechatInfo)
+	}
 	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Code: 5,
-			Msg:  "系统错误",
-		})
+		recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodWechat, u.Id, errs.FromError(err))
+		writeErr(ctx, err)
 		return
 	}
+	// 保存微信凭证，首次登录的时候用微信的昵称和头像补全资料
+	u, err = h.wechatUserSvc.SyncProfile(ctx, u, wechatInfo.Token)
+	if err != nil {
+		// 补全资料失败不影响登录
+		log.Println("同步微信用户资料失败", err)
+	}
 	// 登录成功后先设置refresh-token
 	err = h.SetLoginToken(ctx, u.Id)
+	recordAuthEvent(ctx, h.auditSvc, domain.AuthMethodWechat, u.Id, errs.FromError(err))
 	if err != nil {
-		ctx.String(http.StatusOK, "系统错误（JWT的refresh-token）")
+		writeErr(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, Result{
-		Msg: "OK",
-	})
-	return
+	if sc.Reactivate {
+		writeOK(ctx, "user.reactivate_ok")
+		return
+	}
+	writeOK(ctx, "user.login_ok")
 }
 
-func (h *OAuth2WechatHandler) setStateCookie(ctx *gin.Context, state string) error {
+func (h *OAuth2WechatHandler) setStateCookie(ctx *gin.Context, app string, state string, reactivate bool) error {
 	claims := StateClaims{
-		State: state,
+		State:      state,
+		App:        app,
+		Reactivate: reactivate,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 	tokenStr, err := token.SignedString(h.jWTKey)
@@ -121,26 +143,30 @@ func (h *OAuth2WechatHandler) setStateCookie(ctx *gin.Context, state string) err
 	return nil
 }
 
-func (h *OAuth2WechatHandler) verifyState(ctx *gin.Context) error {
+func (h *OAuth2WechatHandler) verifyState(ctx *gin.Context) (StateClaims, error) {
 	state := ctx.Query("state")
 	cookie, err := ctx.Cookie(h.stateCookieName)
 	if err != nil {
-		return fmt.Errorf("%w, 无法获得 cookie ", err)
+		return StateClaims{}, fmt.Errorf("%w, 无法获得 cookie ", err)
 	}
 	var sc StateClaims
 	_, err = jwt.ParseWithClaims(cookie, &sc, func(token *jwt.Token) (interface{}, error) {
 		return h.jWTKey, nil
 	})
 	if err != nil {
-		return fmt.Errorf("%w, 无法获得 cookie ", err)
+		return StateClaims{}, fmt.Errorf("%w, 无法获得 cookie ", err)
 	}
 	if state != sc.State {
-		return errors.New("state 被篡改了")
+		return StateClaims{}, errors.New("state 被篡改了")
 	}
-	return nil
+	return sc, nil
 }
 
 type StateClaims struct {
 	jwt.RegisteredClaims
 	State string
+	// App 发起登录的是哪个微信应用
+	App string
+	// Reactivate 这次授权是用来恢复注销了的账号的
+	Reactivate bool
 }
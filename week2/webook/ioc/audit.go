@@ -0,0 +1,34 @@
+package ioc
+
+import (
+	"basic-go/week2/webook/internal/repository"
+	"basic-go/week2/webook/internal/service"
+	"context"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// InitAuthAuditService 审计日志异步写库，写库的协程跟着进程一直跑
+func InitAuthAuditService(repo repository.AuthEventRepository) service.AuthAuditService {
+	type Config struct {
+		// BufferSize 缓冲多少条，满了之后新的会被丢掉
+		BufferSize    int
+		BatchSize     int
+		FlushInterval time.Duration
+	}
+	c := Config{
+		BufferSize:    10000,
+		BatchSize:     100,
+		FlushInterval: time.Second,
+	}
+	err := viper.UnmarshalKey("audit", &c)
+	if err != nil {
+		panic(err)
+	}
+	// 新设备登录暂时只打日志，以后要发短信、邮件提醒就在这里加
+	svc := service.NewAsyncAuthAuditService(repo, c.BufferSize, service.LogNewDeviceHook{}).
+		BatchSize(c.BatchSize).
+		FlushInterval(c.FlushInterval)
+	svc.Start(context.Background())
+	return svc
+}
@@ -0,0 +1,69 @@
+package ioc
+
+import (
+	"basic-go/week2/webook/internal/repository/cache"
+	"basic-go/week2/webook/pkg/breaker"
+	"basic-go/week2/webook/pkg/codec"
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// InitUserCache 用户缓存分两级：本地 LRU 在前，redis 在后。
+// redis 外面包一层熔断：连续出错 5 次就熔断 10 秒，期间用户查询走降级逻辑
+func InitUserCache(client redis.UniversalClient) cache.UserCache {
+	type LocalConfig struct {
+		Size       int
+		Expiration time.Duration
+	}
+	type RedisConfig struct {
+		Expiration         time.Duration
+		NotFoundExpiration time.Duration
+		Jitter             time.Duration
+		// Codec 序列化方式，json 或者 msgpack
+		Codec string
+	}
+	type Config struct {
+		Local LocalConfig
+		Redis RedisConfig
+		// InvalidateChannel 用户更新之后通知所有实例删本地缓存的频道
+		InvalidateChannel string
+	}
+	// 默认值，配置文件里没有 cache.user 这一段也能跑
+	c := Config{
+		Local: LocalConfig{
+			Size: 10000,
+			// 失效消息可能会丢，所以要比 redis 的短很多
+			Expiration: time.Second * 30,
+		},
+		Redis: RedisConfig{
+			Expiration:         time.Minute * 15,
+			NotFoundExpiration: time.Minute,
+			Jitter:             time.Minute * 3,
+			Codec:              "msgpack",
+		},
+		InvalidateChannel: "user:info:invalidate",
+	}
+	err := viper.UnmarshalKey("cache.user", &c)
+	if err != nil {
+		panic(err)
+	}
+	cc, ok := codec.ByName(c.Redis.Codec)
+	if !ok {
+		panic(fmt.Sprintf("不支持的缓存序列化方式 %q", c.Redis.Codec))
+	}
+	remote := cache.NewRedisUserCache(client).
+		Expiration(c.Redis.Expiration).
+		NotFoundExpiration(c.Redis.NotFoundExpiration).
+		Jitter(c.Redis.Jitter).
+		Codec(cc)
+	res := cache.NewMultiLevelUserCache(
+		cache.NewLocalUserCache(c.Local.Size, c.Local.Expiration),
+		cache.NewBreakerUserCache(remote, breaker.NewBreaker(5, time.Second*10)),
+		client, c.InvalidateChannel)
+	// 跟着进程一直订阅
+	res.Subscribe(context.Background())
+	return res
+}
@@ -3,32 +3,115 @@ package ioc
 import (
 	"basic-go/week2/webook/config"
 	"basic-go/week2/webook/internal/repository/dao"
+	"basic-go/week2/webook/pkg/migrate"
+	"context"
+	"fmt"
 	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"log"
+	"strings"
 )
 
 func InitDB() *gorm.DB {
+	db := openDB()
+	// 表结构由迁移脚本管理，上线前先跑 webook migrate up
+	checkMigrations(db)
+	return db
+}
+
+// InitMigrator 给 webook migrate 子命令用，不检查迁移
+func InitMigrator() *migrate.Migrator {
+	m, err := dao.NewMigrator(openDB())
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
 
+func openDB() *gorm.DB {
 	// note viper：用一个内部结构体接收配置
 	type Config struct {
-		DSN string `yaml:"dsn"`
+		// Driver mysql 或者 sqlite，不配就是 mysql。本地开发没有 MySQL 的时候可以用 sqlite
+		Driver string `yaml:"driver"`
+		DSN    string `yaml:"dsn"`
+		// Replicas 从库的 DSN，可以不配，不配就读写都走主库
+		Replicas []string `yaml:"replicas"`
+	}
+	c := Config{
+		Driver: "mysql",
+		DSN:    config.Config.DB.DSN,
 	}
-	var c Config
 	err := viper.UnmarshalKey("db", &c)
 	if err != nil {
 		panic("viper初始化db失败")
 	}
-	// gorm连接mysql
-	db, err := gorm.Open(mysql.Open(config.Config.DB.DSN))
+	// gorm连接数据库
+	db, err := gorm.Open(openDialector(c.Driver, c.DSN))
 	if err != nil {
 		// 服务器都出错就直接panic不用return啦
 		panic(err)
 	}
-	// 建表（有点耦合，但没优化办法）
-	err = dao.InitTables(db)
+	if len(c.Replicas) > 0 {
+		// 读写分离：写和事务走主库，读随机选一个从库。需要读主库的用 dbx.WithPrimary
+		replicas := make([]gorm.Dialector, 0, len(c.Replicas))
+		for _, dsn := range c.Replicas {
+			replicas = append(replicas, openDialector(c.Driver, dsn))
+		}
+		err = db.Use(dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		}))
+		if err != nil {
+			panic(err)
+		}
+	}
+	return db
+}
+
+func openDialector(driver string, dsn string) gorm.Dialector {
+	switch driver {
+	case "mysql":
+		return mysql.Open(dsn)
+	case "sqlite":
+		// dsn 就是文件路径，比如 webook.db；file::memory:?cache=shared 是内存数据库
+		return sqlite.Open(dsn)
+	default:
+		panic(fmt.Sprintf("不支持的数据库 %q", driver))
+	}
+}
+
+// checkMigrations 还有没执行的迁移脚本就不能启动，不然请求会在运行的时候才报找不到表、找不到列。
+// sqlite 只是本地开发用，直接执行；别的数据库要先运行 webook migrate up
+func checkMigrations(db *gorm.DB) {
+	m, err := dao.NewMigrator(db)
 	if err != nil {
 		panic(err)
 	}
-	return db
+	ctx := context.Background()
+	if db.Dialector.Name() == "sqlite" {
+		done, err := m.Up(ctx)
+		if err != nil {
+			panic(err)
+		}
+		for _, mg := range done {
+			log.Printf("已执行数据库迁移 %04d_%s", mg.Version, mg.Name)
+		}
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if len(pending) > 0 {
+		names := make([]string, 0, len(pending))
+		for _, mg := range pending {
+			names = append(names, fmt.Sprintf("%04d_%s", mg.Version, mg.Name))
+		}
+		panic(fmt.Sprintf("数据库迁移 %s 还没有执行，请先运行 webook migrate up", strings.Join(names, ", ")))
+	}
+	if err = m.Verify(ctx); err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,15 @@
+package ioc
+
+import (
+	"basic-go/week2/webook/pkg/signurl"
+	"github.com/spf13/viper"
+)
+
+// InitURLSigner 给导出的下载链接签名用，密钥不能为空
+func InitURLSigner() *signurl.Signer {
+	key := viper.GetString("export.signKey")
+	if key == "" {
+		panic("没有配置下载链接的签名密钥（export.signKey）")
+	}
+	return signurl.NewSigner([]byte(key))
+}
@@ -0,0 +1,42 @@
+package ioc
+
+import (
+	"basic-go/week2/webook/pkg/httpx"
+	"github.com/spf13/viper"
+	"net/http"
+	"time"
+)
+
+// InitHTTPClient 调用第三方接口用的 client，所有对外的请求共用一个连接池
+func InitHTTPClient() httpx.Client {
+	type Config struct {
+		Timeout       time.Duration
+		MaxRetries    int
+		RetryInterval time.Duration
+		MaxBodySize   int64
+	}
+	// 默认值，配置文件里没有 http 这一段也能跑
+	c := Config{
+		Timeout:       time.Second * 3,
+		MaxRetries:    2,
+		RetryInterval: time.Millisecond * 100,
+		MaxBodySize:   1 << 20,
+	}
+	err := viper.UnmarshalKey("http", &c)
+	if err != nil {
+		panic(err)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     time.Minute,
+		},
+	}
+	return httpx.NewRetryClient(client).
+		Timeout(c.Timeout).
+		MaxRetries(c.MaxRetries).
+		RetryInterval(c.RetryInterval).
+		MaxBodySize(c.MaxBodySize)
+}
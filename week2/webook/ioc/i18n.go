@@ -0,0 +1,29 @@
+package ioc
+
+import (
+	"basic-go/week2/webook/pkg/i18n"
+	"github.com/spf13/viper"
+	"os"
+)
+
+// InitI18nCatalog 加载多语言消息文件，文件有问题启动的时候就报出来
+func InitI18nCatalog() *i18n.Catalog {
+	type Config struct {
+		// Dir 消息文件所在的目录，相对于工作目录
+		Dir           string
+		DefaultLocale string
+	}
+	c := Config{
+		Dir:           "config/i18n",
+		DefaultLocale: "zh-CN",
+	}
+	err := viper.UnmarshalKey("i18n", &c)
+	if err != nil {
+		panic(err)
+	}
+	catalog, err := i18n.Load(os.DirFS(c.Dir), ".", c.DefaultLocale)
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
@@ -0,0 +1,103 @@
+package ioc
+
+import (
+	"basic-go/week2/webook/internal/job"
+	"basic-go/week2/webook/internal/repository/dao"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"log"
+	"time"
+)
+
+// UserMigration 不停服迁移 users 表要用到的东西，没有配置 migration.user.dst 的时候是 nil
+type UserMigration struct {
+	Dao *dao.DoubleWriteUserDao
+	Src dao.UserMigrateDao
+	Dst dao.UserMigrateDao
+	// Validate 校验任务的配置
+	Interval  time.Duration
+	BatchSize int
+}
+
+// InitUserMigration 按配置打开目标库，组装双写的 dao。
+// 双写模式 migration.user.pattern 改了配置文件就生效，不用重启
+func InitUserMigration(db *gorm.DB) *UserMigration {
+	type DstConfig struct {
+		// Driver 不配就和源库一样
+		Driver string
+		DSN    string
+	}
+	type Config struct {
+		Pattern   string
+		Dst       DstConfig
+		Interval  time.Duration
+		BatchSize int
+	}
+	c := Config{
+		Pattern:   dao.PatternSrcOnly,
+		Interval:  time.Minute * 10,
+		BatchSize: 100,
+	}
+	err := viper.UnmarshalKey("migration.user", &c)
+	if err != nil {
+		panic(err)
+	}
+	if c.Dst.DSN == "" {
+		return nil
+	}
+	if c.Dst.Driver == "" {
+		c.Dst.Driver = viper.GetString("db.driver")
+	}
+	if c.Dst.Driver == "" {
+		c.Dst.Driver = "mysql"
+	}
+	dst, err := gorm.Open(openDialector(c.Dst.Driver, c.Dst.DSN))
+	if err != nil {
+		panic(err)
+	}
+	checkMigrations(dst)
+
+	d, err := dao.NewDoubleWriteUserDao(dao.NewUserDao(db), dao.NewUserDao(dst), c.Pattern)
+	if err != nil {
+		panic(err)
+	}
+	// note viper 只能注册一个 OnConfigChange，以后别的配置也要热更新的话，要在这里一起处理
+	viper.OnConfigChange(func(in fsnotify.Event) {
+		pattern := viper.GetString("migration.user.pattern")
+		if pattern == d.Pattern() {
+			return
+		}
+		if err := d.UpdatePattern(pattern); err != nil {
+			log.Println("切换 users 表双写模式失败", pattern, err)
+			return
+		}
+		log.Println("users 表双写模式切换为", pattern)
+	})
+	viper.WatchConfig()
+	return &UserMigration{
+		Dao:       d,
+		Src:       dao.NewUserMigrateDao(db),
+		Dst:       dao.NewUserMigrateDao(dst),
+		Interval:  c.Interval,
+		BatchSize: c.BatchSize,
+	}
+}
+
+// InitUserDao 在迁移 users 表就用双写的 dao，否则就是普通的 dao
+func InitUserDao(db *gorm.DB, m *UserMigration) dao.UserDao {
+	if m == nil {
+		return dao.NewUserDao(db)
+	}
+	return m.Dao
+}
+
+// InitUserMigrationValidateJob 没有在迁移就返回 nil，不用启动
+func InitUserMigrationValidateJob(m *UserMigration) *job.UserMigrationValidateJob {
+	if m == nil {
+		return nil
+	}
+	return job.NewUserMigrationValidateJob(m.Dao, m.Src, m.Dst).
+		Interval(m.Interval).
+		BatchSize(m.BatchSize)
+}
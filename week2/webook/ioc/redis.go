@@ -5,7 +5,9 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-func InitRedis() redis.Cmdable {
+// InitRedis 返回 UniversalClient 而不是 Cmdable，因为发布订阅要用到；
+// 只需要 Cmdable 的地方用 wire.Bind 绑定
+func InitRedis() redis.UniversalClient {
 	return redis.NewClient(&redis.Options{
 		Addr: config.Config.Redis.Addr,
 	})
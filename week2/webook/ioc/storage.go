@@ -0,0 +1,43 @@
+package ioc
+
+import (
+	"basic-go/week2/webook/pkg/httpx"
+	"basic-go/week2/webook/pkg/storage"
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+// InitStorage 上传的文件存在哪里，type 是 local 或者 s3
+func InitStorage(client httpx.Client) storage.Storage {
+	type LocalConfig struct {
+		Dir       string
+		URLPrefix string
+	}
+	type Config struct {
+		Type  string
+		Local LocalConfig
+		S3    storage.S3Config
+	}
+	c := Config{
+		Type: "local",
+		Local: LocalConfig{
+			Dir:       "uploads",
+			URLPrefix: "/uploads",
+		},
+	}
+	err := viper.UnmarshalKey("storage", &c)
+	if err != nil {
+		panic(err)
+	}
+	switch c.Type {
+	case "local":
+		return storage.NewLocalStorage(c.Local.Dir, c.Local.URLPrefix)
+	case "s3":
+		if c.S3.Endpoint == "" || c.S3.Bucket == "" {
+			panic("S3 存储没有配置 endpoint 或者 bucket（storage.s3）")
+		}
+		return storage.NewS3Storage(c.S3, client)
+	default:
+		panic(fmt.Sprintf("不支持的存储类型 %q", c.Type))
+	}
+}
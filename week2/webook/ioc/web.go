@@ -1,10 +1,13 @@
 package ioc
 
 import (
+	"basic-go/week2/webook/internal/service"
 	"basic-go/week2/webook/internal/web"
 	ijwt "basic-go/week2/webook/internal/web/jwt"
 	"basic-go/week2/webook/internal/web/middleware"
 	"basic-go/week2/webook/pkg/ginx/middleware/ratelimit"
+	"basic-go/week2/webook/pkg/i18n"
+	"basic-go/week2/webook/pkg/storage"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -12,16 +15,26 @@ import (
 	"time"
 )
 
-func InitWebServer(mdls []gin.HandlerFunc, userHdl *web.UserHandler, wechatHdl *web.OAuth2WechatHandler) *gin.Engine {
+func InitWebServer(mdls []gin.HandlerFunc, userHdl *web.UserHandler, wechatHdl *web.OAuth2WechatHandler,
+	exportHdl *web.UserExportHandler, avatarHdl *web.UserAvatarHandler, articleHdl *web.ArticleHandler,
+	store storage.Storage) *gin.Engine {
 	server := gin.Default()
+	// 存在本地的文件由自己来提供访问。要在 Use 之前注册，这样就不经过登录校验和限流这些 middleware
+	if local, ok := store.(*storage.LocalStorage); ok {
+		server.Static(local.URLPrefix(), local.Root())
+	}
 	// NOTE *gin.Engine的两大用处：注册middleware和注册路由
 	server.Use(mdls...)
 	userHdl.RegisterRoutes(server)
 	wechatHdl.RegisterRoutes(server)
+	exportHdl.RegisterRoutes(server)
+	avatarHdl.RegisterRoutes(server)
+	articleHdl.RegisterRoutes(server)
 	return server
 }
 
-func InitGinMiddlewares(redisClient redis.Cmdable, hdl ijwt.Handler) []gin.HandlerFunc {
+func InitGinMiddlewares(redisClient redis.Cmdable, hdl ijwt.Handler,
+	catalog *i18n.Catalog, userSvc service.UserService) []gin.HandlerFunc {
 	return []gin.HandlerFunc{
 		// gin提供了一个middleware中间件来解决跨域问题————cors（有跨域问题时才会触发）
 		cors.New(cors.Config{
@@ -39,9 +52,9 @@ func InitGinMiddlewares(redisClient redis.Cmdable, hdl ijwt.Handler) []gin.Handl
 			//AllowMethods:     []string{"PUT", "POST"},
 			// 3. headers
 			// 前端要把token放在authorization里面
-			AllowHeaders: []string{"content-type", "authorization"},
+			AllowHeaders: []string{"content-type", "authorization", "accept-language"},
 			// 允许前端访问到你的后端响应中带的header【跨域问题类型】【加几个header就要在这允许几个】
-			ExposeHeaders: []string{"x-jwt-token", "x-refresh-token"},
+			ExposeHeaders: []string{"x-jwt-token", "x-refresh-token", "content-language"},
 			// 4. 是否允许cookie
 			AllowCredentials: true,
 			MaxAge:           12 * time.Hour,
@@ -51,5 +64,7 @@ func InitGinMiddlewares(redisClient redis.Cmdable, hdl ijwt.Handler) []gin.Handl
 		},
 		ratelimit.NewBuilder(redisClient, time.Second, 1000).Build(),
 		middleware.NewLoginJWTMiddlewareBuilder(hdl).CheckLoginJWT(),
+		// 登录校验之后才知道是哪个用户，才能用用户自己设置的语言
+		middleware.NewI18nMiddlewareBuilder(catalog, userSvc).Build(),
 	}
 }
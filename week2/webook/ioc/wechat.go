@@ -2,21 +2,61 @@ package ioc
 
 import (
 	"basic-go/week2/webook/internal/service/oauth2/wechat"
+	"basic-go/week2/webook/pkg/cryptox"
+	"basic-go/week2/webook/pkg/httpx"
+	"fmt"
+	"github.com/spf13/viper"
 	"os"
+	"strings"
 )
 
-func InitWechatService() wechat.Service {
-	// 从环境变量中取出 appid （因为appid是敏感信息，所以放在环境变量或配置文件中）
-	// os.getenv("WECHAT_APP_ID")是假设一定有这个环境变量
+// InitWechatApps 从配置文件的 wechat 下面读出所有的微信应用，配置不对直接 panic
+func InitWechatApps(client httpx.Client) *wechat.Apps {
+	type Config struct {
+		DefaultApp string
+		Apps       map[string]wechat.Config
+	}
+	var c Config
+	err := viper.UnmarshalKey("wechat", &c)
+	if err != nil {
+		panic(fmt.Errorf("读取微信配置失败: %w", err))
+	}
+	if len(c.Apps) == 0 {
+		panic("没有配置任何微信应用（wechat.apps）")
+	}
+	services := make(map[string]wechat.Service, len(c.Apps))
+	for name, cfg := range c.Apps {
+		cfg.Name = name
+		// appid 和 appSecret 是敏感信息，可以不写在配置文件里，而是放在环境变量 WECHAT_{应用名}_APP_ID 中
+		cfg.AppID = lookupEnvOr(name, "APP_ID", cfg.AppID)
+		cfg.AppSecret = lookupEnvOr(name, "APP_SECRET", cfg.AppSecret)
+		cfg = cfg.WithDefaults()
+		if err = cfg.Validate(); err != nil {
+			panic(err)
+		}
+		services[name] = wechat.NewService(cfg, client)
+	}
+	if _, ok := services[c.DefaultApp]; !ok {
+		panic(fmt.Errorf("默认的微信应用 %q 没有配置", c.DefaultApp))
+	}
+	return wechat.NewApps(c.DefaultApp, services)
+}
 
-	appID, ok := os.LookupEnv("WECHAT_APP_ID")
+func lookupEnvOr(app, key, val string) string {
+	env, ok := os.LookupEnv(fmt.Sprintf("WECHAT_%s_%s", strings.ToUpper(app), key))
 	if !ok {
-		// panic("找不到环境变量WECHAT_APP_ID")
+		return val
 	}
+	return env
+}
 
-	appSecret, ok := os.LookupEnv("WECHAT_APP_SECRET")
-	if !ok {
-		// panic("找不到环境变量WECHAT_APP_SECRET")
+// InitWechatTokenCipher 微信凭证落库前用的加密器，密钥放在配置文件里
+func InitWechatTokenCipher() cryptox.Cipher {
+	key := viper.GetString("wechat.tokenKey")
+	c, err := cryptox.NewAESGCM([]byte(key))
+	if err != nil {
+		// 密钥不对的话凭证存不进去，启动的时候就要暴露出来
+		panic(fmt.Errorf("微信凭证的加密密钥不合法: %w", err))
 	}
-	return wechat.NewService(appID, appSecret)
+	return c
 }
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 	"log"
 	"net/http"
+	"os"
 )
 
 func main() {
 
 	initViperV1()
 
+	// webook migrate ... 是数据库迁移的子命令，执行完就退出
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	//
 	//// 连接mysql + 建表
 	//db := initDB()
@@ -22,7 +30,15 @@ func main() {
 	//codeSvc := initCodeSvc(redisClient)
 	//initUser(db, server, redisClient, codeSvc)
 
-	server := InitWebServer()
+	app := InitApp()
+	// 后台任务跟着服务器一起启动
+	app.WechatTokenJob.Start(context.Background())
+	app.UserAnonymizeJob.Start(context.Background())
+	if app.UserMigrationValidateJob != nil {
+		app.UserMigrationValidateJob.Start(context.Background())
+	}
+
+	server := app.Server
 	server.GET("/hello", func(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "hello go")
 	})
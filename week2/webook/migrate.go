@@ -0,0 +1,72 @@
+package main
+
+import (
+	"basic-go/week2/webook/ioc"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = `用法:
+  webook migrate up        执行所有还没执行的迁移
+  webook migrate down [n]  回滚最近的 n 个迁移，默认 1 个
+  webook migrate status    查看每个迁移有没有执行`
+
+// runMigrate 数据库迁移子命令，只连数据库，不启动服务
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		exitMigrate(migrateUsage)
+	}
+	m := ioc.InitMigrator()
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, mg := range done {
+			fmt.Printf("已执行 %04d_%s\n", mg.Version, mg.Name)
+		}
+		if err != nil {
+			exitMigrate(err.Error())
+		}
+		if len(done) == 0 {
+			fmt.Println("没有需要执行的迁移")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				exitMigrate("回滚的个数必须是正整数")
+			}
+			steps = n
+		}
+		done, err := m.Down(ctx, steps)
+		for _, mg := range done {
+			fmt.Printf("已回滚 %04d_%s\n", mg.Version, mg.Name)
+		}
+		if err != nil {
+			exitMigrate(err.Error())
+		}
+	case "status":
+		sts, err := m.Status(ctx)
+		if err != nil {
+			exitMigrate(err.Error())
+		}
+		for _, st := range sts {
+			appliedAt := "未执行"
+			if st.Applied {
+				appliedAt = time.UnixMilli(st.AppliedAt).Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", st.Version, st.Name, appliedAt)
+		}
+	default:
+		exitMigrate(migrateUsage)
+	}
+}
+
+func exitMigrate(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(1)
+}
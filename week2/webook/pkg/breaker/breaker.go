@@ -0,0 +1,95 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+type state int
+
+const (
+	// stateClosed 正常，所有请求都放过去
+	stateClosed state = iota
+	// stateOpen 熔断，所有请求都直接拒绝
+	stateOpen
+	// stateHalfOpen 熔断时间到了，只放一个请求过去试探下游是否恢复
+	stateHalfOpen
+)
+
+// Breaker 按连续失败次数熔断的熔断器，并发安全
+//
+// 用法：调下游之前先 Allow，调完之后按结果调 Success、Failure 或者 Ignore
+type Breaker struct {
+	lock sync.Mutex
+	// 连续失败多少次就熔断
+	threshold int
+	// 熔断多久之后开始试探
+	openTimeout time.Duration
+
+	state    state
+	failures int
+	openedAt time.Time
+	// 半开状态下是不是已经有一个试探请求在路上了
+	probing bool
+	now     func() time.Time
+}
+
+func NewBreaker(threshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+	}
+}
+
+// Allow 这次能不能调下游，返回 false 的时候不用再调 Success、Failure 或者 Ignore
+func (b *Breaker) Allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		return true
+	case stateHalfOpen:
+		// 已经有试探请求了，其它的继续拒绝
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success 下游调用成功，半开状态下说明下游恢复了
+func (b *Breaker) Success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.state = stateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure 下游调用失败，连续失败到阈值或者试探失败就熔断
+func (b *Breaker) Failure() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		b.state = stateOpen
+		b.openedAt = b.now()
+		b.probing = false
+	}
+}
+
+// Ignore 这次调用的结果说明不了下游的好坏（比如调用方自己取消了），半开状态下让下一个请求再去试探
+func (b *Breaker) Ignore() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,43 @@
+package breaker
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(3, time.Second)
+	b.now = func() time.Time { return now }
+
+	// 连续失败 2 次还没到阈值
+	for i := 0; i < 2; i++ {
+		assert.True(t, b.Allow())
+		b.Failure()
+	}
+	// 成功一次，失败次数清零
+	assert.True(t, b.Allow())
+	b.Success()
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Allow())
+		b.Failure()
+	}
+	// 熔断了
+	assert.False(t, b.Allow())
+
+	// 熔断时间到了，只放一个试探请求
+	now = now.Add(time.Second)
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+	// 试探失败，重新熔断
+	b.Failure()
+	assert.False(t, b.Allow())
+
+	now = now.Add(time.Second)
+	assert.True(t, b.Allow())
+	// 试探成功，恢复
+	b.Success()
+	assert.True(t, b.Allow())
+	assert.True(t, b.Allow())
+}
@@ -0,0 +1,73 @@
+package codec
+
+import (
+	"encoding/json"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec 缓存值的序列化方式
+type Codec interface {
+	// ID 写在 Envelope 里，读的时候按它找对应的 Codec。已经用过的 ID 不能改
+	ID() byte
+	Name() string
+	Marshal(val any) ([]byte, error)
+	Unmarshal(data []byte, val any) error
+}
+
+var (
+	JSON    Codec = jsonCodec{}
+	Msgpack Codec = msgpackCodec{}
+)
+
+// codecs 所有支持的 Codec，按 ID 索引
+var codecs = map[byte]Codec{
+	JSON.ID():    JSON,
+	Msgpack.ID(): Msgpack,
+}
+
+// ByName 按配置里的名字找 Codec
+func ByName(name string) (Codec, bool) {
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte {
+	return 1
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(val any) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+func (jsonCodec) Unmarshal(data []byte, val any) error {
+	return json.Unmarshal(data, val)
+}
+
+// msgpackCodec 比 JSON 小，编解码也更快，字段名按 msgpack tag 来
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte {
+	return 2
+}
+
+func (msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+func (msgpackCodec) Marshal(val any) ([]byte, error) {
+	return msgpack.Marshal(val)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, val any) error {
+	return msgpack.Unmarshal(data, val)
+}
@@ -0,0 +1,47 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrVersionMismatch 数据是别的结构版本写的，调用方应该当成缓存没命中
+	ErrVersionMismatch = errors.New("缓存数据的版本不一致")
+	// ErrInvalidEnvelope 不是 Encode 写进去的数据，比如升级之前直接存的 JSON
+	ErrInvalidEnvelope = errors.New("缓存数据格式不对")
+)
+
+// magic 放在最前面，JSON 和 msgpack 都不会以它开头，用来识别旧格式的数据
+const magic byte = 0xFE
+
+const headerSize = 3
+
+// Encode 把 val 编码成带版本的数据：1 字节 magic + 1 字节 Codec ID + 1 字节结构版本 + 数据
+//
+// 结构版本由调用方维护，缓存的结构改了（加减字段、改类型）就加一，
+// 这样新旧版本的服务同时在跑的时候，读到对方写的数据只会当成没命中，不会反序列化出错或者读出错的值
+func Encode(c Codec, version byte, val any) ([]byte, error) {
+	data, err := c.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]byte, 0, headerSize+len(data))
+	res = append(res, magic, c.ID(), version)
+	return append(res, data...), nil
+}
+
+// Decode 按数据里记录的 Codec 解码，所以换 Codec 的时候旧数据还能读
+func Decode(data []byte, version byte, val any) error {
+	if len(data) < headerSize || data[0] != magic {
+		return ErrInvalidEnvelope
+	}
+	c, ok := codecs[data[1]]
+	if !ok {
+		return fmt.Errorf("%w: 未知的 codec %d", ErrInvalidEnvelope, data[1])
+	}
+	if data[2] != version {
+		return fmt.Errorf("%w: 期望 %d，实际 %d", ErrVersionMismatch, version, data[2])
+	}
+	return c.Unmarshal(data[headerSize:], val)
+}
@@ -0,0 +1,40 @@
+package codec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testVal struct {
+	Name string `json:"name" msgpack:"name"`
+	Age  int    `json:"age" msgpack:"age"`
+}
+
+func TestEnvelope(t *testing.T) {
+	val := testVal{Name: "Tom", Age: 18}
+	for _, c := range []Codec{JSON, Msgpack} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := Encode(c, 1, val)
+			require.NoError(t, err)
+
+			var got testVal
+			require.NoError(t, Decode(data, 1, &got))
+			assert.Equal(t, val, got)
+
+			// 版本对不上
+			assert.ErrorIs(t, Decode(data, 2, &got), ErrVersionMismatch)
+		})
+	}
+
+	// 升级之前存的 JSON
+	var got testVal
+	assert.ErrorIs(t, Decode([]byte(`{"name":"Tom"}`), 1, &got), ErrInvalidEnvelope)
+	// 未知的 codec
+	assert.ErrorIs(t, Decode([]byte{magic, 99, 1}, 1, &got), ErrInvalidEnvelope)
+
+	// msgpack 比 JSON 小
+	j, _ := Encode(JSON, 1, val)
+	m, _ := Encode(Msgpack, 1, val)
+	assert.Less(t, len(m), len(j))
+}
@@ -0,0 +1,63 @@
+package cryptox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+var ErrInvalidCiphertext = errors.New("密文格式不对")
+
+// Cipher 对敏感字段进行加解密，比如存到数据库里的第三方凭证
+type Cipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
+// AESGCM 用 AES-GCM 加密，输出的是 base64(nonce + 密文)
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM key 的长度必须是 16、24 或 32 字节，分别对应 AES-128、AES-192、AES-256
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+func (c *AESGCM) Encrypt(plaintext string) (string, error) {
+	// 每次加密都要用一个新的随机 nonce，不然同样的明文会得到同样的密文
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	// nonce 放在密文的前面，解密的时候再切出来
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *AESGCM) Decrypt(ciphertext string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	size := c.aead.NonceSize()
+	if len(data) < size {
+		return "", ErrInvalidCiphertext
+	}
+	plaintext, err := c.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
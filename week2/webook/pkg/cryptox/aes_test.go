@@ -0,0 +1,90 @@
+package cryptox
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAESGCM(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	c, err := NewAESGCM(key)
+	require.NoError(t, err)
+
+	// 加密之后能解出来，同样的明文每次密文都不一样
+	ct1, err := c.Encrypt("access-token")
+	require.NoError(t, err)
+	ct2, err := c.Encrypt("access-token")
+	require.NoError(t, err)
+	assert.NotEqual(t, ct1, ct2)
+	assert.NotContains(t, ct1, "access-token")
+	pt, err := c.Decrypt(ct1)
+	require.NoError(t, err)
+	assert.Equal(t, "access-token", pt)
+
+	// 空字符串也能加解密
+	ct, err := c.Encrypt("")
+	require.NoError(t, err)
+	pt, err = c.Decrypt(ct)
+	require.NoError(t, err)
+	assert.Equal(t, "", pt)
+}
+
+func TestAESGCM_Decrypt(t *testing.T) {
+	c, err := NewAESGCM([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	other, err := NewAESGCM([]byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(t, err)
+	ct, err := c.Encrypt("access-token")
+	require.NoError(t, err)
+	data, err := base64.StdEncoding.DecodeString(ct)
+	require.NoError(t, err)
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 0x01
+
+	testCases := []struct {
+		name       string
+		cipher     Cipher
+		ciphertext string
+		wantErr    error
+	}{
+		{
+			name:       "密文被改过",
+			cipher:     c,
+			ciphertext: base64.StdEncoding.EncodeToString(tampered),
+		},
+		{
+			name:       "key 不对",
+			cipher:     other,
+			ciphertext: ct,
+		},
+		{
+			name:       "比 nonce 还短",
+			cipher:     c,
+			ciphertext: base64.StdEncoding.EncodeToString(data[:5]),
+			wantErr:    ErrInvalidCiphertext,
+		},
+		{
+			name:       "不是 base64",
+			cipher:     c,
+			ciphertext: "不是base64",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pt, err := tc.cipher.Decrypt(tc.ciphertext)
+			// 解不出来的都要报错，不能返回一个看起来正常的明文
+			require.Error(t, err)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+			}
+			assert.Equal(t, "", pt)
+		})
+	}
+}
+
+func TestNewAESGCM(t *testing.T) {
+	_, err := NewAESGCM([]byte("too-short"))
+	assert.Error(t, err)
+}
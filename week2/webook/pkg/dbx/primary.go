@@ -0,0 +1,18 @@
+// Package dbx 数据库相关的 context 提示，不依赖具体的 ORM，service 层也可以用
+package dbx
+
+import "context"
+
+type primaryKey struct{}
+
+// WithPrimary 要求这个 ctx 上的读请求都走主库。
+// 刚写完马上要读的时候用，不然从库还没同步到，会读不到刚写进去的数据（主从延迟）
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// IsPrimary ctx 是不是要求读主库
+func IsPrimary(ctx context.Context) bool {
+	val, _ := ctx.Value(primaryKey{}).(bool)
+	return val
+}
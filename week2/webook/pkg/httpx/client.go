@@ -0,0 +1,170 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+var ErrResponseTooLarge = errors.New("响应体超过了大小限制")
+
+// Client 对外发 HTTP 请求的抽象，所有第三方接口（微信、短信等）都用它，方便注入和测试
+type Client interface {
+	// Do 发出请求并读完、关闭 body。非 2xx 的响应返回 *StatusError
+	Do(req *http.Request) (*Response, error)
+}
+
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// JSON 把 body 反序列化到 val
+func (r *Response) JSON(val any) error {
+	return json.Unmarshal(r.Body, val)
+}
+
+// StatusError 对方返回了非 2xx 的状态码
+type StatusError struct {
+	StatusCode int
+	// Body 只保留一部分，方便排查
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP 状态码 %d: %s", e.StatusCode, e.Body)
+}
+
+// RetryClient 带超时、重试、响应大小限制和请求日志的 Client
+type RetryClient struct {
+	client *http.Client
+	// 每次尝试的超时时间
+	timeout time.Duration
+	// 最多重试几次（不算第一次）
+	maxRetries int
+	// 第一次重试前等多久，之后每次翻倍
+	retryInterval time.Duration
+	// 响应体最大多少字节
+	maxBodySize int64
+}
+
+func NewRetryClient(client *http.Client) *RetryClient {
+	return &RetryClient{
+		client:        client,
+		timeout:       time.Second * 3,
+		maxRetries:    2,
+		retryInterval: time.Millisecond * 100,
+		maxBodySize:   1 << 20,
+	}
+}
+
+func (c *RetryClient) Timeout(timeout time.Duration) *RetryClient {
+	c.timeout = timeout
+	return c
+}
+
+func (c *RetryClient) MaxRetries(maxRetries int) *RetryClient {
+	c.maxRetries = maxRetries
+	return c
+}
+
+func (c *RetryClient) RetryInterval(interval time.Duration) *RetryClient {
+	c.retryInterval = interval
+	return c
+}
+
+func (c *RetryClient) MaxBodySize(size int64) *RetryClient {
+	c.maxBodySize = size
+	return c
+}
+
+func (c *RetryClient) Do(req *http.Request) (*Response, error) {
+	interval := c.retryInterval
+	for i := 0; ; i++ {
+		start := time.Now()
+		resp, err := c.do(req)
+		// 日志里不打 query，里面可能有 secret 和 access_token
+		log.Printf("HTTP %s %s%s 第 %d 次 耗时 %s 错误 %v",
+			req.Method, req.URL.Host, req.URL.Path, i+1, time.Since(start), err)
+		if err == nil || i >= c.maxRetries || !c.retryable(req, err) {
+			return resp, err
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if req.GetBody != nil {
+			// 有 body 的请求，重试的时候要重新拿一份 body
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (c *RetryClient) do(req *http.Request) (*Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.timeout)
+	defer cancel()
+	rep, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rep.Body.Close()
+	// 多读一个字节，用来判断是不是超过了限制
+	body, err := io.ReadAll(io.LimitReader(rep.Body, c.maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > c.maxBodySize {
+		return nil, ErrResponseTooLarge
+	}
+	if rep.StatusCode < 200 || rep.StatusCode >= 300 {
+		const maxErrBody = 256
+		if len(body) > maxErrBody {
+			body = body[:maxErrBody]
+		}
+		return nil, &StatusError{StatusCode: rep.StatusCode, Body: string(body)}
+	}
+	return &Response{
+		StatusCode: rep.StatusCode,
+		Header:     rep.Header,
+		Body:       body,
+	}, nil
+}
+
+// retryable 只有幂等的请求，并且是网络错误、超时、5xx 或者 429 才重试
+func (c *RetryClient) retryable(req *http.Request, err error) bool {
+	if req.Context().Err() != nil {
+		// 调用方自己取消了或者整体超时了
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// body 已经被读掉了，没法再发一次
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500 || se.StatusCode == http.StatusTooManyRequests
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		// 单次尝试超时
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
@@ -0,0 +1,143 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryClient_Do(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 第几次请求（从 1 开始）返回什么
+		handler  func(attempt int64, w http.ResponseWriter)
+		method   string
+		wantCnt  int64
+		wantBody string
+		wantErr  func(t *testing.T, err error)
+	}{
+		{
+			name:   "一次成功",
+			method: http.MethodGet,
+			handler: func(attempt int64, w http.ResponseWriter) {
+				_, _ = w.Write([]byte(`{"ok":true}`))
+			},
+			wantCnt:  1,
+			wantBody: `{"ok":true}`,
+		},
+		{
+			name:   "5xx 之后重试成功",
+			method: http.MethodGet,
+			handler: func(attempt int64, w http.ResponseWriter) {
+				if attempt < 3 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				_, _ = w.Write([]byte("ok"))
+			},
+			wantCnt:  3,
+			wantBody: "ok",
+		},
+		{
+			name:   "重试次数用完",
+			method: http.MethodGet,
+			handler: func(attempt int64, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantCnt: 3,
+			wantErr: func(t *testing.T, err error) {
+				var se *StatusError
+				require.True(t, errors.As(err, &se))
+				assert.Equal(t, http.StatusServiceUnavailable, se.StatusCode)
+			},
+		},
+		{
+			name:   "4xx 不重试",
+			method: http.MethodGet,
+			handler: func(attempt int64, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte("bad request"))
+			},
+			wantCnt: 1,
+			wantErr: func(t *testing.T, err error) {
+				var se *StatusError
+				require.True(t, errors.As(err, &se))
+				assert.Equal(t, "bad request", se.Body)
+			},
+		},
+		{
+			name:   "POST 不重试",
+			method: http.MethodPost,
+			handler: func(attempt int64, w http.ResponseWriter) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantCnt: 1,
+			wantErr: func(t *testing.T, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name:   "响应太大",
+			method: http.MethodGet,
+			handler: func(attempt int64, w http.ResponseWriter) {
+				_, _ = w.Write([]byte(strings.Repeat("a", 33)))
+			},
+			wantCnt: 1,
+			wantErr: func(t *testing.T, err error) {
+				assert.Equal(t, ErrResponseTooLarge, err)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var cnt int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc.handler(atomic.AddInt64(&cnt, 1), w)
+			}))
+			defer server.Close()
+			client := NewRetryClient(server.Client()).
+				RetryInterval(time.Millisecond).
+				MaxBodySize(32)
+
+			req, err := http.NewRequestWithContext(context.Background(), tc.method, server.URL, nil)
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			assert.Equal(t, tc.wantCnt, atomic.LoadInt64(&cnt))
+			if tc.wantErr != nil {
+				tc.wantErr(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, string(resp.Body))
+		})
+	}
+}
+
+func TestRetryClient_Timeout(t *testing.T) {
+	var cnt int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&cnt, 1) == 1 {
+			// 第一次故意超时
+			time.Sleep(time.Millisecond * 100)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	client := NewRetryClient(server.Client()).
+		Timeout(time.Millisecond * 20).
+		RetryInterval(time.Millisecond)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(resp.Body))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&cnt))
+}
@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"fmt"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Catalog 多语言的消息目录，每种语言一个 yaml 文件，文件名就是语言，比如 zh-CN.yaml、en-US.yaml
+// 文件内容是 key: 消息 的平铺结构，消息里可以用 {name} 占位
+type Catalog struct {
+	// locale -> key -> 消息
+	messages      map[string]map[string]string
+	defaultLocale string
+	// 和 matcher 里的顺序一一对应
+	locales []string
+	matcher language.Matcher
+}
+
+// Load 从 fsys 的 dir 目录加载所有的 yaml 文件，defaultLocale 必须在里面
+func Load(fsys fs.FS, dir string, defaultLocale string) (*Catalog, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{
+		messages:      make(map[string]map[string]string, len(entries)),
+		defaultLocale: defaultLocale,
+	}
+	// 默认语言放第一个，matcher 匹配不上的时候会返回第一个
+	tags := []language.Tag{language.Make(defaultLocale)}
+	c.locales = []string{defaultLocale}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".yaml" {
+			continue
+		}
+		locale := strings.TrimSuffix(entry.Name(), ".yaml")
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var msgs map[string]string
+		if err = yaml.Unmarshal(data, &msgs); err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", entry.Name(), err)
+		}
+		c.messages[locale] = msgs
+		if locale != defaultLocale {
+			tags = append(tags, language.Make(locale))
+			c.locales = append(c.locales, locale)
+		}
+	}
+	if _, ok := c.messages[defaultLocale]; !ok {
+		return nil, fmt.Errorf("缺少默认语言 %s 的消息文件", defaultLocale)
+	}
+	c.matcher = language.NewMatcher(tags)
+	return c, nil
+}
+
+// Supports locale 有没有对应的消息文件
+func (c *Catalog) Supports(locale string) bool {
+	_, ok := c.messages[locale]
+	return ok
+}
+
+// Match 按 Accept-Language 选一个支持的语言，比如 "en;q=0.9,zh;q=0.8" 会选 en-US
+func (c *Catalog) Match(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return c.defaultLocale
+	}
+	_, idx, conf := c.matcher.Match(tags...)
+	if conf == language.No {
+		return c.defaultLocale
+	}
+	return c.locales[idx]
+}
+
+// Localizer 返回某个语言的翻译器，不支持的语言用默认语言
+func (c *Catalog) Localizer(locale string) *Localizer {
+	if !c.Supports(locale) {
+		locale = c.defaultLocale
+	}
+	return &Localizer{catalog: c, locale: locale}
+}
+
+func (c *Catalog) translate(locale, key string) (string, bool) {
+	if msg, ok := c.messages[locale][key]; ok {
+		return msg, true
+	}
+	// 这个语言没有翻译，就用默认语言的
+	msg, ok := c.messages[c.defaultLocale][key]
+	return msg, ok
+}
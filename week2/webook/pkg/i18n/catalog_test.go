@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestCatalog(t *testing.T) *Catalog {
+	fsys := fstest.MapFS{
+		"i18n/zh-CN.yaml": {Data: []byte("user.signup_ok: 注册成功\nvalidation.max: 长度不能超过 {param} 个字\n")},
+		"i18n/en-US.yaml": {Data: []byte("user.signup_ok: Signed up\n")},
+	}
+	c, err := Load(fsys, "i18n", "zh-CN")
+	require.NoError(t, err)
+	return c
+}
+
+func TestCatalog_Match(t *testing.T) {
+	c := newTestCatalog(t)
+	testCases := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{acceptLanguage: "", want: "zh-CN"},
+		{acceptLanguage: "en-US,en;q=0.9", want: "en-US"},
+		{acceptLanguage: "en", want: "en-US"},
+		{acceptLanguage: "zh,en;q=0.5", want: "zh-CN"},
+		{acceptLanguage: "fr-FR", want: "zh-CN"},
+		{acceptLanguage: "fr;q=0.9,en;q=0.8", want: "en-US"},
+		{acceptLanguage: "乱写的", want: "zh-CN"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.acceptLanguage, func(t *testing.T) {
+			assert.Equal(t, tc.want, c.Match(tc.acceptLanguage))
+		})
+	}
+}
+
+func TestLocalizer_T(t *testing.T) {
+	c := newTestCatalog(t)
+	en := c.Localizer("en-US")
+	assert.Equal(t, "Signed up", en.T("user.signup_ok", "", nil))
+	// 英文没有翻译的用中文
+	assert.Equal(t, "长度不能超过 200 个字", en.T("validation.max", "", map[string]string{"param": "200"}))
+	// 目录里没有的 key 用 fallback
+	assert.Equal(t, "兜底", en.T("no.such.key", "兜底", nil))
+	// 不支持的语言用默认语言
+	assert.Equal(t, "zh-CN", c.Localizer("fr-FR").Locale())
+	// ctx 里没有 Localizer
+	assert.Equal(t, "兜底", T(context.Background(), "user.signup_ok", "兜底", nil))
+}
@@ -0,0 +1,46 @@
+package i18n
+
+import (
+	"context"
+	"strings"
+)
+
+// ContextKey Localizer 放在 gin.Context 里用的 key
+const ContextKey = "i18n_localizer"
+
+// Localizer 某个语言的翻译器，一个请求一个
+type Localizer struct {
+	catalog *Catalog
+	locale  string
+}
+
+func (l *Localizer) Locale() string {
+	return l.locale
+}
+
+// T 翻译 key，args 按 {name} 替换。目录里没有这个 key 的时候返回 fallback
+func (l *Localizer) T(key string, fallback string, args map[string]string) string {
+	msg, ok := l.catalog.translate(l.locale, key)
+	if !ok {
+		return fallback
+	}
+	for name, val := range args {
+		msg = strings.ReplaceAll(msg, "{"+name+"}", val)
+	}
+	return msg
+}
+
+// FromContext 取出中间件放进去的 Localizer（gin.Context 也是 context.Context）
+func FromContext(ctx context.Context) (*Localizer, bool) {
+	l, ok := ctx.Value(ContextKey).(*Localizer)
+	return l, ok
+}
+
+// T 从 ctx 里拿 Localizer 翻译，没有的话（比如单元测试里）直接用 fallback
+func T(ctx context.Context, key string, fallback string, args map[string]string) string {
+	l, ok := FromContext(ctx)
+	if !ok {
+		return fallback
+	}
+	return l.T(key, fallback, args)
+}
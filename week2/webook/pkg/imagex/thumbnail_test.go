@@ -0,0 +1,65 @@
+package imagex
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	// 左边一半红、右边一半蓝的 400x200，中间裁出来的正方形左红右蓝
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 200 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+	testCases := []struct {
+		name     string
+		src      image.Image
+		size     int
+		wantSize int
+		// 要检查的像素和颜色
+		want map[image.Point]color.RGBA
+	}{
+		{
+			name:     "裁剪并缩小",
+			src:      src,
+			size:     50,
+			wantSize: 50,
+			want: map[image.Point]color.RGBA{
+				{X: 0, Y: 0}:   {R: 255, A: 255},
+				{X: 49, Y: 49}: {B: 255, A: 255},
+			},
+		},
+		{
+			name:     "原图太小不放大",
+			src:      src,
+			size:     300,
+			wantSize: 200,
+		},
+		{
+			name:     "透明的地方填白色",
+			src:      image.NewNRGBA(image.Rect(0, 0, 10, 10)),
+			size:     5,
+			wantSize: 5,
+			want: map[image.Point]color.RGBA{
+				{X: 2, Y: 2}: {R: 255, G: 255, B: 255, A: 255},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := Thumbnail(tc.src, tc.size)
+			assert.Equal(t, image.Rect(0, 0, tc.wantSize, tc.wantSize), res.Bounds())
+			for p, c := range tc.want {
+				assert.Equal(t, c, res.RGBAAt(p.X, p.Y), p)
+			}
+		})
+	}
+}
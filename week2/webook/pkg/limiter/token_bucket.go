@@ -0,0 +1,48 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket 单机的令牌桶，并发安全
+// 和 ginx/middleware/ratelimit 不一样，它不依赖 redis，redis 挂了也能用
+type TokenBucket struct {
+	lock sync.Mutex
+	// 每秒放多少个令牌
+	rate float64
+	// 桶的容量，也就是最多允许多少突发请求
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewTokenBucket 每秒 rate 个令牌，一开始桶是满的
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Allow 拿一个令牌，拿不到返回 false，不会阻塞
+func (b *TokenBucket) Allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := b.now()
+	// 按上次到现在过去的时间补令牌，不超过容量
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
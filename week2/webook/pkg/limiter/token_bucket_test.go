@@ -0,0 +1,38 @@
+package limiter
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := NewTokenBucket(2, 3)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	// 一开始桶是满的，可以突发 3 个
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Allow())
+	}
+	assert.False(t, b.Allow())
+
+	// 每秒 2 个，半秒补 1 个
+	now = now.Add(time.Millisecond * 500)
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+
+	// 不到一个令牌的时间拿不到，但是补的零头会攒下来
+	now = now.Add(time.Millisecond * 300)
+	assert.False(t, b.Allow())
+	now = now.Add(time.Millisecond * 200)
+	assert.True(t, b.Allow())
+
+	// 空闲很久也最多攒 burst 个
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.Allow())
+	}
+	assert.False(t, b.Allow())
+}
@@ -0,0 +1,299 @@
+// Package migrate 按版本号执行数据库迁移脚本，已经执行过的记在 schema_migrations 表里
+//
+// 脚本的文件名是 {版本号}_{名字}.up.sql 和 {版本号}_{名字}.down.sql，比如 0001_create_users.up.sql。
+// 版本号必须是递增的整数，已经发布的脚本不能再改，要改表结构就加一个新版本
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var fileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移脚本
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	// Down 回滚脚本，可以没有，没有的版本不能回滚
+	Down string
+}
+
+// Status 一个版本的执行情况
+type Status struct {
+	Version int64
+	Name    string
+	Applied bool
+	// AppliedAt 执行时间，UTC 0 的毫秒数，没执行过是 0
+	AppliedAt int64
+}
+
+// SchemaMigration schema_migrations 表，记录执行过的版本
+type SchemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"type:varchar(255)"`
+	AppliedAt int64
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Check 检查执行完某个版本之后表结构对不对，比如用 IF NOT EXISTS 建表的时候老表缺了列
+type Check func(db *gorm.DB) error
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	checks     map[int64][]Check
+}
+
+// New 从 fsys 的 dir 目录读取所有的迁移脚本
+func New(db *gorm.DB, fsys fs.FS, dir string) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := fileRegexp.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("迁移脚本的文件名不对: %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(matches[1], 10, 64)
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		}
+		if m.Name != matches[2] {
+			return nil, fmt.Errorf("版本 %d 有两个名字: %s 和 %s", version, m.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+	res := &Migrator{
+		db:         db,
+		migrations: make([]Migration, 0, len(byVersion)),
+		checks:     make(map[int64][]Check),
+	}
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("版本 %d 没有 up 脚本", m.Version)
+		}
+		res.migrations = append(res.migrations, *m)
+	}
+	sort.Slice(res.migrations, func(i, j int) bool {
+		return res.migrations[i].Version < res.migrations[j].Version
+	})
+	return res, nil
+}
+
+// Up 执行所有还没执行过的版本，返回这次执行了的
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	err := m.createTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []Migration
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+		err = m.exec(ctx, mg.Up, func(tx *gorm.DB) error {
+			// 检查不通过就不记下来，修好之后再执行一次
+			if err := m.check(tx, mg.Version); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   mg.Version,
+				Name:      mg.Name,
+				AppliedAt: time.Now().UnixMilli(),
+			}).Error
+		})
+		if err != nil {
+			return res, fmt.Errorf("执行版本 %d_%s 失败: %w", mg.Version, mg.Name, err)
+		}
+		res = append(res, mg)
+	}
+	return res, nil
+}
+
+// Down 从最新的版本开始回滚 steps 个版本，返回这次回滚了的
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(res) < steps; i-- {
+		mg := m.migrations[i]
+		if _, ok := applied[mg.Version]; !ok {
+			continue
+		}
+		if mg.Down == "" {
+			return res, fmt.Errorf("版本 %d_%s 没有 down 脚本，不能回滚", mg.Version, mg.Name)
+		}
+		err = m.exec(ctx, mg.Down, func(tx *gorm.DB) error {
+			return tx.Delete(&SchemaMigration{}, mg.Version).Error
+		})
+		if err != nil {
+			return res, fmt.Errorf("回滚版本 %d_%s 失败: %w", mg.Version, mg.Name, err)
+		}
+		res = append(res, mg)
+	}
+	return res, nil
+}
+
+// Status 所有版本的执行情况，按版本号排序
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		sm, ok := applied[mg.Version]
+		res = append(res, Status{
+			Version:   mg.Version,
+			Name:      mg.Name,
+			Applied:   ok,
+			AppliedAt: sm.AppliedAt,
+		})
+	}
+	return res, nil
+}
+
+// Pending 还有没有没执行的版本，服务启动的时候用来检查
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []Migration
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; !ok {
+			res = append(res, mg)
+		}
+	}
+	return res, nil
+}
+
+// AddCheck 给 version 加一个检查，执行完这个版本之后检查，不通过这个版本就不算执行成功。
+// Verify 的时候已经执行过的版本也会再检查一次
+func (m *Migrator) AddCheck(version int64, check Check) {
+	m.checks[version] = append(m.checks[version], check)
+}
+
+// Verify 检查已经执行过的版本，服务启动的时候用，防止之前的版本没检查就记成执行过了
+func (m *Migrator) Verify(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; !ok {
+			continue
+		}
+		if err = m.check(m.db.WithContext(ctx), mg.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) check(db *gorm.DB, version int64) error {
+	for _, c := range m.checks[version] {
+		if err := c(db); err != nil {
+			return fmt.Errorf("版本 %d 检查不通过: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// applied 已经执行过的版本。schema_migrations 表还没建就是一个都没执行过，只查询的时候不建表
+func (m *Migrator) applied(ctx context.Context) (map[int64]SchemaMigration, error) {
+	if !m.db.WithContext(ctx).Migrator().HasTable(&SchemaMigration{}) {
+		return map[int64]SchemaMigration{}, nil
+	}
+	var sms []SchemaMigration
+	err := m.db.WithContext(ctx).Find(&sms).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]SchemaMigration, len(sms))
+	for _, sm := range sms {
+		res[sm.Version] = sm
+	}
+	return res, nil
+}
+
+// createTable 只有 schema_migrations 这一张表是 gorm 按结构体建的，它自己的结构不会再变
+func (m *Migrator) createTable(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		return nil
+	}
+	return db.Migrator().CreateTable(&SchemaMigration{})
+}
+
+// exec 在一个事务里执行脚本里的每一条语句，再更新 schema_migrations
+// note MySQL 的 DDL 会隐式提交事务，所以脚本执行到一半失败了要手动处理，一个脚本最好只做一件事
+func (m *Migrator) exec(ctx context.Context, script string, record func(tx *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range splitStatements(script) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return record(tx)
+	})
+}
+
+// splitStatements 按行尾的分号拆成一条条语句，去掉 -- 开头的注释行。
+// 大部分驱动默认不允许一次执行多条语句
+func splitStatements(script string) []string {
+	var (
+		res []string
+		sb  strings.Builder
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			res = append(res, strings.TrimSpace(sb.String()))
+			sb.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(sb.String()); rest != "" {
+		res = append(res, rest)
+	}
+	return res
+}
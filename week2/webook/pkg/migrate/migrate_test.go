@@ -0,0 +1,160 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+var testFS = fstest.MapFS{
+	"sql/0001_create_a.up.sql":   {Data: []byte("-- 建表 a\nCREATE TABLE a (id integer);\nCREATE INDEX idx_a_id ON a (id);\n")},
+	"sql/0001_create_a.down.sql": {Data: []byte("DROP TABLE a;\n")},
+	"sql/0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id integer);\n")},
+	"sql/0002_create_b.down.sql": {Data: []byte("DROP TABLE b;\n")},
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	require.NoError(t, err)
+	return db
+}
+
+func TestMigrator(t *testing.T) {
+	db := newTestDB(t)
+	m, err := New(db, testFS, "sql")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// 没执行过的时候查状态不会建表
+	sts, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Len(t, sts, 2)
+	assert.False(t, sts[0].Applied)
+	assert.False(t, db.Migrator().HasTable(&SchemaMigration{}))
+
+	done, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, done, 2)
+	assert.True(t, db.Migrator().HasTable("a"))
+	assert.True(t, db.Migrator().HasTable("b"))
+
+	// 再执行一次什么都不做
+	done, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, done, 0)
+
+	done, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.Equal(t, int64(2), done[0].Version)
+	assert.False(t, db.Migrator().HasTable("b"))
+	assert.True(t, db.Migrator().HasTable("a"))
+
+	sts, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, sts[0].Applied)
+	assert.NotZero(t, sts[0].AppliedAt)
+	assert.False(t, sts[1].Applied)
+
+	pending, err := m.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "create_b", pending[0].Name)
+}
+
+func TestMigrator_UpFailed(t *testing.T) {
+	db := newTestDB(t)
+	fsys := fstest.MapFS{
+		"sql/0001_create_a.up.sql": {Data: []byte("CREATE TABLE a (id integer);\n")},
+		"sql/0002_bad.up.sql":      {Data: []byte("CREATE TABLE a (id integer);\n")},
+	}
+	m, err := New(db, fsys, "sql")
+	require.NoError(t, err)
+
+	done, err := m.Up(context.Background())
+	assert.Error(t, err)
+	// 失败之前的版本已经执行了，失败的版本没有记下来
+	assert.Len(t, done, 1)
+	pending, err := m.Pending(context.Background())
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, int64(2), pending[0].Version)
+}
+
+func TestMigrator_Check(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"sql/0001_create_a.up.sql": {Data: []byte("CREATE TABLE IF NOT EXISTS a (id integer, name text);\n")},
+	}
+	m, err := New(db, fsys, "sql")
+	require.NoError(t, err)
+	m.AddCheck(1, func(db *gorm.DB) error {
+		if !db.Migrator().HasColumn("a", "name") {
+			return errors.New("a 缺少 name")
+		}
+		return nil
+	})
+	// 老表缺了列，IF NOT EXISTS 不会补上，检查不通过就不算执行过
+	require.NoError(t, db.Exec("CREATE TABLE a (id integer)").Error)
+	_, err = m.Up(ctx)
+	assert.Error(t, err)
+	pending, err := m.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	// 已经记成执行过了（比如加检查之前执行的），启动的时候 Verify 能发现
+	require.NoError(t, db.Create(&SchemaMigration{Version: 1, Name: "create_a"}).Error)
+	assert.Error(t, m.Verify(ctx))
+
+	// 手动补上之后就好了
+	require.NoError(t, db.Exec("ALTER TABLE a ADD COLUMN name text").Error)
+	assert.NoError(t, m.Verify(ctx))
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "文件名不对",
+			fsys: fstest.MapFS{"sql/create_a.sql": {Data: []byte("CREATE TABLE a (id integer);")}},
+		},
+		{
+			name: "只有 down 脚本",
+			fsys: fstest.MapFS{"sql/0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")}},
+		},
+		{
+			name: "同一个版本两个名字",
+			fsys: fstest.MapFS{
+				"sql/0001_create_a.up.sql": {Data: []byte("CREATE TABLE a (id integer);")},
+				"sql/0001_create_b.up.sql": {Data: []byte("CREATE TABLE b (id integer);")},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(newTestDB(t), tc.fsys, "sql")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := splitStatements("-- 注释\nCREATE TABLE a (\n  id integer\n);\n\nINSERT INTO a VALUES (1);\nSELECT 1")
+	assert.Equal(t, []string{
+		"CREATE TABLE a (\n  id integer\n);",
+		"INSERT INTO a VALUES (1);",
+		"SELECT 1",
+	}, stmts)
+}
@@ -0,0 +1,78 @@
+package signurl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	signer := NewSigner([]byte("test-key"))
+	now := time.Now()
+	signed := signer.Sign(url.Values{"id": {"export-1"}}, now.Add(time.Minute))
+
+	testCases := []struct {
+		name    string
+		params  func() url.Values
+		now     time.Time
+		wantErr error
+	}{
+		{
+			name: "签名正确",
+			params: func() url.Values {
+				return signed
+			},
+			now: now,
+		},
+		{
+			name: "过期了",
+			params: func() url.Values {
+				return signed
+			},
+			now:     now.Add(time.Minute * 2),
+			wantErr: ErrExpired,
+		},
+		{
+			name: "改了参数",
+			params: func() url.Values {
+				res, _ := url.ParseQuery(signed.Encode())
+				res.Set("id", "export-2")
+				return res
+			},
+			now:     now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "改了过期时间",
+			params: func() url.Values {
+				res, _ := url.ParseQuery(signed.Encode())
+				res.Set("expires", "9999999999")
+				return res
+			},
+			now:     now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "别的 key 签的",
+			params: func() url.Values {
+				return NewSigner([]byte("other-key")).Sign(url.Values{"id": {"export-1"}}, now.Add(time.Minute))
+			},
+			now:     now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "没有签名",
+			params: func() url.Values {
+				return url.Values{"id": {"export-1"}}
+			},
+			now:     now,
+			wantErr: ErrInvalidSignature,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantErr, signer.Verify(tc.params(), tc.now))
+		})
+	}
+}
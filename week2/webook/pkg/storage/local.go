@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStorage 存在本地磁盘上，由 web 服务器自己把 root 目录挂在 urlPrefix 下面。
+// 只适合单实例部署和开发环境，多个实例之间的文件不共享
+type LocalStorage struct {
+	root      string
+	urlPrefix string
+}
+
+func NewLocalStorage(root string, urlPrefix string) *LocalStorage {
+	return &LocalStorage{
+		root:      root,
+		urlPrefix: urlPrefix,
+	}
+}
+
+// Root 文件保存的目录
+func (s *LocalStorage) Root() string {
+	return s.root
+}
+
+// URLPrefix 对外访问的路径前缀，比如 /uploads
+func (s *LocalStorage) URLPrefix() string {
+	return s.urlPrefix
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，别人不会读到写了一半的文件
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(filepath.Join(s.root, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return joinURL(s.urlPrefix, key)
+}
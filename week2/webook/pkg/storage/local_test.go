@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	s := NewLocalStorage(root, "/uploads/")
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, "avatars/1/a.jpg", []byte("hello"), "image/jpeg"))
+	data, err := os.ReadFile(filepath.Join(root, "avatars", "1", "a.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, "/uploads/avatars/1/a.jpg", s.URL("avatars/1/a.jpg"))
+
+	// 覆盖
+	require.NoError(t, s.Put(ctx, "avatars/1/a.jpg", []byte("world"), "image/jpeg"))
+	data, err = os.ReadFile(filepath.Join(root, "avatars", "1", "a.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(data))
+
+	for _, key := range []string{"../a.jpg", "avatars/../../a.jpg", "/etc/passwd", "avatars/.hidden", ""} {
+		assert.Equal(t, ErrInvalidKey, s.Put(ctx, key, []byte("x"), "text/plain"), key)
+	}
+
+	require.NoError(t, s.Delete(ctx, "avatars/1/a.jpg"))
+	// 不存在也不报错
+	require.NoError(t, s.Delete(ctx, "avatars/1/a.jpg"))
+	_, err = os.Stat(filepath.Join(root, "avatars", "1", "a.jpg"))
+	assert.True(t, os.IsNotExist(err))
+}
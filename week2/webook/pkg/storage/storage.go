@@ -0,0 +1,33 @@
+// Package storage 文件存储的抽象，有本地磁盘和 S3 兼容的对象存储（AWS S3、MinIO 等）两种实现
+package storage
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("非法的文件 key")
+
+// Storage 按 key 存文件。key 是用 / 分隔的相对路径，比如 avatars/1/abc.jpg
+type Storage interface {
+	// Put 保存文件，key 已经存在就覆盖。存的都是头像这种小文件，所以直接传内容
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete 删除文件，文件不存在不算错
+	Delete(ctx context.Context, key string) error
+	// URL 文件对外访问的地址
+	URL(key string) string
+}
+
+var keyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*(/[A-Za-z0-9_-][A-Za-z0-9._-]*)*$`)
+
+// validKey key 只能有字母、数字和 ._-，每一段不能以 . 开头，防止用 .. 写到别的目录。
+// 这样 key 放进 URL 里也不用转义
+func validKey(key string) bool {
+	return len(key) <= 512 && keyRegexp.MatchString(key)
+}
+
+func joinURL(prefix string, key string) string {
+	return strings.TrimSuffix(prefix, "/") + "/" + key
+}
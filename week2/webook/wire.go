@@ -3,6 +3,7 @@
 package main
 
 import (
+	"basic-go/week2/webook/internal/job"
 	"basic-go/week2/webook/internal/repository"
 	"basic-go/week2/webook/internal/repository/cache"
 	"basic-go/week2/webook/internal/repository/dao"
@@ -10,28 +11,43 @@ import (
 	"basic-go/week2/webook/internal/web"
 	ijwt "basic-go/week2/webook/internal/web/jwt"
 	"basic-go/week2/webook/ioc"
-	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
+	"github.com/redis/go-redis/v9"
 )
 
-func InitWebServer() *gin.Engine {
+func InitApp() *App {
 	wire.Build(
 		// 第三方依赖
-		ioc.InitRedis, ioc.InitDB,
+		ioc.InitRedis, wire.Bind(new(redis.Cmdable), new(redis.UniversalClient)), ioc.InitDB, ioc.InitWechatTokenCipher, ioc.InitHTTPClient,
+		ioc.InitStorage,
+		ioc.InitI18nCatalog,
 		// dao和cache
-		dao.NewUserDao, cache.NewUserCache, cache.NewCodeCache,
+		ioc.InitUserMigration, ioc.InitUserDao, ioc.InitUserCache, cache.NewCodeCache,
+		dao.NewWechatTokenDao, cache.NewSessionCache, cache.NewUserExportCache, dao.NewAuthEventDao,
+		dao.NewArticleDao, cache.NewArticleCache, dao.NewInteractiveDao, cache.NewInteractiveCache,
 		// repository
 		repository.NewCachedUserRepository, repository.NewCodeRepository,
+		repository.NewWechatTokenRepository, repository.NewSessionRepository, repository.NewUserExportRepository,
+		repository.NewAuthEventRepository, repository.NewArticleRepository, repository.NewInteractiveRepository,
 		// service
 		ioc.InitSMSService, service.NewUserService, service.NewCodeService,
-		ioc.InitWechatService,
+		ioc.InitWechatApps, service.NewWechatUserService, service.NewUserExportService,
+		ioc.InitAuthAuditService, service.NewAvatarService, service.NewArticleService,
+		service.NewInteractiveService,
+		// 后台任务
+		job.NewWechatTokenRefreshJob, ioc.InitUserMigrationValidateJob, job.NewUserAnonymizeJob,
 
 		// handler
 		web.NewUserHandler,
 		ijwt.NewRedisJWTHandler,
 		web.NewOAuth2WechatHandler,
+		web.NewUserExportHandler, ioc.InitURLSigner,
+		web.NewUserAvatarHandler,
+		web.NewArticleHandler,
 		// gin.Engine部分
 		ioc.InitGinMiddlewares, ioc.InitWebServer,
+
+		wire.Struct(new(App), "*"),
 	)
-	return gin.Default()
+	return new(App)
 }
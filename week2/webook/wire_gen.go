@@ -7,6 +7,7 @@
 package main
 
 import (
+	"basic-go/week2/webook/internal/job"
 	"basic-go/week2/webook/internal/repository"
 	"basic-go/week2/webook/internal/repository/cache"
 	"basic-go/week2/webook/internal/repository/dao"
@@ -14,27 +15,64 @@ import (
 	"basic-go/week2/webook/internal/web"
 	"basic-go/week2/webook/internal/web/jwt"
 	"basic-go/week2/webook/ioc"
-	"github.com/gin-gonic/gin"
 )
 
 // Injectors from wire.go:
 
-func InitWebServer() *gin.Engine {
-	cmdable := ioc.InitRedis()
-	handler := jwt.NewRedisJWTHandler(cmdable)
-	v := ioc.InitGinMiddlewares(cmdable, handler)
+func InitApp() *App {
+	universalClient := ioc.InitRedis()
+	handler := jwt.NewRedisJWTHandler(universalClient)
+	catalog := ioc.InitI18nCatalog()
 	db := ioc.InitDB()
-	userDao := dao.NewUserDao(db)
-	userCache := cache.NewUserCache(cmdable)
+	userMigration := ioc.InitUserMigration(db)
+	userDao := ioc.InitUserDao(db, userMigration)
+	userCache := ioc.InitUserCache(universalClient)
 	userRepository := repository.NewCachedUserRepository(userDao, userCache)
-	userService := service.NewUserService(userRepository)
-	codeCache := cache.NewCodeCache(cmdable)
+	sessionCache := cache.NewSessionCache(universalClient)
+	sessionRepository := repository.NewSessionRepository(sessionCache)
+	client := ioc.InitHTTPClient()
+	storageStorage := ioc.InitStorage(client)
+	userService := service.NewUserService(userRepository, sessionRepository, storageStorage)
+	v := ioc.InitGinMiddlewares(universalClient, handler, catalog, userService)
+	codeCache := cache.NewCodeCache(universalClient)
 	codeRepository := repository.NewCodeRepository(codeCache)
 	smsService := ioc.InitSMSService()
 	codeService := service.NewCodeService(codeRepository, smsService)
-	userHandler := web.NewUserHandler(userService, codeService, handler)
-	wechatService := ioc.InitWechatService()
-	oAuth2WechatHandler := web.NewOAuth2WechatHandler(wechatService, userService, handler)
-	engine := ioc.InitWebServer(v, userHandler, oAuth2WechatHandler)
-	return engine
+	authEventDao := dao.NewAuthEventDao(db)
+	authEventRepository := repository.NewAuthEventRepository(authEventDao)
+	authAuditService := ioc.InitAuthAuditService(authEventRepository)
+	userHandler := web.NewUserHandler(userService, codeService, authAuditService, handler, catalog)
+	apps := ioc.InitWechatApps(client)
+	wechatTokenDao := dao.NewWechatTokenDao(db)
+	cipher := ioc.InitWechatTokenCipher()
+	wechatTokenRepository := repository.NewWechatTokenRepository(wechatTokenDao, cipher)
+	wechatUserService := service.NewWechatUserService(apps, userRepository, wechatTokenRepository)
+	oAuth2WechatHandler := web.NewOAuth2WechatHandler(apps, userService, wechatUserService, authAuditService, handler)
+	userExportCache := cache.NewUserExportCache(universalClient)
+	userExportRepository := repository.NewUserExportRepository(userExportCache)
+	userExportService := service.NewUserExportService(userRepository, wechatTokenRepository, sessionRepository, authEventRepository, userExportRepository)
+	signer := ioc.InitURLSigner()
+	userExportHandler := web.NewUserExportHandler(userExportService, signer)
+	avatarService := service.NewAvatarService(userRepository, storageStorage)
+	userAvatarHandler := web.NewUserAvatarHandler(avatarService)
+	articleDao := dao.NewArticleDao(db)
+	articleCache := cache.NewArticleCache(universalClient)
+	articleRepository := repository.NewArticleRepository(articleDao, articleCache)
+	articleService := service.NewArticleService(articleRepository, userRepository)
+	interactiveDao := dao.NewInteractiveDao(db)
+	interactiveCache := cache.NewInteractiveCache(universalClient)
+	interactiveRepository := repository.NewInteractiveRepository(interactiveDao, interactiveCache)
+	interactiveService := service.NewInteractiveService(interactiveRepository)
+	articleHandler := web.NewArticleHandler(articleService, interactiveService)
+	engine := ioc.InitWebServer(v, userHandler, oAuth2WechatHandler, userExportHandler, userAvatarHandler, articleHandler, storageStorage)
+	wechatTokenRefreshJob := job.NewWechatTokenRefreshJob(wechatUserService)
+	userMigrationValidateJob := ioc.InitUserMigrationValidateJob(userMigration)
+	userAnonymizeJob := job.NewUserAnonymizeJob(userService)
+	app := &App{
+		Server:                   engine,
+		WechatTokenJob:           wechatTokenRefreshJob,
+		UserMigrationValidateJob: userMigrationValidateJob,
+		UserAnonymizeJob:         userAnonymizeJob,
+	}
+	return app
 }